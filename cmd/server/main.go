@@ -12,18 +12,24 @@ import (
 	"go-echo-template/internal/cache"
 	"go-echo-template/internal/config"
 	"go-echo-template/internal/db"
+	"go-echo-template/internal/mail"
+	"go-echo-template/internal/modules/audit"
 	"go-echo-template/internal/modules/auth"
 	"go-echo-template/internal/modules/user"
+	"go-echo-template/internal/object"
 	"go-echo-template/internal/shared/i18n"
 	"go-echo-template/internal/shared/log"
 	"go-echo-template/internal/shared/response"
 	"go-echo-template/internal/storage"
+	storageAudit "go-echo-template/internal/storage/audit"
 	storageAuth "go-echo-template/internal/storage/auth"
+	storageSession "go-echo-template/internal/storage/session"
 	storageUser "go-echo-template/internal/storage/user"
 	"go-echo-template/web"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -43,6 +49,9 @@ func main() {
 	// Initiate Alarmer
 	alarmer := alarm.NewAlarmer(cfg.Alarmer.Telegram, logger)
 
+	// Initiate Mailer
+	mailer := mail.NewSMTPMailer(cfg.Mail.SMTP)
+
 	// Create Echo instance
 	e := echo.New()
 
@@ -75,29 +84,58 @@ func main() {
 	}
 	defer postgreSQL.Close()
 
-	// Connect to the Redis Cache
-	redis := cache.NewRedisCache(ctx, *cfg.Redis)
-	defer redis.Close()
+	// Connect to the Redis Cache, optional unless it's the session store
+	var redisClient *redis.Client
+	userCache := storageUser.NewNoopUserCache()
+	if cfg.Redis != nil {
+		redisClient = cache.NewRedisCache(ctx, *cfg.Redis)
+		defer redisClient.Close()
+		userCache = storageUser.NewUserCache(redisClient)
+	}
+
+	// Session store, selected by SESSION_STORE
+	sessionStore := storageSession.NewSessionStore(ctx, cfg.Auth.Session, logger, postgreSQL, redisClient)
+
+	// Object store for uploads, selected by OBJECT_STORE
+	blobStore := object.NewBlobStore(ctx, cfg.Object)
 
 	// API grouping
 	api := e.Group("/api")
 
+	// the local object store is served by the app, S3 serves its objects itself
+	if cfg.Object.Local != nil {
+		api.Static("/media", cfg.Object.Local.Dir)
+	}
+
 	// New Storage Dependencies
 	authRepo := storageAuth.NewAuthRepository(logger, postgreSQL)
-	userCache := storageUser.NewUserCache(redis)
 	userRepo := storageUser.NewUserRepository(logger, postgreSQL, userCache)
+	auditRepo := storageAudit.NewAuditRepository(logger, postgreSQL)
 
 	// New Storage
-	newStorage := storage.NewStorage(postgreSQL, userRepo, authRepo)
+	newStorage := storage.NewStorage(postgreSQL, userRepo, authRepo, auditRepo)
 
-	// Auth
-	authService := auth.NewSessionCookieService(cfg.Server, logger, redis, newStorage)
+	// Auth, browsers use the session cookie, API clients use bearer tokens on the same routes under /api/token
+	authService := auth.NewSessionCookieService(cfg.Server, cfg.Auth, logger, alarmer, sessionStore, newStorage, mailer)
+	api.Use(authService.CSRF())
 	auth.NewAuthHandler(logger, alarmer, authService).RegisterRoutes(api)
 
+	tokenAPI := e.Group("/api/token")
+	tokenAuthService := auth.NewJWTService(cfg.Server, cfg.Auth, logger, alarmer, sessionStore, newStorage, mailer)
+	auth.NewAuthHandler(logger, alarmer, tokenAuthService).RegisterRoutes(tokenAPI)
+
 	// User
-	userService := user.NewUserService(logger, newStorage, authService)
+	userService := user.NewUserService(logger, newStorage, authService, cfg.Auth, blobStore)
 	user.NewUserHandler(logger, alarmer, userService, authService).RegisterRoutes(api)
 
+	tokenUserService := user.NewUserService(logger, newStorage, tokenAuthService, cfg.Auth, blobStore)
+	user.NewUserHandler(logger, alarmer, tokenUserService, tokenAuthService).RegisterRoutes(tokenAPI)
+
+	// Audit
+	auditService := audit.NewAuditService(logger, newStorage)
+	audit.NewAuditHandler(logger, alarmer, auditService, authService).RegisterRoutes(api)
+	audit.NewAuditHandler(logger, alarmer, auditService, tokenAuthService).RegisterRoutes(tokenAPI)
+
 	// Register web route
 	if cfg.Server.IsLocal() {
 		target, _ := url.Parse(cfg.Server.LocalWebURL)
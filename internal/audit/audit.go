@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+
+	"go-echo-template/internal/shared/log"
+	storageAudit "go-echo-template/internal/storage/audit"
+	"go-echo-template/internal/storage/audit/sqlc"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Action names a security relevant event as "<resource>.<verb>"
+type Action string
+
+const (
+	ActionLogin                Action = "auth.login"
+	ActionLoginFailed          Action = "auth.login_failed"
+	ActionLogout               Action = "auth.logout"
+	ActionLogoutAll            Action = "auth.logout_all"
+	ActionReauthenticate       Action = "auth.reauthenticate"
+	ActionReauthenticateFailed Action = "auth.reauthenticate_failed"
+	ActionImpersonationStart   Action = "auth.impersonation_start"
+	ActionImpersonationStop    Action = "auth.impersonation_stop"
+	ActionTOTPEnable           Action = "auth.totp_enable"
+	ActionTOTPDisable          Action = "auth.totp_disable"
+	ActionPasskeyAdd           Action = "auth.passkey_add"
+	ActionPasskeyRemove        Action = "auth.passkey_remove"
+	ActionAPIKeyCreate         Action = "auth.api_key_create"
+	ActionAPIKeyRevoke         Action = "auth.api_key_revoke"
+	ActionUserUpdate           Action = "user.update"
+	ActionUserDelete           Action = "user.delete"
+	ActionUserRoleChange       Action = "user.role_change"
+	ActionUserSuspend          Action = "user.suspend"
+	ActionUserRestore          Action = "user.restore"
+	ActionUserPasswordChange   Action = "user.password_change"
+	ActionUserPasswordReset    Action = "user.password_reset"
+	ActionUserEmailChange      Action = "user.email_change"
+	ActionUserAvatarChange     Action = "user.avatar_change"
+)
+
+// Target types
+const (
+	TargetUser = "user"
+)
+
+// Change is the value of a field before and after an event
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Event describes what happened, Write adds where the request came from
+type Event struct {
+	// ActorID is 0 when nobody was authenticated, e.g. on a failed login
+	ActorID    int64
+	Action     Action
+	TargetType string
+	TargetID   int64
+	Diff       map[string]Change
+}
+
+// Write appends the event with the IP, user agent and request ID of the request. Pass the
+// repository of a transaction so that the event commits together with the change it records
+func Write(c echo.Context, repo storageAudit.AuditRepository, event *Event) error {
+	ctx := c.Request().Context()
+
+	diff := event.Diff
+	if diff == nil {
+		diff = map[string]Change{}
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	requestID, _ := ctx.Value(log.RequestIDKey).(string)
+	return repo.CreateAuditEvent(ctx, sqlc.CreateAuditEventParams{
+		ActorID:    sql.NullInt64{Int64: event.ActorID, Valid: event.ActorID != 0},
+		Action:     string(event.Action),
+		TargetType: event.TargetType,
+		TargetID:   sql.NullInt64{Int64: event.TargetID, Valid: event.TargetID != 0},
+		Ip:         c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		RequestID:  requestID,
+		Diff:       diffJSON,
+	})
+}
+
+// Diff returns the fields whose values differ between before and after, fields
+// missing on one side are compared as nil
+func Diff(before, after map[string]any) map[string]Change {
+	diff := make(map[string]Change)
+	for field, from := range before {
+		if to := after[field]; !reflect.DeepEqual(from, to) {
+			diff[field] = Change{From: from, To: to}
+		}
+	}
+	for field, to := range after {
+		if _, ok := before[field]; !ok && to != nil {
+			diff[field] = Change{To: to}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-echo-template/internal/shared/log"
+	storageAudit "go-echo-template/internal/storage/audit"
+	"go-echo-template/internal/storage/audit/sqlc"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+type captureRepo struct {
+	storageAudit.AuditRepository
+	events []sqlc.CreateAuditEventParams
+}
+
+func (r *captureRepo) CreateAuditEvent(ctx context.Context, params sqlc.CreateAuditEventParams) error {
+	r.events = append(r.events, params)
+	return nil
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("Request Details Are Recorded", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/", nil)
+		req.Header.Set("User-Agent", "laptop")
+		req.Header.Set(echo.HeaderXRealIP, "203.0.113.7")
+		req = req.WithContext(context.WithValue(req.Context(), log.RequestIDKey, "req-1"))
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+
+		repo := new(captureRepo)
+		require.NoError(t, Write(c, repo, &Event{
+			ActorID:    1,
+			Action:     ActionUserUpdate,
+			TargetType: TargetUser,
+			TargetID:   42,
+			Diff:       Diff(map[string]any{"name": "Jane"}, map[string]any{"name": "Janet"}),
+		}))
+
+		require.Len(t, repo.events, 1)
+		event := repo.events[0]
+		require.Equal(t, int64(1), event.ActorID.Int64)
+		require.Equal(t, "user.update", event.Action)
+		require.Equal(t, int64(42), event.TargetID.Int64)
+		require.Equal(t, "203.0.113.7", event.Ip)
+		require.Equal(t, "laptop", event.UserAgent)
+		require.Equal(t, "req-1", event.RequestID)
+		require.JSONEq(t, `{"name": {"from": "Jane", "to": "Janet"}}`, string(event.Diff))
+	})
+
+	t.Run("Missing Actor And Target Are Stored As NULL", func(t *testing.T) {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+
+		repo := new(captureRepo)
+		require.NoError(t, Write(c, repo, &Event{Action: ActionLoginFailed}))
+		require.False(t, repo.events[0].ActorID.Valid)
+		require.False(t, repo.events[0].TargetID.Valid)
+		require.JSONEq(t, `{}`, string(repo.events[0].Diff))
+	})
+}
+
+func TestDiff(t *testing.T) {
+	diff := Diff(
+		map[string]any{"name": "Jane", "email": "jane@example.com", "phone": "555"},
+		map[string]any{"name": "Jane", "email": "janet@example.com", "role": "admin"},
+	)
+	require.Equal(t, map[string]Change{
+		"email": {From: "jane@example.com", To: "janet@example.com"},
+		"phone": {From: "555"},
+		"role":  {To: "admin"},
+	}, diff)
+}
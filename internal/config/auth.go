@@ -1,23 +1,281 @@
 package config
 
-import "go-echo-template/internal/shared/utils"
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 type AuthConfig struct {
-	Session *SessionConfig
+	Session           *SessionConfig
+	EmailVerification *EmailVerificationConfig
+	WebAuthn          *WebAuthnConfig
+	OIDC              *OIDCConfig
+	JWT               *JWTConfig
+	Lockout           *LockoutConfig
+	SessionLimit      *SessionLimitConfig
+	// PasswordHash is what new password hashes are created with, logins upgrade older hashes
+	PasswordHash *utils.PasswordHashParams
+	// PasswordHistory is how many of the latest passwords, the current one included, a
+	// password change can't reuse. Zero turns the check off
+	PasswordHistory int
 }
 
 type SessionConfig struct {
-	SESSION_SECRET string
+	// SESSION_SECRET signs session IDs and CSRF tokens, signatures of PreviousSecrets are
+	// still accepted so that the secret can be rotated without logging everyone out
+	SESSION_SECRET  string
+	PreviousSecrets []string
+	// CookieDomain shares the session and CSRF cookies with subdomains when set
+	CookieDomain   string
+	CookieSameSite http.SameSite
+	// IdleTimeout ends sessions that weren't used for that long, AbsoluteTimeout ends
+	// every session that long after the login no matter how active it is
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+	// SlideInterval throttles how often activity moves the idle expiry of a session along
+	SlideInterval time.Duration
+	// Store keeps sessions and the other short-lived auth state, one of redis, postgres or memory.
+	// The memory store is lost on restart and not shared between instances
+	Store string
+	// CleanupInterval is how often the postgres store deletes expired rows
+	CleanupInterval time.Duration
+}
+
+type EmailVerificationConfig struct {
+	Secret string
+	TTL    time.Duration
+}
+
+// WebAuthnConfig describes the relying party passkeys are bound to
+type WebAuthnConfig struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+}
+
+// OIDCConfig lists the OpenID Connect providers users can log in with, keyed by
+// the name used in the /v1/auth/oidc/:provider routes
+type OIDCConfig struct {
+	Providers map[string]*OIDCProviderConfig
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// JWTConfig configures the bearer tokens of API clients. HS256 signs with Secret,
+// EdDSA with PrivateKey, a PEM encoded PKCS #8 Ed25519 key
+type JWTConfig struct {
+	Algorithm       string
+	Secret          string
+	PrivateKey      string
+	Issuer          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// LockoutConfig limits failed password logins per email and per client IP. Reaching
+// a limit within Window locks logins for BaseDuration, doubled with every further
+// lockout up to MaxDuration
+type LockoutConfig struct {
+	MaxAttemptsPerEmail int
+	MaxAttemptsPerIP    int
+	Window              time.Duration
+	BaseDuration        time.Duration
+	MaxDuration         time.Duration
+	// number of distinct accounts a single IP may fail to log in to within Window
+	// before a password spraying alarm is raised
+	SprayThreshold int
+}
+
+// Session limit policies
+const (
+	SessionLimitEvict  = "evict"
+	SessionLimitReject = "reject"
+)
+
+// SessionLimitConfig caps the simultaneous sessions of a user by role, roles without
+// a positive limit are unlimited. A login over the limit evicts the oldest sessions
+// or is rejected, depending on Policy
+type SessionLimitConfig struct {
+	PerRole map[string]int
+	Policy  string
 }
 
 func newSessionConfig() *SessionConfig {
-	return &SessionConfig{
-		SESSION_SECRET: utils.MustGetStrEnv("SESSION_SECRET"),
+	sessionConfig := &SessionConfig{
+		SESSION_SECRET:  utils.MustGetStrEnv("SESSION_SECRET"),
+		PreviousSecrets: utils.GetStrSliceEnv("SESSION_PREVIOUS_SECRETS", nil),
+		CookieDomain:    utils.GetStrEnv("SESSION_COOKIE_DOMAIN", ""),
+		IdleTimeout:     utils.GetDurationEnv("SESSION_IDLE_TIMEOUT", 24*time.Hour),
+		AbsoluteTimeout: utils.GetDurationEnv("SESSION_ABSOLUTE_TIMEOUT", 30*24*time.Hour),
+		SlideInterval:   utils.GetDurationEnv("SESSION_SLIDE_INTERVAL", 5*time.Minute),
+		Store:           strings.ToLower(utils.GetStrEnv("SESSION_STORE", "redis")),
+		CleanupInterval: utils.GetDurationEnv("SESSION_STORE_CLEANUP_INTERVAL", 10*time.Minute),
+	}
+
+	if sessionConfig.IdleTimeout <= 0 || sessionConfig.IdleTimeout > sessionConfig.AbsoluteTimeout {
+		panic("SESSION_IDLE_TIMEOUT must be positive and not longer than SESSION_ABSOLUTE_TIMEOUT")
+	}
+	if sessionConfig.SlideInterval >= sessionConfig.IdleTimeout {
+		panic("SESSION_SLIDE_INTERVAL must be shorter than SESSION_IDLE_TIMEOUT")
+	}
+
+	switch sessionConfig.Store {
+	case "redis", "postgres", "memory":
+	default:
+		panic("unsupported SESSION_STORE: " + sessionConfig.Store)
+	}
+
+	switch sameSite := utils.GetStrEnv("SESSION_COOKIE_SAMESITE", "strict"); strings.ToLower(sameSite) {
+	case "strict":
+		sessionConfig.CookieSameSite = http.SameSiteStrictMode
+	case "lax":
+		sessionConfig.CookieSameSite = http.SameSiteLaxMode
+	case "none":
+		sessionConfig.CookieSameSite = http.SameSiteNoneMode
+	default:
+		panic("unsupported SESSION_COOKIE_SAMESITE: " + sameSite)
+	}
+
+	return sessionConfig
+}
+
+func newEmailVerificationConfig() *EmailVerificationConfig {
+	return &EmailVerificationConfig{
+		Secret: utils.MustGetStrEnv("EMAIL_VERIFICATION_SECRET"),
+		TTL:    utils.GetDurationEnv("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+	}
+}
+
+func newWebAuthnConfig() *WebAuthnConfig {
+	return &WebAuthnConfig{
+		RPID:          utils.MustGetStrEnv("WEBAUTHN_RP_ID"),
+		RPDisplayName: utils.MustGetStrEnv("WEBAUTHN_RP_DISPLAY_NAME"),
+		RPOrigins:     utils.GetStrSliceEnv("WEBAUTHN_RP_ORIGINS", nil),
+	}
+}
+
+// newOIDCConfig reads OIDC_PROVIDERS (e.g. "google,microsoft") and the
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and
+// optional OIDC_<NAME>_SCOPES variables of each listed provider
+func newOIDCConfig() *OIDCConfig {
+	providers := make(map[string]*OIDCProviderConfig)
+	for _, name := range utils.GetStrSliceEnv("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers[strings.ToLower(name)] = &OIDCProviderConfig{
+			Issuer:       utils.MustGetStrEnv(prefix + "ISSUER"),
+			ClientID:     utils.MustGetStrEnv(prefix + "CLIENT_ID"),
+			ClientSecret: utils.MustGetStrEnv(prefix + "CLIENT_SECRET"),
+			Scopes:       utils.GetStrSliceEnv(prefix+"SCOPES", []string{"email", "profile"}),
+		}
+	}
+
+	return &OIDCConfig{Providers: providers}
+}
+
+func newJWTConfig() *JWTConfig {
+	jwtConfig := &JWTConfig{
+		Algorithm:       utils.GetStrEnv("JWT_ALGORITHM", "HS256"),
+		Issuer:          utils.MustGetStrEnv("JWT_ISSUER"),
+		AccessTokenTTL:  utils.GetDurationEnv("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: utils.GetDurationEnv("JWT_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	}
+
+	switch jwtConfig.Algorithm {
+	case "HS256":
+		jwtConfig.Secret = utils.MustGetStrEnv("JWT_SECRET")
+	case "EdDSA":
+		jwtConfig.PrivateKey = utils.MustGetStrEnv("JWT_PRIVATE_KEY")
+	default:
+		panic("unsupported JWT_ALGORITHM: " + jwtConfig.Algorithm)
+	}
+
+	return jwtConfig
+}
+
+func newLockoutConfig() *LockoutConfig {
+	return &LockoutConfig{
+		MaxAttemptsPerEmail: utils.GetIntEnv("LOCKOUT_MAX_ATTEMPTS_PER_EMAIL", 5),
+		MaxAttemptsPerIP:    utils.GetIntEnv("LOCKOUT_MAX_ATTEMPTS_PER_IP", 50),
+		Window:              utils.GetDurationEnv("LOCKOUT_WINDOW", 15*time.Minute),
+		BaseDuration:        utils.GetDurationEnv("LOCKOUT_BASE_DURATION", time.Minute),
+		MaxDuration:         utils.GetDurationEnv("LOCKOUT_MAX_DURATION", 24*time.Hour),
+		SprayThreshold:      utils.GetIntEnv("LOCKOUT_SPRAY_THRESHOLD", 20),
+	}
+}
+
+func newSessionLimitConfig() *SessionLimitConfig {
+	limitConfig := &SessionLimitConfig{
+		PerRole: map[string]int{
+			shared.RoleCustomer: utils.GetIntEnv("SESSION_LIMIT_USER", 0),
+			shared.RoleAdmin:    utils.GetIntEnv("SESSION_LIMIT_ADMIN", 0),
+			shared.RoleSubadmin: utils.GetIntEnv("SESSION_LIMIT_SUBADMIN", 0),
+		},
+		Policy: strings.ToLower(utils.GetStrEnv("SESSION_LIMIT_POLICY", SessionLimitEvict)),
+	}
+
+	switch limitConfig.Policy {
+	case SessionLimitEvict, SessionLimitReject:
+	default:
+		panic("unsupported SESSION_LIMIT_POLICY: " + limitConfig.Policy)
+	}
+
+	return limitConfig
+}
+
+func newPasswordHashConfig() *utils.PasswordHashParams {
+	params := &utils.PasswordHashParams{
+		Algorithm:         strings.ToLower(utils.GetStrEnv("PASSWORD_HASH_ALGORITHM", utils.PasswordHashArgon2id)),
+		BcryptCost:        utils.GetIntEnv("PASSWORD_BCRYPT_COST", bcrypt.DefaultCost),
+		Argon2Memory:      uint32(utils.GetIntEnv("PASSWORD_ARGON2_MEMORY", 64*1024)),
+		Argon2Iterations:  uint32(utils.GetIntEnv("PASSWORD_ARGON2_ITERATIONS", 3)),
+		Argon2Parallelism: uint8(utils.GetIntEnv("PASSWORD_ARGON2_PARALLELISM", 2)),
+	}
+
+	switch params.Algorithm {
+	case utils.PasswordHashBcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			panic("PASSWORD_BCRYPT_COST is out of range")
+		}
+	case utils.PasswordHashArgon2id:
+		if params.Argon2Memory < 8*uint32(params.Argon2Parallelism) || params.Argon2Iterations < 1 || params.Argon2Parallelism < 1 {
+			panic("PASSWORD_ARGON2_* parameters are out of range")
+		}
+	default:
+		panic("unsupported PASSWORD_HASH_ALGORITHM: " + params.Algorithm)
+	}
+
+	return params
+}
+
+func newPasswordHistoryConfig() int {
+	size := utils.GetIntEnv("PASSWORD_HISTORY_SIZE", 5)
+	if size < 0 {
+		panic("PASSWORD_HISTORY_SIZE must not be negative")
 	}
+	return size
 }
 
 func newAuthConfig() *AuthConfig {
 	return &AuthConfig{
-		Session: newSessionConfig(),
+		Session:           newSessionConfig(),
+		EmailVerification: newEmailVerificationConfig(),
+		WebAuthn:          newWebAuthnConfig(),
+		OIDC:              newOIDCConfig(),
+		JWT:               newJWTConfig(),
+		Lockout:           newLockoutConfig(),
+		SessionLimit:      newSessionLimitConfig(),
+		PasswordHash:      newPasswordHashConfig(),
+		PasswordHistory:   newPasswordHistoryConfig(),
 	}
 }
@@ -8,6 +8,7 @@ type Config struct {
 	Mail    *MailConfig
 	Object  *ObjectConfig
 	Queue   *QueueConfig
+	Auth    *AuthConfig
 }
 
 func Load() *Config {
@@ -19,5 +20,6 @@ func Load() *Config {
 		Mail:    newMailConfig(),
 		Object:  newObjectConfig(),
 		Queue:   newQueueConfig(),
+		Auth:    newAuthConfig(),
 	}
 }
@@ -12,6 +12,7 @@ type SMTPConfig struct {
 	Port     int
 	Username string
 	Password string
+	From     string
 }
 
 type EmailSendGridConfig struct {
@@ -24,6 +25,7 @@ func newSMTPConfig() *SMTPConfig {
 		Port:     utils.MustGetIntEnv("SMTP_PORT"),
 		Username: utils.MustGetStrEnv("SMTP_USERNAME"),
 		Password: utils.MustGetStrEnv("SMTP_PASSWORD"),
+		From:     utils.MustGetStrEnv("SMTP_FROM"),
 	}
 }
 
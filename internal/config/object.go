@@ -1,17 +1,39 @@
 package config
 
-import "go-echo-template/internal/shared/utils"
+import (
+	"strings"
+	"time"
+
+	"go-echo-template/internal/shared/utils"
+)
 
 type ObjectConfig struct {
-	S3 *S3Config
+	// Store keeps uploaded files, one of s3 or local. Only the config of the selected
+	// store is loaded, the other one is nil
+	Store string
+	S3    *S3Config
+	Local *LocalObjectConfig
+	// URLExpiry is how long signed URLs of private objects stay valid
+	URLExpiry time.Duration
 }
 
+// S3Config works with AWS and with S3 compatible servers like MinIO
 type S3Config struct {
 	Region    string
 	Bucket    string
 	AccessKey string
 	SecretKey string
 	Endpoint  string
+	// PublicURL serves objects from a public bucket or a CDN, objects get signed
+	// URLs when it is empty
+	PublicURL string
+}
+
+// LocalObjectConfig keeps objects in a directory served by the app itself
+type LocalObjectConfig struct {
+	Dir string
+	// URL is the base URL the directory is served under
+	URL string
 }
 
 func newS3Config() *S3Config {
@@ -21,11 +43,36 @@ func newS3Config() *S3Config {
 		AccessKey: utils.MustGetStrEnv("S3_ACCESS_KEY"),
 		SecretKey: utils.MustGetStrEnv("S3_SECRET_KEY"),
 		Endpoint:  utils.MustGetStrEnv("S3_ENDPOINT"),
+		PublicURL: strings.TrimSuffix(utils.GetStrEnv("S3_PUBLIC_URL", ""), "/"),
+	}
+}
+
+func newLocalObjectConfig() *LocalObjectConfig {
+	return &LocalObjectConfig{
+		Dir: utils.GetStrEnv("OBJECT_LOCAL_DIR", "./data/objects"),
+		URL: strings.TrimSuffix(utils.GetStrEnv("OBJECT_LOCAL_URL", "/api/media"), "/"),
 	}
 }
 
 func newObjectConfig() *ObjectConfig {
-	return &ObjectConfig{
-		S3: newS3Config(),
+	objectConfig := &ObjectConfig{
+		Store:     strings.ToLower(utils.GetStrEnv("OBJECT_STORE", "local")),
+		URLExpiry: utils.GetDurationEnv("OBJECT_URL_EXPIRY", time.Hour),
 	}
+
+	switch objectConfig.Store {
+	case "s3":
+		objectConfig.S3 = newS3Config()
+	case "local":
+		objectConfig.Local = newLocalObjectConfig()
+	default:
+		panic("unsupported OBJECT_STORE: " + objectConfig.Store)
+	}
+
+	// S3 doesn't sign URLs for longer than a week
+	if objectConfig.URLExpiry <= 0 || objectConfig.URLExpiry > 7*24*time.Hour {
+		panic("OBJECT_URL_EXPIRY must be positive and at most 7 days")
+	}
+
+	return objectConfig
 }
@@ -16,9 +16,16 @@ func (r *RedisConfig) Addr() string {
 	return r.Host + ":" + strconv.Itoa(r.Port)
 }
 
+// newRedisConfig returns nil when REDIS_HOST is not set, redis is optional
+// unless it's the session store
 func newRedisConfig() *RedisConfig {
+	host := utils.GetStrEnv("REDIS_HOST", "")
+	if host == "" {
+		return nil
+	}
+
 	return &RedisConfig{
-		Host:     utils.MustGetStrEnv("REDIS_HOST"),
+		Host:     host,
 		Port:     utils.MustGetIntEnv("REDIS_PORT"),
 		Password: utils.MustGetStrEnv("REDIS_PASSWORD"),
 		DB:       utils.MustGetIntEnv("REDIS_DB"),
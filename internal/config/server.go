@@ -14,6 +14,7 @@ type ServerConfig struct {
 	Environment    string
 	RequestTimeout time.Duration
 	LocalWebURL    string
+	BaseURL        string
 }
 
 func newServerConfig() *ServerConfig {
@@ -25,6 +26,7 @@ func newServerConfig() *ServerConfig {
 		Environment:    utils.MustGetStrEnv("ENVIRONMENT"),
 		RequestTimeout: utils.MustGetDurationEnv("REQUEST_TIMEOUT"),
 		LocalWebURL:    utils.MustGetStrEnv("LOCAL_WEB_URL"),
+		BaseURL:        utils.MustGetStrEnv("BASE_URL"),
 	}
 }
 
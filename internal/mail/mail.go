@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"go-echo-template/internal/shared/i18n"
+)
+
+// Message is a single plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails. Keep implementations swappable so tests can
+// capture outgoing messages instead of talking to a real SMTP server.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Template holds the localized subject and body of an email
+type Template struct {
+	Subject i18n.Messages
+	Body    i18n.Messages
+}
+
+// Render builds a Message for the given recipient and locale, body args are
+// applied with fmt.Sprintf the same way translated messages are
+func (t *Template) Render(to string, locale i18n.Locale, args ...any) *Message {
+	return &Message{
+		To:      to,
+		Subject: translate(t.Subject, locale),
+		Body:    translate(t.Body, locale, args...),
+	}
+}
+
+func translate(messages i18n.Messages, locale i18n.Locale, args ...any) string {
+	msg, ok := messages[locale]
+	if !ok {
+		// fallback locale
+		msg = messages[i18n.DefaultLocale]
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
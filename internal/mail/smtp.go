@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/config"
+)
+
+type smtpMailer struct {
+	config config.SMTPConfig
+}
+
+// Send delivers the message through the configured SMTP server
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+
+	var b strings.Builder
+	b.WriteString("From: " + m.config.From + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	// net/smtp is not context aware, run it in the background and
+	// give up waiting as soon as the context is done
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(addr, auth, m.config.From, []string{msg.To}, []byte(b.String()))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to send mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewSMTPMailer creates a Mailer backed by the SMTP configuration
+func NewSMTPMailer(cfg *config.SMTPConfig) Mailer {
+	return &smtpMailer{config: *cfg}
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"go-echo-template/internal/shared/query"
+)
+
+// AuditEventFilter narrows down the events, empty fields match everything
+type AuditEventFilter struct {
+	ActorID    *int64 `query:"actorId" validate:"omitempty,min=1"`
+	Action     string `query:"action" validate:"omitempty,max=64"`
+	TargetType string `query:"targetType" validate:"omitempty,max=64"`
+	TargetID   *int64 `query:"targetId" validate:"omitempty,min=1"`
+	// From is inclusive and To exclusive, both in RFC 3339
+	From *time.Time `query:"from"`
+	To   *time.Time `query:"to"`
+}
+
+// validateRange rejects empty and inverted time ranges
+func (f *AuditEventFilter) validateRange() error {
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return errInvalidTimeRange
+	}
+	return nil
+}
+
+type ListAuditEventsRequest struct {
+	AuditEventFilter
+}
+
+// listEventsQuery pages the events newest first, by page number or cursor
+var listEventsQuery = &query.Spec{Cursor: true}
+
+type ExportAuditEventsRequest struct {
+	AuditEventFilter
+}
+
+type AuditEventResponse struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actorId"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   *int64          `json:"targetId"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"userAgent"`
+	RequestID  string          `json:"requestId"`
+	Diff       json.RawMessage `json:"diff"`
+	CreatedAt  string          `json:"createdAt"`
+}
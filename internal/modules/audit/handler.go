@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"net/http"
+
+	"go-echo-template/internal/alarm"
+	"go-echo-template/internal/modules/auth"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/query"
+	"go-echo-template/internal/shared/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AuditHandler struct {
+	logger  log.CustomLogger
+	alarmer alarm.Alarmer
+
+	service auditService
+	auth    auth.AuthService
+}
+
+func NewAuditHandler(logger log.CustomLogger, alarmer alarm.Alarmer, service auditService, authService auth.AuthService) *AuditHandler {
+	return &AuditHandler{logger: logger, alarmer: alarmer, service: service, auth: authService}
+}
+
+func (h *AuditHandler) RegisterRoutes(e *echo.Group) {
+	events := e.Group("/v1/admin/audit-events", h.auth.CheckAuth(false), h.auth.RequirePermission(auth.PermAuditReadAny))
+	events.GET("", h.ListEvents)
+	events.GET("/export", h.ExportEvents)
+}
+
+func (h *AuditHandler) ListEvents(c echo.Context) error {
+	// validate input
+	ler := new(ListAuditEventsRequest)
+	if err := c.Bind(ler); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(ler); err != nil {
+		return err
+	}
+	if err := ler.validateRange(); err != nil {
+		return err
+	}
+	lq := query.New(listEventsQuery)
+	if err := lq.Bind(c); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(lq); err != nil {
+		return err
+	}
+
+	// service call
+	res, err := h.service.listEvents(c.Request().Context(), ler, lq)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(res.Items).WithPagination(lq, res.Total, res.NextCursor).Send()
+}
+
+func (h *AuditHandler) ExportEvents(c echo.Context) error {
+	// validate input
+	eer := new(ExportAuditEventsRequest)
+	if err := c.Bind(eer); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(eer); err != nil {
+		return err
+	}
+	if err := eer.validateRange(); err != nil {
+		return err
+	}
+
+	// build response, the CSV is streamed while the events are read
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-events.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	// service call
+	if err := h.service.exportEvents(c.Request().Context(), eer, c.Response()); err != nil {
+		// the status is already sent, the truncated file is all that can be done
+		h.logger.ErrorWithContext(c.Request().Context(), "failed to export audit events", h.logger.Err(err))
+	}
+	return nil
+}
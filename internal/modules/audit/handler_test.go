@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/response"
+	"go-echo-template/internal/storage"
+	storageAudit "go-echo-template/internal/storage/audit"
+	"go-echo-template/internal/storage/audit/sqlc"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuditRepo serves total generated events, newest first, and records the list params
+type fakeAuditRepo struct {
+	storageAudit.AuditRepository
+	total  int
+	params []sqlc.ListAuditEventsParams
+	// written is how many events are added after every read, as if written meanwhile
+	written int
+}
+
+func (r *fakeAuditRepo) ListAuditEvents(ctx context.Context, params sqlc.ListAuditEventsParams) ([]sqlc.AuditEvent, error) {
+	r.params = append(r.params, params)
+
+	start := int(params.PageOffset)
+	if params.BeforeID.Valid {
+		start += r.total - int(params.BeforeID.Int64) + 1
+	}
+
+	var events []sqlc.AuditEvent
+	for i := start; i < min(r.total, start+int(params.PageLimit)); i++ {
+		events = append(events, sqlc.AuditEvent{
+			ID:        int64(r.total - i),
+			ActorID:   sql.NullInt64{Int64: 1, Valid: true},
+			Action:    "auth.login",
+			UserAgent: "=HYPERLINK(\"http://example.com\")",
+			Diff:      json.RawMessage(`{}`),
+			CreatedAt: time.Now(),
+		})
+	}
+	r.total += r.written
+	return events, nil
+}
+
+func (r *fakeAuditRepo) CountAuditEvents(ctx context.Context, params sqlc.CountAuditEventsParams) (int64, error) {
+	return int64(r.total), nil
+}
+
+func newAuditTestHandler(t *testing.T, total int) (*AuditHandler, *fakeAuditRepo) {
+	t.Helper()
+
+	logger, err := log.NewCustomLogger(&config.ServerConfig{Environment: "local"})
+	require.NoError(t, err)
+
+	repo := &fakeAuditRepo{total: total}
+	svc := NewAuditService(logger, storage.NewStorage(nil, nil, nil, repo))
+	return NewAuditHandler(logger, nil, svc, nil), repo
+}
+
+func newAuditTestContext(target string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Validator = response.NewValidator()
+	rec := httptest.NewRecorder()
+	return e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec), rec
+}
+
+type listBody struct {
+	Data []AuditEventResponse `json:"data"`
+	Meta struct {
+		Page       int    `json:"page"`
+		Total      int64  `json:"total"`
+		TotalPages int    `json:"totalPages"`
+		NextCursor string `json:"nextCursor"`
+	} `json:"meta"`
+	Links struct {
+		Prev string `json:"prev"`
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+func decodeListBody(t *testing.T, rec *httptest.ResponseRecorder) *listBody {
+	t.Helper()
+
+	body := new(listBody)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), body))
+	return body
+}
+
+func TestAuditHandler(t *testing.T) {
+	t.Run("List Applies Filters And Pages", func(t *testing.T) {
+		h, repo := newAuditTestHandler(t, 25)
+
+		c, rec := newAuditTestContext("/?actorId=1&action=auth.login&from=2026-01-01T00:00:00Z&to=2026-02-01T00:00:00Z&page=2&limit=10")
+		require.NoError(t, h.ListEvents(c))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		params := repo.params[0]
+		require.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, params.ActorID)
+		require.Equal(t, sql.NullString{String: "auth.login", Valid: true}, params.Action)
+		require.False(t, params.TargetID.Valid)
+		require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), params.CreatedFrom.Time.UTC())
+		require.True(t, params.CreatedTo.Valid)
+		require.Equal(t, int32(11), params.PageLimit, "one extra event tells whether there is a next page")
+		require.Equal(t, int32(10), params.PageOffset)
+
+		body := decodeListBody(t, rec)
+		require.Len(t, body.Data, 10)
+		require.Equal(t, int64(25), body.Meta.Total)
+		require.Equal(t, 2, body.Meta.Page)
+		require.Equal(t, 3, body.Meta.TotalPages)
+		require.Contains(t, body.Links.Next, "page=3")
+		require.Contains(t, body.Links.Prev, "page=1")
+		require.Contains(t, body.Links.Next, "actorId=1", "filters are kept in links")
+	})
+
+	t.Run("List Continues From Cursor", func(t *testing.T) {
+		h, repo := newAuditTestHandler(t, 25)
+
+		c, rec := newAuditTestContext("/?limit=10")
+		require.NoError(t, h.ListEvents(c))
+		first := decodeListBody(t, rec)
+		require.NotEmpty(t, first.Meta.NextCursor)
+
+		c, rec = newAuditTestContext("/?limit=10&cursor=" + first.Meta.NextCursor)
+		require.NoError(t, h.ListEvents(c))
+		require.Equal(t, sql.NullInt64{Int64: 16, Valid: true}, repo.params[1].BeforeID)
+		require.Equal(t, int32(0), repo.params[1].PageOffset)
+
+		second := decodeListBody(t, rec)
+		require.Equal(t, int64(15), second.Data[0].ID)
+		require.Zero(t, second.Meta.Page, "cursor pages have no number")
+		require.Contains(t, second.Links.Next, "cursor=")
+
+		c, _ = newAuditTestContext("/?cursor=bogus")
+		require.Error(t, h.ListEvents(c))
+
+		c, _ = newAuditTestContext("/?page=2&cursor=" + first.Meta.NextCursor)
+		require.Error(t, h.ListEvents(c), "page and cursor are exclusive")
+	})
+
+	t.Run("Inverted Time Range Is Rejected", func(t *testing.T) {
+		h, _ := newAuditTestHandler(t, 0)
+
+		c, _ := newAuditTestContext("/?from=2026-02-01T00:00:00Z&to=2026-01-01T00:00:00Z")
+		require.ErrorIs(t, h.ExportEvents(c), errInvalidTimeRange)
+	})
+
+	t.Run("Export Streams Every Event As CSV", func(t *testing.T) {
+		h, repo := newAuditTestHandler(t, exportBatchSize+1)
+
+		c, rec := newAuditTestContext("/export")
+		require.NoError(t, h.ExportEvents(c))
+		require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		require.Len(t, repo.params, 2, "events are read in batches")
+
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, exportBatchSize+2, "a header and every event")
+		require.Equal(t, "user_agent", rows[0][7])
+		require.Equal(t, `'=HYPERLINK("http://example.com")`, rows[1][7], "formulas are neutralized")
+	})
+
+	t.Run("Export Is Not Shifted By New Events", func(t *testing.T) {
+		h, repo := newAuditTestHandler(t, exportBatchSize+1)
+		repo.written = 5
+
+		c, rec := newAuditTestContext("/export")
+		require.NoError(t, h.ExportEvents(c))
+		require.Len(t, repo.params, 2)
+		require.Zero(t, repo.params[1].PageOffset)
+		require.Equal(t, int64(2), repo.params[1].BeforeID.Int64, "the next batch starts below the last exported event")
+
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		seen := make(map[string]bool)
+		for _, row := range rows[1:] {
+			require.False(t, seen[row[0]], "event %s is exported twice", row[0])
+			seen[row[0]] = true
+		}
+		require.Len(t, seen, exportBatchSize+1)
+	})
+}
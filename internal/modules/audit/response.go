@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"net/http"
+
+	"go-echo-template/internal/shared/i18n"
+	"go-echo-template/internal/shared/response"
+)
+
+// Errors
+var (
+	errInvalidTimeRange = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUDIT_INVALID_TIME_RANGE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The start of the time range must be before its end",
+			i18n.TR_TR: "Zaman aralığının başlangıcı bitişinden önce olmalıdır",
+		},
+	}
+)
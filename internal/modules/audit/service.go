@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/query"
+	"go-echo-template/internal/storage"
+	"go-echo-template/internal/storage/audit/sqlc"
+)
+
+// exportBatchSize is how many events the CSV export reads per query
+const exportBatchSize = 1000
+
+type auditService interface {
+	listEvents(ctx context.Context, req *ListAuditEventsRequest, q *query.Query) (*query.Result[AuditEventResponse], error)
+	exportEvents(ctx context.Context, req *ExportAuditEventsRequest, w io.Writer) error
+}
+
+type service struct {
+	logger  log.CustomLogger
+	storage *storage.Storage
+}
+
+func NewAuditService(logger log.CustomLogger, storage *storage.Storage) auditService {
+	return &service{logger: logger, storage: storage}
+}
+
+func (s *service) listEvents(ctx context.Context, req *ListAuditEventsRequest, q *query.Query) (*query.Result[AuditEventResponse], error) {
+	limit := q.Limit()
+
+	// repo call, one extra event tells whether there is a next page
+	params := req.listParams(limit+1, q.Offset())
+	if _, id, ok := q.After(); ok {
+		params.BeforeID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	events, err := s.storage.Audit.ListAuditEvents(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.storage.Audit.CountAuditEvents(ctx, sqlc.CountAuditEventsParams{
+		ActorID:     params.ActorID,
+		Action:      params.Action,
+		TargetType:  params.TargetType,
+		TargetID:    params.TargetID,
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// build response
+	res := &query.Result[AuditEventResponse]{Total: total}
+	if len(events) > limit {
+		events = events[:limit]
+		res.NextCursor = q.NextCursor("", events[limit-1].ID)
+	}
+	res.Items = make([]AuditEventResponse, len(events))
+	for i, event := range events {
+		res.Items[i] = AuditEventResponse{
+			ID:         event.ID,
+			ActorID:    nullableID(event.ActorID),
+			Action:     event.Action,
+			TargetType: event.TargetType,
+			TargetID:   nullableID(event.TargetID),
+			IP:         event.Ip,
+			UserAgent:  event.UserAgent,
+			RequestID:  event.RequestID,
+			Diff:       event.Diff,
+			CreatedAt:  event.CreatedAt.Format(shared.DefaultDateFormat),
+		}
+	}
+	return res, nil
+}
+
+// exportEvents writes every matching event as CSV, newest first
+func (s *service) exportEvents(ctx context.Context, req *ExportAuditEventsRequest, w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"id", "created_at", "actor_id", "action", "target_type", "target_id", "ip", "user_agent", "request_id", "diff"}); err != nil {
+		return err
+	}
+
+	// keyset paging, events written during the export don't shift the batches
+	params := req.listParams(exportBatchSize, 0)
+	for {
+		events, err := s.storage.Audit.ListAuditEvents(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := out.Write([]string{
+				strconv.FormatInt(event.ID, 10),
+				event.CreatedAt.Format(shared.DefaultDateFormat),
+				nullableIDString(event.ActorID),
+				csvCell(event.Action),
+				csvCell(event.TargetType),
+				nullableIDString(event.TargetID),
+				csvCell(event.Ip),
+				csvCell(event.UserAgent),
+				csvCell(event.RequestID),
+				csvCell(string(event.Diff)),
+			}); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+
+		if len(events) < exportBatchSize {
+			return nil
+		}
+		params.BeforeID = sql.NullInt64{Int64: events[len(events)-1].ID, Valid: true}
+	}
+}
+
+// listParams converts the filter to query params, unset filters become NULL
+func (f *AuditEventFilter) listParams(limit, offset int) sqlc.ListAuditEventsParams {
+	params := sqlc.ListAuditEventsParams{
+		Action:     sql.NullString{String: f.Action, Valid: f.Action != ""},
+		TargetType: sql.NullString{String: f.TargetType, Valid: f.TargetType != ""},
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
+	}
+	if f.ActorID != nil {
+		params.ActorID = sql.NullInt64{Int64: *f.ActorID, Valid: true}
+	}
+	if f.TargetID != nil {
+		params.TargetID = sql.NullInt64{Int64: *f.TargetID, Valid: true}
+	}
+	if f.From != nil {
+		params.CreatedFrom = sql.NullTime{Time: *f.From, Valid: true}
+	}
+	if f.To != nil {
+		params.CreatedTo = sql.NullTime{Time: *f.To, Valid: true}
+	}
+	return params
+}
+
+func nullableID(id sql.NullInt64) *int64 {
+	if !id.Valid {
+		return nil
+	}
+	return &id.Int64
+}
+
+func nullableIDString(id sql.NullInt64) string {
+	if !id.Valid {
+		return ""
+	}
+	return strconv.FormatInt(id.Int64, 10)
+}
+
+// csvCell keeps spreadsheet apps from evaluating user controlled values, like the
+// user agent, as formulas
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
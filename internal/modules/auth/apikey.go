@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/utils"
+	"go-echo-template/internal/storage"
+	"go-echo-template/internal/storage/auth/sqlc"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// APIKeyPrefix marks personal access tokens, "pat_<64 hex chars>"
+	APIKeyPrefix = "pat_"
+	// apiKeyDisplayLength is how much of a token is stored in clear to tell keys apart
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+)
+
+// checkAPIKey resolves a personal access token into the user it belongs to, limited to the scopes of the key
+func (s *service) checkAPIKey(c echo.Context, token string) (*User, error) {
+	ctx := c.Request().Context()
+
+	apiKey, err := s.storage.Auth.GetApiKeyBySecretHash(ctx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errAPIKeyInvalid
+		}
+		return nil, err
+	}
+	if apiKey.ExpiresAt.Valid && !apiKey.ExpiresAt.Time.After(time.Now()) {
+		return nil, errAPIKeyExpired
+	}
+
+	// deleted users keep their rows until the cascade, their keys must stop working right away
+	userRow, err := s.storage.Auth.GetUserById(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errAPIKeyInvalid
+		}
+		return nil, err
+	}
+
+	if !apiKey.LastUsedAt.Valid || time.Since(apiKey.LastUsedAt.Time) >= SessionTouchInterval {
+		if err := s.storage.Auth.UpdateApiKeyLastUsed(ctx, apiKey.ID); err != nil {
+			s.logger.WarnWithContext(ctx, "failed to update api key usage", s.logger.Err(err))
+		}
+	}
+
+	return &User{
+		ID:        userRow.ID,
+		Name:      userRow.Name,
+		Email:     userRow.Email,
+		Phone:     userRow.Phone.String,
+		Role:      userRow.Role,
+		CreatedAt: userRow.CreatedAt,
+		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+		APIKeyID:      apiKey.ID,
+		Scopes:        parseScopes(apiKey.Scopes),
+	}, nil
+}
+
+// apiKeyOwner returns the user managing their keys, which needs an interactive session so
+// that a leaked token can't mint new ones
+func apiKeyOwner(c echo.Context) (*User, error) {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return nil, shared.ErrSessionUnauthorized
+	}
+	if user.APIKeyID != 0 {
+		return nil, errAPIKeyManagement
+	}
+	return user, nil
+}
+
+// RefuseAPIKey guards the endpoints managing credentials and sessions, they need an
+// interactive login so that a leaked token can't be turned into one
+func (s *service) RefuseAPIKey() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user, ok := GetUserFromContext(c); ok && user.APIKeyID != 0 {
+				return errAPIKeyForbidden
+			}
+			return next(c)
+		}
+	}
+}
+
+func (s *service) apiCreateAPIKey(c echo.Context, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	user, err := apiKeyOwner(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// a key never grants more than the role of its user
+	scopes := make([]Permission, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		permission := Permission(scope)
+		if !HasPermission(user.Role, permission) {
+			return nil, errAPIKeyScopeInvalid
+		}
+		if !slices.Contains(scopes, permission) {
+			scopes = append(scopes, permission)
+		}
+	}
+	slices.Sort(scopes)
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errAPIKeyGenerate
+	}
+	token := APIKeyPrefix + secret
+
+	var expiresAt sql.NullTime
+	if req.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, req.ExpiresInDays), Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	var apiKey *sqlc.ApiKey
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		apiKey, err = storageTx.Auth.CreateApiKey(ctx, sqlc.CreateApiKeyParams{
+			UserID:     user.ID,
+			Name:       req.Name,
+			Prefix:     token[:apiKeyDisplayLength],
+			SecretHash: utils.HashToken(token),
+			Scopes:     formatScopes(scopes),
+			ExpiresAt:  expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionAPIKeyCreate,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Diff:       audit.Diff(nil, map[string]any{"apiKeyId": apiKey.ID, "scopes": apiKey.Scopes}),
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return &CreateAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(apiKey), Token: token}, nil
+}
+
+func (s *service) apiListAPIKeys(c echo.Context) ([]APIKeyResponse, error) {
+	user, err := apiKeyOwner(c)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.storage.Auth.ListApiKeys(c.Request().Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := make([]APIKeyResponse, len(rows))
+	for i := range rows {
+		apiKeys[i] = toAPIKeyResponse(&rows[i])
+	}
+	return apiKeys, nil
+}
+
+func (s *service) apiDeleteAPIKey(c echo.Context, req *DeleteAPIKeyRequest) error {
+	user, err := apiKeyOwner(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	return s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		affected, err := storageTx.Auth.DeleteApiKey(ctx, sqlc.DeleteApiKeyParams{
+			ID:     req.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return errAPIKeyNotFound
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionAPIKeyRevoke,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Diff:       audit.Diff(map[string]any{"apiKeyId": req.ID}, nil),
+		})
+	})
+}
+
+func toAPIKeyResponse(row *sqlc.ApiKey) APIKeyResponse {
+	apiKey := APIKeyResponse{
+		ID:        row.ID,
+		Name:      row.Name,
+		Prefix:    row.Prefix,
+		Scopes:    parseScopes(row.Scopes),
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		apiKey.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		apiKey.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return apiKey
+}
+
+// parseScopes reads the comma separated scopes column
+func parseScopes(scopes string) []Permission {
+	var permissions []Permission
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			permissions = append(permissions, Permission(scope))
+		}
+	}
+	return permissions
+}
+
+func formatScopes(scopes []Permission) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/response"
+	"go-echo-template/internal/shared/utils"
+	storageAuth "go-echo-template/internal/storage/auth"
+	authSqlc "go-echo-template/internal/storage/auth/sqlc"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIKeyRepo keeps api keys in memory next to the users of fakeAuthRepo
+type fakeAPIKeyRepo struct {
+	*fakeAuthRepo
+	keys map[int64]*authSqlc.ApiKey
+}
+
+func (r *fakeAPIKeyRepo) WithTx(tx *sql.Tx) storageAuth.AuthRepository {
+	return r
+}
+
+func (r *fakeAPIKeyRepo) CreateApiKey(ctx context.Context, params authSqlc.CreateApiKeyParams) (*authSqlc.ApiKey, error) {
+	apiKey := &authSqlc.ApiKey{
+		ID:         int64(len(r.keys) + 1),
+		UserID:     params.UserID,
+		Name:       params.Name,
+		Prefix:     params.Prefix,
+		SecretHash: params.SecretHash,
+		Scopes:     params.Scopes,
+		ExpiresAt:  params.ExpiresAt,
+		CreatedAt:  time.Now(),
+	}
+	r.keys[apiKey.ID] = apiKey
+	return apiKey, nil
+}
+
+func (r *fakeAPIKeyRepo) ListApiKeys(ctx context.Context, userID int64) ([]authSqlc.ApiKey, error) {
+	var keys []authSqlc.ApiKey
+	for _, apiKey := range r.keys {
+		if apiKey.UserID == userID {
+			keys = append(keys, *apiKey)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeAPIKeyRepo) GetApiKeyBySecretHash(ctx context.Context, secretHash string) (*authSqlc.ApiKey, error) {
+	for _, apiKey := range r.keys {
+		if apiKey.SecretHash == secretHash {
+			found := *apiKey
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeAPIKeyRepo) UpdateApiKeyLastUsed(ctx context.Context, id int64) error {
+	r.keys[id].LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (r *fakeAPIKeyRepo) DeleteApiKey(ctx context.Context, params authSqlc.DeleteApiKeyParams) (int64, error) {
+	apiKey, ok := r.keys[params.ID]
+	if !ok || apiKey.UserID != params.UserID {
+		return 0, nil
+	}
+	delete(r.keys, params.ID)
+	return 1, nil
+}
+
+func newAPIKeyTestService(t *testing.T) (*service, *fakeAPIKeyRepo) {
+	t.Helper()
+
+	svc, _, _, _ := newPasswordResetTestService(t)
+	repo := &fakeAPIKeyRepo{fakeAuthRepo: svc.storage.Auth.(*fakeAuthRepo), keys: map[int64]*authSqlc.ApiKey{}}
+	svc.storage.Auth = repo
+	return svc, repo
+}
+
+func TestAPIKeys(t *testing.T) {
+	jane := &User{ID: 42, Name: "Jane", Email: "jane@example.com", Role: shared.RoleCustomer}
+
+	createKey := func(t *testing.T, svc *service, req *CreateAPIKeyRequest) *CreateAPIKeyResponse {
+		t.Helper()
+		resData, err := svc.apiCreateAPIKey(newUserContext(jane), req)
+		require.NoError(t, err)
+		return resData
+	}
+
+	t.Run("Token Resolves User", func(t *testing.T) {
+		svc, repo := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersReadSelf)}})
+
+		require.True(t, strings.HasPrefix(created.Token, APIKeyPrefix))
+		require.True(t, strings.HasPrefix(created.Token, created.Prefix))
+		require.Equal(t, utils.HashToken(created.Token), repo.keys[created.ID].SecretHash, "only the hash is stored")
+
+		var seen *User
+		c := newBearerContext(created.Token)
+		err := svc.CheckAuth(false)(func(c echo.Context) error {
+			seen, _ = GetUserFromContext(c)
+			return nil
+		})(c)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), seen.ID)
+		require.Equal(t, "jane@example.com", seen.Email)
+		require.Equal(t, created.ID, seen.APIKeyID)
+		require.True(t, repo.keys[created.ID].LastUsedAt.Valid)
+	})
+
+	t.Run("Scopes Limit Permissions", func(t *testing.T) {
+		svc, _ := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "read only", Scopes: []string{string(PermUsersReadSelf)}})
+
+		user, err := svc.Check(newBearerContext(created.Token))
+		require.NoError(t, err)
+		require.True(t, user.Can(PermUsersReadSelf))
+		require.False(t, user.Can(PermUsersUpdateSelf), "granted by the role but not by the key")
+
+		next := func(c echo.Context) error { return nil }
+		require.ErrorIs(t, svc.RequirePermission(PermUsersDeleteSelf)(next)(newBearerContext(created.Token)), shared.ErrForbidden)
+	})
+
+	t.Run("Scopes Beyond The Role Are Rejected", func(t *testing.T) {
+		svc, _ := newAPIKeyTestService(t)
+
+		_, err := svc.apiCreateAPIKey(newUserContext(jane), &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersDeleteAny)}})
+		require.ErrorIs(t, err, errAPIKeyScopeInvalid)
+		_, err = svc.apiCreateAPIKey(newUserContext(jane), &CreateAPIKeyRequest{Name: "ci", Scopes: []string{"users:launch:any"}})
+		require.ErrorIs(t, err, errAPIKeyScopeInvalid)
+	})
+
+	t.Run("Invalid And Expired Tokens", func(t *testing.T) {
+		svc, repo := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersReadSelf)}, ExpiresInDays: 30})
+		require.NotNil(t, created.ExpiresAt)
+
+		_, err := svc.Check(newBearerContext(created.Token + "0"))
+		require.ErrorIs(t, err, errAPIKeyInvalid)
+
+		repo.keys[created.ID].ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true}
+		_, err = svc.Check(newBearerContext(created.Token))
+		require.ErrorIs(t, err, errAPIKeyExpired)
+	})
+
+	t.Run("Deleted Keys Stop Working", func(t *testing.T) {
+		svc, _ := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersReadSelf)}})
+
+		other := newUserContext(&User{ID: 7, Role: shared.RoleCustomer})
+		require.ErrorIs(t, svc.apiDeleteAPIKey(other, &DeleteAPIKeyRequest{ID: created.ID}), errAPIKeyNotFound)
+
+		keys, err := svc.apiListAPIKeys(newUserContext(jane))
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+
+		require.NoError(t, svc.apiDeleteAPIKey(newUserContext(jane), &DeleteAPIKeyRequest{ID: created.ID}))
+		_, err = svc.Check(newBearerContext(created.Token))
+		require.ErrorIs(t, err, errAPIKeyInvalid)
+
+		actions := svc.storage.Audit.(*fakeAuditRepo).actions()
+		require.Equal(t, []string{"auth.api_key_create", "auth.api_key_revoke"}, actions, "the failed delete isn't audited")
+	})
+
+	t.Run("Tokens Cannot Manage Keys", func(t *testing.T) {
+		svc, _ := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersReadSelf)}})
+
+		user, err := svc.Check(newBearerContext(created.Token))
+		require.NoError(t, err)
+		_, err = svc.apiCreateAPIKey(newUserContext(user), &CreateAPIKeyRequest{Name: "escalate", Scopes: []string{string(PermUsersDeleteSelf)}})
+		require.ErrorIs(t, err, errAPIKeyManagement)
+	})
+
+	t.Run("Tokens Cannot Manage Credentials Or Sessions", func(t *testing.T) {
+		svc, _ := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersReadSelf)}})
+
+		e := echo.New()
+		e.Validator = response.NewValidator()
+		e.HTTPErrorHandler = response.CustomHTTPErrorHandler
+		NewAuthHandler(svc.logger, svc.alarmer, svc).RegisterRoutes(e.Group("/api"))
+
+		for _, route := range []struct{ method, path string }{
+			{http.MethodPost, "/api/v1/auth/webauthn/register/begin"},
+			{http.MethodPost, "/api/v1/auth/webauthn/register/finish"},
+			{http.MethodGet, "/api/v1/auth/webauthn/credentials"},
+			{http.MethodPost, "/api/v1/auth/2fa/totp/enroll"},
+			{http.MethodPost, "/api/v1/auth/2fa/totp/confirm"},
+			{http.MethodPost, "/api/v1/auth/2fa/totp/disable"},
+			{http.MethodGet, "/api/v1/auth/sessions"},
+			{http.MethodDelete, "/api/v1/auth/sessions"},
+			{http.MethodDelete, "/api/v1/auth/sessions/abc"},
+			{http.MethodPost, "/api/v1/auth/verify-email/resend"},
+			{http.MethodPost, "/api/v1/auth/api-keys"},
+		} {
+			req := httptest.NewRequest(route.method, route.path, strings.NewReader("{}"))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+created.Token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusForbidden, rec.Code, route.path)
+			require.Contains(t, rec.Body.String(), errAPIKeyForbidden.Code, route.path)
+		}
+	})
+
+	t.Run("Cookie Service Accepts Tokens Without CSRF", func(t *testing.T) {
+		svc, _ := newAPIKeyTestService(t)
+		created := createKey(t, svc, &CreateAPIKeyRequest{Name: "ci", Scopes: []string{string(PermUsersReadSelf)}})
+
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+created.Token)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		handler := svc.CSRF()(svc.CheckAuth(false)(func(c echo.Context) error { return nil }))
+		require.NoError(t, handler(c))
+	})
+}
@@ -0,0 +1,374 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/utils"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sessionIDContextKey holds the session ID issued during the request, it replaces the
+// ID of the request cookie once the session was started or rotated
+const sessionIDContextKey shared.ContextKey = "session_id"
+
+// cookieSessions keeps the user in the session store behind a random session ID sent as an HttpOnly
+// cookie. The cookie carries "<ID>.<HMAC of the ID>" and the store only knows the hash of the ID, so
+// that read access to the store doesn't reveal usable cookie values
+type cookieSessions struct {
+	cfg        *config.ServerConfig
+	sessionCfg *config.SessionConfig
+	store      storageSession.SessionStore
+	logger     log.CustomLogger
+}
+
+// cookieSession is what the store keeps under the key of a cookie session
+type cookieSession struct {
+	User       *User     `json:"user"`
+	RememberMe bool      `json:"rememberMe"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// when activity last moved the idle expiry along
+	RefreshedAt time.Time `json:"refreshedAt"`
+}
+
+// Login sets the session in cookie and store, a session presented by the request is
+// dropped first so that a planted session ID never becomes authenticated
+func (m *cookieSessions) Login(c echo.Context, user *User) error {
+	ctx := c.Request().Context()
+	if sessionID, err := m.sessionID(c); err == nil {
+		m.remove(ctx, sessionKey(sessionID))
+	}
+
+	// Generate a secure session ID
+	sessionID, err := m.generateSessionID()
+	if err != nil {
+		return errSessionGenID
+	}
+
+	key := sessionKey(sessionID)
+	now := time.Now()
+	session := &cookieSession{
+		User:        user,
+		RememberMe:  rememberMe(c),
+		CreatedAt:   now,
+		RefreshedAt: now,
+	}
+
+	infoJSON, err := newSessionInfo(c, key)
+	if err != nil {
+		return err
+	}
+
+	if err := m.save(ctx, key, session); err != nil {
+		return err
+	}
+	if err := m.store.HSet(ctx, userSessionsKey(sessionOwnerID(user)), publicSessionID(key), string(infoJSON), m.ttl(session)); err != nil {
+		return errSessionStore
+	}
+
+	return m.issue(c, sessionID, session)
+}
+
+func (m *cookieSessions) Logout(c echo.Context) error {
+	sessionID, err := m.sessionID(c)
+	switch err {
+	case errSessionCookieNotFound, errEmptySessionID:
+		// If the session cookie does not exist, nothing to do, just return
+		return nil
+	case nil:
+		m.remove(c.Request().Context(), sessionKey(sessionID))
+	default:
+		// cookies with an invalid signature are cleared as well
+	}
+
+	c.SetCookie(m.cookie(SessionCookieName, "", -1, true))
+	c.SetCookie(m.cookie(CSRFCookieName, "", -1, false))
+	return nil
+}
+
+// Refresh extends the session up to its absolute timeout, a user whose role or second
+// factor changed gets a new session ID
+func (m *cookieSessions) Refresh(c echo.Context, user *User) error {
+	sessionID, err := m.sessionID(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	key := sessionKey(sessionID)
+	current, err := m.load(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	updated := *current
+	if user != nil {
+		updated.User = user
+	}
+	updated.RefreshedAt = time.Now()
+
+	if privilegeChanged(current.User, updated.User) {
+		return m.rotate(c, key, &updated)
+	}
+
+	infoJSON, err := newSessionInfo(c, key)
+	if err != nil {
+		return err
+	}
+
+	if err := m.save(ctx, key, &updated); err != nil {
+		return err
+	}
+	// only re-added when the index expired, the original creation time is kept otherwise
+	if _, err := m.store.HSetNX(ctx, userSessionsKey(sessionOwnerID(updated.User)), publicSessionID(key), string(infoJSON), m.ttl(&updated)); err != nil {
+		return errSessionStore
+	}
+
+	return m.issue(c, sessionID, &updated)
+}
+
+// Check resolves the session of the cookie, activity slides the idle expiry along at most
+// once per SlideInterval so that a busy session doesn't write to the store on every request
+func (m *cookieSessions) Check(c echo.Context) (*User, error) {
+	sessionID, err := m.sessionID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := c.Request().Context()
+	key := sessionKey(sessionID)
+	session, err := m.load(ctx, key)
+	if err != nil {
+		if errors.Is(err, errSessionNotFound) && sessionEvicted(ctx, m.store, key) {
+			return nil, errSessionEvicted
+		}
+		return nil, err
+	}
+
+	if time.Since(session.RefreshedAt) >= m.sessionCfg.SlideInterval {
+		session.RefreshedAt = time.Now()
+		if err := m.save(ctx, key, session); err != nil {
+			m.logger.WarnWithContext(ctx, "failed to extend session", m.logger.Err(err))
+		} else if err := m.store.Extend(ctx, userSessionsKey(sessionOwnerID(session.User)), m.ttl(session)); err != nil {
+			m.logger.WarnWithContext(ctx, "failed to extend session index", m.logger.Err(err))
+		}
+	}
+
+	// cookies signed with a previous secret are moved to the current one
+	if cookie, err := c.Cookie(SessionCookieName); err == nil && cookie.Value != m.sign(sessionID) {
+		c.SetCookie(m.cookie(SessionCookieName, m.sign(sessionID), m.maxAge(session), true))
+	}
+	return session.User, nil
+}
+
+// rotate moves the session to a new ID, the ID known before a privilege change
+// can't be used to ride on the elevated session
+func (m *cookieSessions) rotate(c echo.Context, oldKey string, session *cookieSession) error {
+	sessionID, err := m.generateSessionID()
+	if err != nil {
+		return errSessionGenID
+	}
+
+	ctx := c.Request().Context()
+	key := sessionKey(sessionID)
+	indexKey := userSessionsKey(sessionOwnerID(session.User))
+
+	info := new(sessionInfo)
+	if oldJSON, err := m.store.HGet(ctx, indexKey, publicSessionID(oldKey)); err == nil {
+		if err := json.Unmarshal([]byte(oldJSON), info); err != nil {
+			m.logger.WarnWithContext(ctx, "failed to read session info", m.logger.Err(err))
+		}
+	}
+	// the session stays on the same device, only a missing entry is described anew
+	now := time.Now()
+	if info.CreatedAt.IsZero() {
+		info.CreatedAt = now
+		info.UserAgent = c.Request().UserAgent()
+	}
+	info.Key = key
+	info.LastSeenAt = now
+	info.IP = c.RealIP()
+	infoJSON, err := json.Marshal(info)
+	if err != nil {
+		return errSessionSerialize
+	}
+
+	if err := m.save(ctx, key, session); err != nil {
+		return err
+	}
+	if err := m.store.HSet(ctx, indexKey, publicSessionID(key), string(infoJSON), m.ttl(session)); err != nil {
+		return errSessionStore
+	}
+	m.remove(ctx, oldKey)
+
+	return m.issue(c, sessionID, session)
+}
+
+// load reads a session, sessions past their absolute timeout are removed
+func (m *cookieSessions) load(ctx context.Context, key string) (*cookieSession, error) {
+	sessionJSON, err := m.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storageSession.ErrNotFound) {
+			return nil, errSessionNotFound
+		}
+		return nil, errSessionCheckExist
+	}
+
+	session := new(cookieSession)
+	if err := json.Unmarshal([]byte(sessionJSON), session); err != nil || session.User == nil {
+		return nil, errSessionDeserialize
+	}
+
+	if m.ttl(session) <= 0 {
+		m.remove(ctx, key)
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+// save stores the session until it idles out, never past its absolute timeout
+func (m *cookieSessions) save(ctx context.Context, key string, session *cookieSession) error {
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return errSessionSerialize
+	}
+	if err := m.store.Set(ctx, key, string(sessionJSON), m.ttl(session)); err != nil {
+		return errSessionStore
+	}
+	return nil
+}
+
+// ttl returns how long the session lives without further activity
+func (m *cookieSessions) ttl(session *cookieSession) time.Duration {
+	remaining := time.Until(session.CreatedAt.Add(m.sessionCfg.AbsoluteTimeout))
+	return min(m.sessionCfg.IdleTimeout, remaining)
+}
+
+// maxAge keeps remembered sessions across browser restarts until their absolute timeout,
+// the cookies of other sessions end with the browser session
+func (m *cookieSessions) maxAge(session *cookieSession) int {
+	if !session.RememberMe {
+		return 0
+	}
+	return int(time.Until(session.CreatedAt.Add(m.sessionCfg.AbsoluteTimeout)).Seconds())
+}
+
+// remove deletes a session and its entry in the index of its user
+func (m *cookieSessions) remove(ctx context.Context, key string) {
+	// Look up the owner first so the session can be removed from the user index
+	if sessionJSON, err := m.store.Get(ctx, key); err == nil {
+		var session cookieSession
+		if err := json.Unmarshal([]byte(sessionJSON), &session); err == nil && session.User != nil {
+			if err := m.store.HDel(ctx, userSessionsKey(sessionOwnerID(session.User)), publicSessionID(key)); err != nil {
+				m.logger.WarnWithContext(ctx, "failed to remove session from user index", m.logger.Err(err))
+			}
+		}
+	}
+
+	if _, err := m.store.Del(ctx, key); err != nil {
+		m.logger.WarnWithContext(ctx, "failed to delete session", m.logger.Err(err))
+	}
+}
+
+// issue sets the signed session cookie and a CSRF token bound to the session
+func (m *cookieSessions) issue(c echo.Context, sessionID string, session *cookieSession) error {
+	c.Set(string(sessionIDContextKey), sessionID)
+	c.SetCookie(m.cookie(SessionCookieName, m.sign(sessionID), m.maxAge(session), true))
+	_, err := m.issueCSRFToken(c, sessionID)
+	return err
+}
+
+// sessionID returns the session ID issued during the request, or the one of the
+// session cookie once its signature has been verified
+func (m *cookieSessions) sessionID(c echo.Context) (string, error) {
+	if sessionID, ok := c.Get(string(sessionIDContextKey)).(string); ok && sessionID != "" {
+		return sessionID, nil
+	}
+
+	cookie, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return "", errSessionCookieNotFound
+	}
+	if cookie.Value == "" {
+		return "", errEmptySessionID
+	}
+
+	sessionID, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || sessionID == "" || !m.verify("session:"+sessionID, signature) {
+		return "", errSessionInvalidID
+	}
+	return sessionID, nil
+}
+
+// sign returns the cookie value of a session ID, signed with the current secret
+func (m *cookieSessions) sign(sessionID string) string {
+	return sessionID + "." + utils.Sign(m.sessionCfg.SESSION_SECRET, "session:"+sessionID)
+}
+
+// verify accepts signatures of the current and of every previous secret, so that
+// rotating SESSION_SECRET doesn't log everyone out
+func (m *cookieSessions) verify(message, signature string) bool {
+	if utils.VerifySignature(m.sessionCfg.SESSION_SECRET, message, signature) {
+		return true
+	}
+	for _, secret := range m.sessionCfg.PreviousSecrets {
+		if utils.VerifySignature(secret, message, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// cookie builds the session and CSRF cookies with the configured domain and SameSite mode,
+// browsers only accept SameSite=None on secure cookies
+func (m *cookieSessions) cookie(name, value string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   m.sessionCfg.CookieDomain,
+		MaxAge:   maxAge,
+		HttpOnly: httpOnly,
+		Secure:   m.cfg.IsProduction() || m.sessionCfg.CookieSameSite == http.SameSiteNoneMode,
+		SameSite: m.sessionCfg.CookieSameSite,
+	}
+}
+
+func (m *cookieSessions) currentKey(c echo.Context) (string, bool) {
+	sessionID, err := m.sessionID(c)
+	if err != nil {
+		return "", false
+	}
+	return sessionKey(sessionID), true
+}
+
+// generateSessionID creates a cryptographically secure random session ID
+func (m *cookieSessions) generateSessionID() (string, error) {
+	bytes := make([]byte, 32) // 256 bits
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", errSessionGenID
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// sessionKey returns the store key of a session, derived from the hash of its ID
+func sessionKey(sessionID string) string {
+	return SessionKeyPrefix + utils.HashToken(sessionID)
+}
+
+// privilegeChanged reports whether the session user gained or lost privileges
+func privilegeChanged(current, updated *User) bool {
+	return current.Role != updated.Role || current.SecondFactor != updated.SecondFactor
+}
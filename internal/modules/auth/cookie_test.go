@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/shared"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// cookieSessionKey returns the store key of the session carried by a session cookie
+func cookieSessionKey(cookie *http.Cookie) string {
+	sessionID, _, _ := strings.Cut(cookie.Value, ".")
+	return sessionKey(sessionID)
+}
+
+func TestSessionCookies(t *testing.T) {
+	user := &User{ID: 42, Email: "jane@example.com", Role: shared.RoleCustomer}
+
+	t.Run("Store Never Sees The Cookie Value", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, user, "laptop")
+
+		sessionID, signature, ok := strings.Cut(cookie.Value, ".")
+		require.True(t, ok, "the cookie carries a signed ID")
+		require.NotEmpty(t, signature)
+		require.True(t, store.has(cookieSessionKey(cookie)))
+
+		for _, key := range store.Keys() {
+			require.NotContains(t, key, sessionID)
+			fields, err := store.HGetAll(context.Background(), key)
+			require.NoError(t, err)
+			for _, value := range fields {
+				require.NotContains(t, value, sessionID)
+			}
+		}
+	})
+
+	t.Run("Tampered Cookies Are Rejected", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, user, "laptop")
+		sessionID, _, _ := strings.Cut(cookie.Value, ".")
+
+		for _, value := range []string{sessionID, sessionID + ".forged", "." + strings.Repeat("0", 64)} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: value})
+			_, err := svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+			require.ErrorIs(t, err, errSessionInvalidID)
+		}
+	})
+
+	t.Run("Previous Secrets Keep Sessions Alive", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, user, "laptop")
+		csrf := loginCookies(t, svc, user)
+
+		svc.authCfg.Session.PreviousSecrets = []string{svc.authCfg.Session.SESSION_SECRET}
+		svc.authCfg.Session.SESSION_SECRET = "rotated-session-secret"
+
+		c, rec := newCookieContext(t, svc, cookie)
+		resigned := rec.Result().Cookies()
+		require.Len(t, resigned, 1, "the cookie is moved to the current secret")
+		require.NotEqual(t, cookie.Value, resigned[0].Value)
+		require.Equal(t, cookieSessionKey(cookie), cookieSessionKey(resigned[0]), "the session itself is kept")
+		_, ok := GetUserFromContext(c)
+		require.True(t, ok)
+
+		require.NoError(t, runCSRF(svc, http.MethodPost, csrf[CSRFCookieName].Value, csrf[SessionCookieName], csrf[CSRFCookieName]))
+
+		svc.authCfg.Session.PreviousSecrets = nil
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, err := svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.ErrorIs(t, err, errSessionInvalidID, "retired secrets are no longer accepted")
+	})
+
+	t.Run("Login Drops The Presented Session", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		planted := loginCookie(t, svc, &User{ID: 7}, "attacker")
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.AddCookie(planted)
+		rec := httptest.NewRecorder()
+		require.NoError(t, svc.Login(echo.New().NewContext(req, rec), user))
+
+		require.False(t, store.has(cookieSessionKey(planted)))
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.Name == SessionCookieName {
+				require.NotEqual(t, planted.Value, cookie.Value)
+			}
+		}
+	})
+
+	t.Run("Privilege Change Rotates The ID", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, user, "laptop")
+
+		// a profile update keeps the ID
+		c, rec := newCookieContext(t, svc, cookie)
+		require.NoError(t, svc.Refresh(c, &User{ID: 42, Name: "Jane", Email: "jane@example.com", Role: shared.RoleCustomer}))
+		require.True(t, store.has(cookieSessionKey(cookie)))
+		require.Equal(t, cookie.Value, sessionCookieOf(t, rec).Value)
+
+		// gaining a second factor doesn't
+		c, rec = newCookieContext(t, svc, cookie)
+		require.NoError(t, svc.Refresh(c, &User{ID: 42, Email: "jane@example.com", Role: shared.RoleCustomer, SecondFactor: true}))
+		rotated := sessionCookieOf(t, rec)
+		require.NotEqual(t, cookie.Value, rotated.Value)
+		require.False(t, store.has(cookieSessionKey(cookie)), "the old ID must stop working")
+
+		checked, _ := newCookieContext(t, svc, rotated)
+		current, ok := GetUserFromContext(checked)
+		require.True(t, ok)
+		require.True(t, current.SecondFactor)
+
+		sessions, err := svc.apiListSessions(checked)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.True(t, sessions[0].Current)
+		require.Equal(t, "laptop", sessions[0].UserAgent)
+	})
+
+	t.Run("Remember Me Persists The Cookie", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+
+		cookie := loginCookie(t, svc, user, "laptop")
+		require.Zero(t, cookie.MaxAge, "the cookie ends with the browser session")
+
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+		c.Set(string(rememberMeContextKey), true)
+		require.NoError(t, svc.Login(c, user))
+		remembered := sessionCookieOf(t, rec)
+		require.InDelta(t, svc.authCfg.Session.AbsoluteTimeout.Seconds(), remembered.MaxAge, 5)
+	})
+
+	t.Run("Activity Slides The Idle Expiry", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, user, "laptop")
+		key := cookieSessionKey(cookie)
+		ctx := context.Background()
+
+		store.FastForward(time.Hour)
+		newCookieContext(t, svc, cookie)
+		ttl, err := store.TTL(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, 23*time.Hour, ttl, "requests within the slide interval don't write")
+
+		// pretend the last slide happened before the slide interval
+		session, err := svc.sessions.(*cookieSessions).load(ctx, key)
+		require.NoError(t, err)
+		session.RefreshedAt = session.RefreshedAt.Add(-svc.authCfg.Session.SlideInterval)
+		require.NoError(t, svc.sessions.(*cookieSessions).save(ctx, key, session))
+		store.FastForward(time.Hour)
+
+		newCookieContext(t, svc, cookie)
+		ttl, err = store.TTL(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, svc.authCfg.Session.IdleTimeout, ttl)
+
+		store.FastForward(svc.authCfg.Session.IdleTimeout)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, err = svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.ErrorIs(t, err, errSessionNotFound, "idle sessions expire")
+	})
+
+	t.Run("Absolute Timeout Ends Active Sessions", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, user, "laptop")
+		key := cookieSessionKey(cookie)
+		ctx := context.Background()
+
+		session, err := svc.sessions.(*cookieSessions).load(ctx, key)
+		require.NoError(t, err)
+		session.CreatedAt = session.CreatedAt.Add(-svc.authCfg.Session.AbsoluteTimeout)
+		sessionJSON, err := json.Marshal(session)
+		require.NoError(t, err)
+		require.NoError(t, store.Set(ctx, key, string(sessionJSON), storageSession.KeepTTL))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, err = svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.ErrorIs(t, err, errSessionNotFound)
+		require.False(t, store.has(key))
+	})
+}
+
+// sessionCookieOf returns the session cookie set last on the response, like browsers apply them
+func sessionCookieOf(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+
+	var session *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionCookieName {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatal("no session cookie set")
+	}
+	return session
+}
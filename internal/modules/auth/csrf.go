@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"go-echo-template/internal/shared/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// CSRFCookieName is readable by the SPA, which echoes its value in CSRFHeaderName
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// csrfToken binds a random nonce to the session ID, "<nonce>.<signature>". A token
+// planted by an attacker, e.g. from a sibling subdomain, doesn't match the victim's session
+func (m *cookieSessions) csrfToken(sessionID string) (string, error) {
+	nonce, err := utils.GenerateToken(16)
+	if err != nil {
+		return "", errCSRFTokenGenerate
+	}
+	return nonce + "." + utils.Sign(m.sessionCfg.SESSION_SECRET, "csrf:"+sessionID+":"+nonce), nil
+}
+
+// issueCSRFToken sets a fresh token for the session in the CSRF cookie
+func (m *cookieSessions) issueCSRFToken(c echo.Context, sessionID string) (string, error) {
+	token, err := m.csrfToken(sessionID)
+	if err != nil {
+		return "", err
+	}
+	c.SetCookie(m.cookie(CSRFCookieName, token, int(m.sessionCfg.AbsoluteTimeout.Seconds()), false))
+	return token, nil
+}
+
+// verifyCSRF checks that the header repeats the CSRF cookie and that the token belongs to the session
+func (m *cookieSessions) verifyCSRF(c echo.Context, sessionID string) error {
+	headerToken := c.Request().Header.Get(CSRFHeaderName)
+	if headerToken == "" {
+		return errCSRFTokenMissing
+	}
+
+	cookie, err := c.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value != headerToken {
+		return errCSRFTokenInvalid
+	}
+
+	nonce, signature, ok := strings.Cut(headerToken, ".")
+	if !ok || !m.verify("csrf:"+sessionID+":"+nonce, signature) {
+		return errCSRFTokenInvalid
+	}
+	return nil
+}
+
+// CSRF enforces the CSRF token on unsafe methods of requests carrying a session cookie.
+// Bearer token clients aren't exposed to CSRF, their requests pass through untouched
+func (s *service) CSRF() echo.MiddlewareFunc {
+	cookies, isCookieService := s.sessions.(*cookieSessions)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isCookieService {
+				return next(c)
+			}
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				return next(c)
+			}
+
+			// requests without a valid session cookie carry no ambient authority
+			sessionID, err := cookies.sessionID(c)
+			if err != nil {
+				return next(c)
+			}
+
+			if err := cookies.verifyCSRF(c, sessionID); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// apiCSRFToken issues a fresh token for the session cookie of the request, an expired session
+// gets one as well so that the SPA can still log in again
+func (s *service) apiCSRFToken(c echo.Context) (*CSRFTokenResponse, error) {
+	cookies, ok := s.sessions.(*cookieSessions)
+	if !ok {
+		return nil, errCSRFNotApplicable
+	}
+
+	sessionID, err := cookies.sessionID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := cookies.issueCSRFToken(c, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &CSRFTokenResponse{Token: token}, nil
+}
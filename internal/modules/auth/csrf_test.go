@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// loginCookies logs the user in and returns the cookies set on the response by name
+func loginCookies(t *testing.T, svc *service, user *User) map[string]*http.Cookie {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	require.NoError(t, svc.Login(c, user))
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+// runCSRF sends a request with the given cookies and header token through the CSRF middleware
+func runCSRF(svc *service, method, headerToken string, cookies ...*http.Cookie) error {
+	req := httptest.NewRequest(method, "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if headerToken != "" {
+		req.Header.Set(CSRFHeaderName, headerToken)
+	}
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	return svc.CSRF()(func(c echo.Context) error { return nil })(c)
+}
+
+func TestCSRF(t *testing.T) {
+	user := &User{ID: 42, Email: "jane@example.com"}
+
+	t.Run("Login Sets Readable Token", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookies := loginCookies(t, svc, user)
+
+		csrfCookie := cookies[CSRFCookieName]
+		require.NotNil(t, csrfCookie)
+		require.NotEmpty(t, csrfCookie.Value)
+		require.False(t, csrfCookie.HttpOnly, "the SPA has to read the token")
+		require.True(t, cookies[SessionCookieName].HttpOnly)
+	})
+
+	t.Run("Unsafe Methods Of Cookie Sessions Need The Token", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookies := loginCookies(t, svc, user)
+		session, csrf := cookies[SessionCookieName], cookies[CSRFCookieName]
+
+		require.NoError(t, runCSRF(svc, http.MethodGet, "", session, csrf), "safe methods are not checked")
+		require.NoError(t, runCSRF(svc, http.MethodPost, ""), "requests without a session are not checked")
+
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			require.ErrorIs(t, runCSRF(svc, method, "", session, csrf), errCSRFTokenMissing)
+			require.NoError(t, runCSRF(svc, method, csrf.Value, session, csrf))
+		}
+	})
+
+	t.Run("Header Must Repeat Cookie", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookies := loginCookies(t, svc, user)
+		session, csrf := cookies[SessionCookieName], cookies[CSRFCookieName]
+
+		require.ErrorIs(t, runCSRF(svc, http.MethodPost, csrf.Value, session), errCSRFTokenInvalid)
+		require.ErrorIs(t, runCSRF(svc, http.MethodPost, "forged", session, csrf), errCSRFTokenInvalid)
+	})
+
+	t.Run("Token Is Bound To The Session", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		victim := loginCookies(t, svc, user)
+		attacker := loginCookies(t, svc, &User{ID: 7})
+
+		// the attacker plants their own valid token next to the victim's session cookie
+		planted := attacker[CSRFCookieName]
+		err := runCSRF(svc, http.MethodPost, planted.Value, victim[SessionCookieName], planted)
+		require.ErrorIs(t, err, errCSRFTokenInvalid)
+	})
+
+	t.Run("Fresh Token Endpoint", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		session := loginCookies(t, svc, user)[SessionCookieName]
+
+		_, err := svc.apiCSRFToken(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()))
+		require.ErrorIs(t, err, errSessionCookieNotFound)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		resData, err := svc.apiCSRFToken(echo.New().NewContext(req, rec))
+		require.NoError(t, err)
+
+		var csrf *http.Cookie
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.Name == CSRFCookieName {
+				csrf = cookie
+			}
+		}
+		require.NotNil(t, csrf)
+		require.Equal(t, resData.Token, csrf.Value)
+		require.NoError(t, runCSRF(svc, http.MethodPost, resData.Token, session, csrf))
+	})
+
+	t.Run("SameSite None Cookies Are Secure", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		svc.authCfg.Session.CookieSameSite = http.SameSiteNoneMode
+
+		for _, cookie := range loginCookies(t, svc, user) {
+			require.Equal(t, http.SameSiteNoneMode, cookie.SameSite)
+			require.True(t, cookie.Secure)
+		}
+	})
+
+	t.Run("Bearer Token Clients Pass Through", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+
+		require.NoError(t, runCSRF(svc, http.MethodDelete, "", &http.Cookie{Name: SessionCookieName, Value: "anything"}))
+		_, err := svc.apiCSRFToken(newBearerContext(""))
+		require.ErrorIs(t, err, errCSRFNotApplicable)
+	})
+}
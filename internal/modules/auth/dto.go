@@ -1,6 +1,169 @@
 package auth
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,password"`
+	// RememberMe keeps the session cookie across browser restarts, token clients ignore it
+	RememberMe bool `json:"rememberMe"`
+}
+
+type LoginResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	TwoFactorToken    string `json:"twoFactorToken,omitempty"`
+}
+
+// TokenResponse is returned on login and refresh by the JWT service
+type TokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	// only set when a new refresh token was issued, the previous one is no longer valid
+	RefreshToken string `json:"refreshToken,omitempty"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type LoginTwoFactorRequest struct {
+	Token string `json:"token" validate:"required"`
+
+	// either a code from the authenticator app or one of the recovery codes
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `query:"token" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `query:"token" validate:"required"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type ConfirmTOTPResponse struct {
+	// shown only once, the hashes are stored
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type ReauthenticateRequest struct {
+	Password     string `json:"password" validate:"required_without_all=Code RecoveryCode"`
+	Code         string `json:"code" validate:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type DisableTOTPRequest struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+type FinishWebAuthnRegistrationRequest struct {
+	// label shown in the credential list, e.g. "MacBook Touch ID"
+	Name string `json:"name" validate:"required,max=64"`
+	// PublicKeyCredential returned by navigator.credentials.create()
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type FinishWebAuthnLoginRequest struct {
+	// PublicKeyCredential returned by navigator.credentials.get()
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type WebAuthnCredentialResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+type DeleteWebAuthnCredentialRequest struct {
+	ID int64 `param:"id" validate:"required"`
+}
+
+type StartOIDCLoginRequest struct {
+	Provider string `param:"provider" validate:"required"`
+}
+
+type FinishOIDCLoginRequest struct {
+	Provider string `param:"provider" validate:"required"`
+	State    string `query:"state" validate:"required"`
+
+	// the provider sends either an authorization code or an error
+	Code  string `query:"code" validate:"required_without=Error"`
+	Error string `query:"error"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	// set on the session the request was made with
+	Current bool `json:"current"`
+}
+
+type RevokeSessionRequest struct {
+	ID string `param:"id" validate:"required"`
+}
+
+type CSRFTokenResponse struct {
+	// also set in the csrf_token cookie, send it back in the X-CSRF-Token header
+	Token string `json:"token"`
+}
+
+type CreateAPIKeyRequest struct {
+	// label shown in the key list, e.g. "CI deploy"
+	Name string `json:"name" validate:"required,max=64"`
+	// permissions granted to the key, e.g. "users:read:self", within those of the user's role
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// 0 creates a key that never expires
+	ExpiresInDays int `json:"expiresInDays" validate:"omitempty,min=1,max=365"`
+}
+
+type APIKeyResponse struct {
+	ID         int64        `json:"id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []Permission `json:"scopes"`
+	ExpiresAt  *time.Time   `json:"expiresAt"`
+	LastUsedAt *time.Time   `json:"lastUsedAt"`
+	CreatedAt  time.Time    `json:"createdAt"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	// shown only once, the hash is stored
+	Token string `json:"token"`
+}
+
+type DeleteAPIKeyRequest struct {
+	ID int64 `param:"id" validate:"required"`
+}
+
+type StartImpersonationRequest struct {
+	UserID int64 `param:"userId" validate:"required"`
 }
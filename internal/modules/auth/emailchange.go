@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"go-echo-template/internal/shared/i18n"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	EmailChangeExpire   = time.Hour
+	EmailChangeCooldown = time.Minute
+
+	emailChangePath = "/confirm-email"
+)
+
+// EmailChange is a change of a user's email waiting for the confirmation of the new address
+type EmailChange struct {
+	UserID   int64  `json:"userId"`
+	OldEmail string `json:"oldEmail"`
+	NewEmail string `json:"newEmail"`
+}
+
+// RequestEmailChange stores a pending email change, mails the confirmation link to the new
+// address and lets the old one know, so a hijacked session can't quietly take the account
+func (s *service) RequestEmailChange(c echo.Context, name string, change *EmailChange) error {
+	ctx := c.Request().Context()
+
+	allowed, err := s.throttleEmailToken(ctx, emailChangeToken, strconv.FormatInt(change.UserID, 10))
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errEmailChangeThrottled
+	}
+
+	token, err := s.issueEmailToken(ctx, emailChangeToken, change.UserID, change)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(ctx, s.emailTokenMessage(c, emailChangeToken, change.NewEmail, name, token)); err != nil {
+		s.logger.ErrorWithContext(ctx, "failed to send email change confirmation mail", s.logger.Err(err))
+		return errEmailChangeMail
+	}
+
+	// the change is still pending, a lost notice must not block it
+	noticeMsg := mailEmailChangeNotice.Render(change.OldEmail, i18n.GetLocaleFromContext(c), name, change.NewEmail)
+	if err := s.mailer.Send(ctx, noticeMsg); err != nil {
+		s.logger.ErrorWithContext(ctx, "failed to send email change notice mail", s.logger.Err(err))
+	}
+
+	return nil
+}
+
+// ConsumeEmailChange returns the pending change of a confirmation token, the token can only
+// be used once
+func (s *service) ConsumeEmailChange(c echo.Context, token string) (*EmailChange, error) {
+	change := new(EmailChange)
+	if _, err := s.consumeEmailToken(c.Request().Context(), emailChangeToken, token, change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var emailChangeTokenPattern = regexp.MustCompile(`confirm-email\?token=([0-9a-f]{64})`)
+
+func TestEmailChange(t *testing.T) {
+	change := &EmailChange{UserID: 42, OldEmail: "jane@example.com", NewEmail: "jane@example.org"}
+
+	t.Run("Link Goes To New Address And Notice To Old One", func(t *testing.T) {
+		svc, mailer, _, _ := newPasswordResetTestService(t)
+
+		require.NoError(t, svc.RequestEmailChange(newTestContext(), "Jane", change))
+		require.Len(t, mailer.messages, 2)
+		require.Equal(t, "jane@example.org", mailer.messages[0].To)
+		require.Equal(t, "jane@example.com", mailer.messages[1].To)
+		require.Contains(t, mailer.messages[1].Body, "jane@example.org")
+		require.NotRegexp(t, emailChangeTokenPattern, mailer.messages[1].Body, "the old address must not get the link")
+
+		token := emailChangeTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+		consumed, err := svc.ConsumeEmailChange(newTestContext(), token)
+		require.NoError(t, err)
+		require.Equal(t, change, consumed)
+
+		_, err = svc.ConsumeEmailChange(newTestContext(), token)
+		require.ErrorIs(t, err, errEmailChangeTokenInvalid, "links work once")
+	})
+
+	t.Run("New Request Replaces The Pending One", func(t *testing.T) {
+		svc, mailer, _, store := newPasswordResetTestService(t)
+
+		require.NoError(t, svc.RequestEmailChange(newTestContext(), "Jane", change))
+		require.ErrorIs(t, svc.RequestEmailChange(newTestContext(), "Jane", change), errEmailChangeThrottled)
+
+		store.FastForward(EmailChangeCooldown)
+		require.NoError(t, svc.RequestEmailChange(newTestContext(), "Jane", change))
+		require.Len(t, mailer.messages, 4)
+
+		oldToken := emailChangeTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+		_, err := svc.ConsumeEmailChange(newTestContext(), oldToken)
+		require.ErrorIs(t, err, errEmailChangeTokenInvalid)
+
+		newToken := emailChangeTokenPattern.FindStringSubmatch(mailer.messages[2].Body)[1]
+		_, err = svc.ConsumeEmailChange(newTestContext(), newToken)
+		require.NoError(t, err)
+	})
+
+	t.Run("Link Expires", func(t *testing.T) {
+		svc, mailer, _, store := newPasswordResetTestService(t)
+
+		require.NoError(t, svc.RequestEmailChange(newTestContext(), "Jane", change))
+		store.FastForward(EmailChangeExpire)
+
+		token := emailChangeTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+		_, err := svc.ConsumeEmailChange(newTestContext(), token)
+		require.ErrorIs(t, err, errEmailChangeTokenInvalid)
+	})
+}
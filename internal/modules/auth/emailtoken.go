@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"go-echo-template/internal/mail"
+	"go-echo-template/internal/shared/i18n"
+	"go-echo-template/internal/shared/response"
+	"go-echo-template/internal/shared/utils"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/labstack/echo/v4"
+)
+
+// emailToken describes a kind of single-use token mailed as a link, a user holds at most
+// one valid token of each kind. A kind with the key prefix P uses the keys
+//
+//	P:<token hash> -> user ID and payload of the token as JSON
+//	P_USER:<user ID> -> token hash, only the latest token of a user is valid
+//	P_COOLDOWN:<email hash or user ID> -> throttles the mails
+type emailToken struct {
+	// name of the kind in logs
+	name      string
+	keyPrefix string
+	expire    time.Duration
+	cooldown  time.Duration
+
+	// path of the frontend page the link opens
+	path string
+	mail *mail.Template
+
+	errTokenInvalid *response.CustomErr
+}
+
+// emailTokenValue is stored under the hash of a token
+type emailTokenValue struct {
+	UserID  int64           `json:"userId"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+var (
+	passwordResetToken = &emailToken{
+		name:            "password reset",
+		keyPrefix:       "PASSWORD_RESET",
+		expire:          PasswordResetExpire,
+		cooldown:        PasswordResetCooldown,
+		path:            passwordResetPath,
+		mail:            mailPasswordReset,
+		errTokenInvalid: errPasswordResetTokenInvalid,
+	}
+	magicLinkToken = &emailToken{
+		name:            "magic link",
+		keyPrefix:       "MAGIC_LINK",
+		expire:          MagicLinkExpire,
+		cooldown:        MagicLinkCooldown,
+		path:            magicLinkPath,
+		mail:            mailMagicLink,
+		errTokenInvalid: errMagicLinkTokenInvalid,
+	}
+	emailChangeToken = &emailToken{
+		name:            "email change",
+		keyPrefix:       "EMAIL_CHANGE",
+		expire:          EmailChangeExpire,
+		cooldown:        EmailChangeCooldown,
+		path:            emailChangePath,
+		mail:            mailEmailChangeConfirm,
+		errTokenInvalid: errEmailChangeTokenInvalid,
+	}
+)
+
+func (kind *emailToken) tokenKey(tokenHash string) string {
+	return kind.keyPrefix + ":" + tokenHash
+}
+
+func (kind *emailToken) userKey(userID int64) string {
+	return kind.keyPrefix + "_USER:" + strconv.FormatInt(userID, 10)
+}
+
+func (kind *emailToken) cooldownKey(subject string) string {
+	return kind.keyPrefix + "_COOLDOWN:" + subject
+}
+
+// emailTokenMessage renders the mail of the given kind carrying the link of token
+func (s *service) emailTokenMessage(c echo.Context, kind *emailToken, to, name, token string) *mail.Message {
+	link := s.cfg.BaseURL + kind.path + "?token=" + token
+	return kind.mail.Render(to, i18n.GetLocaleFromContext(c), name, link, int(kind.expire.Minutes()))
+}
+
+// throttleEmailToken reports whether another mail of the given kind may be sent to subject
+func (s *service) throttleEmailToken(ctx context.Context, kind *emailToken, subject string) (bool, error) {
+	allowed, err := s.store.SetNX(ctx, kind.cooldownKey(subject), "1", kind.cooldown)
+	if err != nil {
+		return false, errEmailTokenStore
+	}
+	return allowed, nil
+}
+
+// issueEmailToken stores a new token of the given kind for a user and invalidates the previous
+// one. payload is kept with the token and decoded again when it is consumed, it may be nil
+func (s *service) issueEmailToken(ctx context.Context, kind *emailToken, userID int64, payload any) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", errEmailTokenGen
+	}
+	tokenHash := utils.HashToken(token)
+
+	value := &emailTokenValue{UserID: userID}
+	if payload != nil {
+		if value.Payload, err = json.Marshal(payload); err != nil {
+			return "", errEmailTokenStore
+		}
+	}
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return "", errEmailTokenStore
+	}
+
+	// invalidate the previously issued token, if any
+	userKey := kind.userKey(userID)
+	if prevHash, err := s.store.Get(ctx, userKey); err == nil {
+		if _, err := s.store.Del(ctx, kind.tokenKey(prevHash)); err != nil {
+			return "", errEmailTokenStore
+		}
+	}
+
+	if err := s.store.Set(ctx, kind.tokenKey(tokenHash), string(valueJSON), kind.expire); err != nil {
+		return "", errEmailTokenStore
+	}
+	if err := s.store.Set(ctx, userKey, tokenHash, kind.expire); err != nil {
+		return "", errEmailTokenStore
+	}
+	return token, nil
+}
+
+// sendEmailToken mails a new token of the given kind to the user registered with email,
+// replacing the previous one. The outcome is never revealed to the caller so that the
+// endpoints can't be used to enumerate registered emails.
+func (s *service) sendEmailToken(c echo.Context, kind *emailToken, email string) error {
+	ctx := c.Request().Context()
+
+	allowed, err := s.throttleEmailToken(ctx, kind, utils.HashToken(email))
+	if err != nil || !allowed {
+		return err
+	}
+
+	userRow, err := s.storage.Auth.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.issueEmailToken(ctx, kind, userRow.ID, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(ctx, s.emailTokenMessage(c, kind, userRow.Email, userRow.Name, token)); err != nil {
+		// failing loudly would reveal that the email is registered
+		s.logger.ErrorWithContext(ctx, "failed to send "+kind.name+" mail", s.logger.Err(err))
+	}
+
+	return nil
+}
+
+// consumeEmailToken invalidates a token of the given kind and returns the ID of its user,
+// the payload stored with the token is decoded into payload unless it is nil
+func (s *service) consumeEmailToken(ctx context.Context, kind *emailToken, token string, payload any) (int64, error) {
+	// GETDEL makes the token single-use even under concurrent requests
+	valueJSON, err := s.store.GetDel(ctx, kind.tokenKey(utils.HashToken(token)))
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return 0, kind.errTokenInvalid
+	}
+	if err != nil {
+		return 0, errEmailTokenStore
+	}
+
+	var value emailTokenValue
+	if err := json.Unmarshal([]byte(valueJSON), &value); err != nil {
+		return 0, kind.errTokenInvalid
+	}
+	if payload != nil {
+		if err := json.Unmarshal(value.Payload, payload); err != nil {
+			return 0, kind.errTokenInvalid
+		}
+	}
+
+	if _, err := s.store.Del(ctx, kind.userKey(value.UserID)); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to delete "+kind.name+" user key", s.logger.Err(err))
+	}
+	return value.UserID, nil
+}
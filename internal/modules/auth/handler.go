@@ -25,8 +25,50 @@ func NewAuthHandler(logger log.CustomLogger, alarmer alarm.Alarmer, service Auth
 func (h *AuthHandler) RegisterRoutes(e *echo.Group) {
 	users := e.Group("/v1/auth")
 	users.POST("/login", h.Login)
+	users.POST("/login/2fa", h.LoginTwoFactor)
 	users.GET("/refresh", h.Refresh)
 	users.GET("/logout", h.Logout)
+	users.GET("/csrf", h.CSRFToken)
+	users.POST("/password/forgot", h.ForgotPassword)
+	users.POST("/password/reset", h.ResetPassword)
+	users.POST("/magic-link", h.RequestMagicLink)
+	users.GET("/magic-link/consume", h.ConsumeMagicLink)
+	users.GET("/verify-email", h.VerifyEmail)
+	users.POST("/verify-email/resend", h.ResendEmailVerification, h.service.CheckAuth(false), h.service.RefuseAPIKey())
+	users.POST("/reauthenticate", h.Reauthenticate, h.service.CheckAuth(false), h.service.RefuseImpersonation())
+
+	totp := users.Group("/2fa/totp", h.service.CheckAuth(false), h.service.RefuseAPIKey(), h.service.RefuseImpersonation())
+	totp.POST("/enroll", h.EnrollTOTP, h.service.RequireRecentAuth(RecentAuthMaxAge))
+	totp.POST("/confirm", h.ConfirmTOTP)
+	totp.POST("/disable", h.DisableTOTP)
+
+	passkeys := users.Group("/webauthn")
+	passkeys.POST("/login/begin", h.BeginWebAuthnLogin)
+	passkeys.POST("/login/finish", h.FinishWebAuthnLogin)
+
+	passkeysAuth := passkeys.Group("", h.service.CheckAuth(false), h.service.RefuseAPIKey(), h.service.RefuseImpersonation())
+	passkeysAuth.POST("/register/begin", h.BeginWebAuthnRegistration, h.service.RequireRecentAuth(RecentAuthMaxAge))
+	passkeysAuth.POST("/register/finish", h.FinishWebAuthnRegistration, h.service.RequireRecentAuth(RecentAuthMaxAge))
+	passkeysAuth.GET("/credentials", h.ListWebAuthnCredentials)
+	passkeysAuth.DELETE("/credentials/:id", h.DeleteWebAuthnCredential)
+
+	sessions := users.Group("/sessions", h.service.CheckAuth(false), h.service.RefuseAPIKey())
+	sessions.GET("", h.ListSessions)
+	sessions.DELETE("", h.LogoutAll, h.service.RefuseImpersonation())
+	sessions.DELETE("/:id", h.RevokeSession, h.service.RefuseImpersonation())
+
+	apiKeys := users.Group("/api-keys", h.service.CheckAuth(false), h.service.RefuseAPIKey(), h.service.RefuseImpersonation())
+	apiKeys.POST("", h.CreateAPIKey, h.service.RequireRecentAuth(RecentAuthMaxAge))
+	apiKeys.GET("", h.ListAPIKeys)
+	apiKeys.DELETE("/:id", h.DeleteAPIKey)
+
+	// browser redirects, not XHR
+	users.GET("/oidc/:provider", h.StartOIDCLogin)
+	users.GET("/oidc/:provider/callback", h.FinishOIDCLogin)
+
+	impersonate := e.Group("/v1/admin/impersonate")
+	impersonate.POST("/stop", h.StopImpersonation, h.service.CheckAuth(false))
+	impersonate.POST("/:userId", h.StartImpersonation, h.service.CheckAuth(false, WithRoles(shared.RoleAdmin)))
 }
 
 func (h *AuthHandler) Login(c echo.Context) error {
@@ -40,12 +82,35 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	}
 
 	// service call
-	if err := h.service.apiLogin(c, lr); err != nil {
+	resData, err := h.service.apiLogin(c, lr)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	if resData != nil && resData.TwoFactorRequired {
+		return response.Success(c, http.StatusOK).WithMessage(succLoginTwoFactorRequired).WithData(resData).Send()
+	}
+	return response.Success(c, http.StatusOK).WithMessage(succLogin).WithData(issuedTokens(c)).Send()
+}
+
+func (h *AuthHandler) LoginTwoFactor(c echo.Context) error {
+	// validate input
+	ltr := new(LoginTwoFactorRequest)
+	if err := c.Bind(ltr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(ltr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiLoginTwoFactor(c, ltr); err != nil {
 		return err
 	}
 
 	// build response
-	return response.Success(c, http.StatusOK).WithMessage(succLogin).Send()
+	return response.Success(c, http.StatusOK).WithMessage(succLogin).WithData(issuedTokens(c)).Send()
 }
 
 func (h *AuthHandler) Refresh(c echo.Context) error {
@@ -55,7 +120,7 @@ func (h *AuthHandler) Refresh(c echo.Context) error {
 	}
 
 	// build response
-	return response.Success(c, http.StatusOK).Send()
+	return response.Success(c, http.StatusOK).WithData(issuedTokens(c)).Send()
 }
 
 func (h *AuthHandler) Logout(c echo.Context) error {
@@ -67,3 +132,450 @@ func (h *AuthHandler) Logout(c echo.Context) error {
 	// build response
 	return response.Success(c, http.StatusOK).Send()
 }
+
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	// validate input
+	fpr := new(ForgotPasswordRequest)
+	if err := c.Bind(fpr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(fpr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiForgotPassword(c, fpr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succPasswordResetRequested).Send()
+}
+
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	// validate input
+	rpr := new(ResetPasswordRequest)
+	if err := c.Bind(rpr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(rpr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiResetPassword(c, rpr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succPasswordReset).Send()
+}
+
+func (h *AuthHandler) RequestMagicLink(c echo.Context) error {
+	// validate input
+	mlr := new(MagicLinkRequest)
+	if err := c.Bind(mlr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(mlr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiRequestMagicLink(c, mlr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succMagicLinkRequested).Send()
+}
+
+func (h *AuthHandler) ConsumeMagicLink(c echo.Context) error {
+	// validate input
+	cml := new(ConsumeMagicLinkRequest)
+	if err := c.Bind(cml); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(cml); err != nil {
+		return err
+	}
+
+	// service call
+	resData, err := h.service.apiConsumeMagicLink(c, cml)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	if resData != nil && resData.TwoFactorRequired {
+		return response.Success(c, http.StatusOK).WithMessage(succLoginTwoFactorRequired).WithData(resData).Send()
+	}
+	return response.Success(c, http.StatusOK).WithMessage(succLogin).WithData(issuedTokens(c)).Send()
+}
+
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	// validate input
+	ver := new(VerifyEmailRequest)
+	if err := c.Bind(ver); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(ver); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiVerifyEmail(c, ver); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succEmailVerified).Send()
+}
+
+func (h *AuthHandler) ResendEmailVerification(c echo.Context) error {
+	// service call
+	if err := h.service.apiResendEmailVerification(c); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succEmailVerificationSent).Send()
+}
+
+func (h *AuthHandler) EnrollTOTP(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiEnrollTOTP(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) ConfirmTOTP(c echo.Context) error {
+	// validate input
+	ctr := new(ConfirmTOTPRequest)
+	if err := c.Bind(ctr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(ctr); err != nil {
+		return err
+	}
+
+	// service call
+	resData, err := h.service.apiConfirmTOTP(c, ctr)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succTOTPEnabled).WithData(resData).Send()
+}
+
+func (h *AuthHandler) DisableTOTP(c echo.Context) error {
+	// validate input
+	dtr := new(DisableTOTPRequest)
+	if err := c.Bind(dtr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(dtr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiDisableTOTP(c, dtr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succTOTPDisabled).Send()
+}
+
+func (h *AuthHandler) BeginWebAuthnRegistration(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiBeginWebAuthnRegistration(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) FinishWebAuthnRegistration(c echo.Context) error {
+	// validate input
+	fwr := new(FinishWebAuthnRegistrationRequest)
+	if err := c.Bind(fwr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(fwr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiFinishWebAuthnRegistration(c, fwr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusCreated).WithMessage(succWebAuthnRegistered).Send()
+}
+
+func (h *AuthHandler) BeginWebAuthnLogin(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiBeginWebAuthnLogin(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) FinishWebAuthnLogin(c echo.Context) error {
+	// validate input
+	fwl := new(FinishWebAuthnLoginRequest)
+	if err := c.Bind(fwl); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(fwl); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiFinishWebAuthnLogin(c, fwl); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succLogin).WithData(issuedTokens(c)).Send()
+}
+
+func (h *AuthHandler) ListWebAuthnCredentials(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiListWebAuthnCredentials(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) DeleteWebAuthnCredential(c echo.Context) error {
+	// validate input
+	dwc := new(DeleteWebAuthnCredentialRequest)
+	if err := c.Bind(dwc); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(dwc); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiDeleteWebAuthnCredential(c, dwc); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succWebAuthnDeleted).Send()
+}
+
+func (h *AuthHandler) StartOIDCLogin(c echo.Context) error {
+	// validate input
+	sor := new(StartOIDCLoginRequest)
+	if err := c.Bind(sor); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(sor); err != nil {
+		return err
+	}
+
+	// service call
+	redirectURL, err := h.service.apiStartOIDCLogin(c, sor)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
+func (h *AuthHandler) FinishOIDCLogin(c echo.Context) error {
+	// validate input
+	fol := new(FinishOIDCLoginRequest)
+	if err := c.Bind(fol); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(fol); err != nil {
+		return err
+	}
+
+	// service call
+	redirectURL, err := h.service.apiFinishOIDCLogin(c, fol)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiListSessions(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	// validate input
+	rsr := new(RevokeSessionRequest)
+	if err := c.Bind(rsr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(rsr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiRevokeSession(c, rsr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succSessionRevoked).Send()
+}
+
+func (h *AuthHandler) LogoutAll(c echo.Context) error {
+	// service call
+	if err := h.service.apiLogoutAll(c); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succLogoutAll).Send()
+}
+
+func (h *AuthHandler) Reauthenticate(c echo.Context) error {
+	// validate input
+	rar := new(ReauthenticateRequest)
+	if err := c.Bind(rar); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(rar); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiReauthenticate(c, rar); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succReauthenticated).Send()
+}
+
+func (h *AuthHandler) CSRFToken(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiCSRFToken(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) CreateAPIKey(c echo.Context) error {
+	// validate input
+	car := new(CreateAPIKeyRequest)
+	if err := c.Bind(car); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(car); err != nil {
+		return err
+	}
+
+	// service call
+	resData, err := h.service.apiCreateAPIKey(c, car)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusCreated).WithMessage(succAPIKeyCreated).WithData(resData).Send()
+}
+
+func (h *AuthHandler) ListAPIKeys(c echo.Context) error {
+	// service call
+	resData, err := h.service.apiListAPIKeys(c)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *AuthHandler) DeleteAPIKey(c echo.Context) error {
+	// validate input
+	dar := new(DeleteAPIKeyRequest)
+	if err := c.Bind(dar); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(dar); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiDeleteAPIKey(c, dar); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succAPIKeyDeleted).Send()
+}
+
+func (h *AuthHandler) StartImpersonation(c echo.Context) error {
+	// validate input
+	sir := new(StartImpersonationRequest)
+	if err := c.Bind(sir); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(sir); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.apiStartImpersonation(c, sir); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succImpersonationStarted).WithData(issuedTokens(c)).Send()
+}
+
+func (h *AuthHandler) StopImpersonation(c echo.Context) error {
+	// service call
+	if err := h.service.apiStopImpersonation(c); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succImpersonationStopped).WithData(issuedTokens(c)).Send()
+}
+
+// issuedTokens returns the tokens issued by the JWT service or nil, so that cookie
+// session responses don't carry an empty data field
+func issuedTokens(c echo.Context) any {
+	if tokens, ok := GetTokensFromContext(c); ok {
+		return tokens
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/shared"
+
+	"github.com/labstack/echo/v4"
+)
+
+const ImpersonationExpire = time.Hour
+
+// Impersonation is carried by the session user while an admin acts as them
+type Impersonation struct {
+	// Admin is the session user who started the impersonation. Only their ID and how they
+	// logged in are relied on, the rest is reloaded when it stops
+	Admin     *User
+	ExpiresAt time.Time
+}
+
+// apiStartImpersonation replaces the session of the admin with a session of the target user
+func (s *service) apiStartImpersonation(c echo.Context, req *StartImpersonationRequest) error {
+	ctx := c.Request().Context()
+	admin, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+	if admin.Impersonation != nil {
+		return errImpersonationNested
+	}
+	// personal access tokens can't start sessions
+	if admin.APIKeyID != 0 {
+		return shared.ErrForbidden
+	}
+	if admin.ID == req.UserID {
+		return errImpersonationSelf
+	}
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, req.UserID)
+	if err == sql.ErrNoRows {
+		return errImpersonationUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// admins can't act with the privileges of each other
+	if userRow.Role == shared.RoleAdmin {
+		return errImpersonationAdmin
+	}
+
+	user := &User{
+		ID:        userRow.ID,
+		Name:      userRow.Name,
+		Email:     userRow.Email,
+		Phone:     userRow.Phone.String,
+		Role:      userRow.Role,
+		CreatedAt: userRow.CreatedAt,
+		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+		Impersonation: &Impersonation{
+			Admin:     admin,
+			ExpiresAt: time.Now().Add(ImpersonationExpire),
+		},
+	}
+
+	// the session manager is used directly, this isn't a login of the user
+	if err := s.sessions.Login(c, user); err != nil {
+		return err
+	}
+
+	s.recordEvent(c, &audit.Event{ActorID: admin.ID, Action: audit.ActionImpersonationStart, TargetType: audit.TargetUser, TargetID: user.ID})
+	s.logger.InfoWithContext(ctx, "impersonation started",
+		s.logger.Int("adminID", int(admin.ID)),
+		s.logger.Int("userID", int(user.ID)),
+	)
+	return nil
+}
+
+// apiStopImpersonation ends the impersonated session and gives the admin their own session back,
+// an admin who no longer exists is just logged out
+func (s *service) apiStopImpersonation(c echo.Context) error {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+	if user.Impersonation == nil {
+		return errNotImpersonating
+	}
+
+	// the admin may have been demoted, suspended or deleted meanwhile, the stored copy isn't trusted
+	stored := user.Impersonation.Admin
+	adminRow, err := s.storage.Auth.GetUserById(ctx, stored.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err := s.sessions.Logout(c); err != nil {
+		return err
+	}
+	s.recordEvent(c, &audit.Event{ActorID: stored.ID, Action: audit.ActionImpersonationStop, TargetType: audit.TargetUser, TargetID: user.ID})
+	s.logger.InfoWithContext(ctx, "impersonation stopped",
+		s.logger.Int("adminID", int(stored.ID)),
+		s.logger.Int("userID", int(user.ID)),
+	)
+	if err == sql.ErrNoRows {
+		return shared.ErrSessionUnauthorized
+	}
+
+	admin := &User{
+		ID:        adminRow.ID,
+		Name:      adminRow.Name,
+		Email:     adminRow.Email,
+		Phone:     adminRow.Phone.String,
+		Role:      adminRow.Role,
+		CreatedAt: adminRow.CreatedAt,
+		UpdatedAt: adminRow.UpdatedAt,
+
+		EmailVerified: adminRow.EmailVerifiedAt.Valid,
+		// how the admin logged in still holds
+		SecondFactor:    stored.SecondFactor,
+		AuthenticatedAt: stored.AuthenticatedAt,
+	}
+	return s.sessions.Login(c, admin)
+}
+
+// checkImpersonation ends expired impersonations and logs every request made while impersonating
+func (s *service) checkImpersonation(c echo.Context, user *User) error {
+	ctx := c.Request().Context()
+
+	if time.Now().After(user.Impersonation.ExpiresAt) {
+		if err := s.sessions.Logout(c); err != nil {
+			s.logger.WarnWithContext(ctx, "failed to end expired impersonation", s.logger.Err(err))
+		}
+		return errImpersonationExpired
+	}
+
+	s.logger.InfoWithContext(ctx, "impersonated request",
+		s.logger.String("method", c.Request().Method),
+		s.logger.String("uri", c.Request().RequestURI),
+		s.logger.Int("adminID", int(user.Impersonation.Admin.ID)),
+		s.logger.Int("userID", int(user.ID)),
+	)
+	return nil
+}
+
+// RefuseImpersonation guards destructive endpoints, only the users themselves may call them
+func (s *service) RefuseImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user, ok := GetUserFromContext(c); ok && user.Impersonation != nil {
+				return errImpersonationForbidden
+			}
+			return next(c)
+		}
+	}
+}
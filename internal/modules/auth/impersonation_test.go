@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/shared"
+	authSqlc "go-echo-template/internal/storage/auth/sqlc"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonation(t *testing.T) {
+	admin := &User{ID: 1, Email: "admin@example.com", Role: shared.RoleAdmin, SecondFactor: true}
+
+	// impersonate logs the admin in and starts impersonating the user, the session cookie is returned
+	impersonate := func(t *testing.T, svc *service, userID int64) *http.Cookie {
+		t.Helper()
+
+		c, rec := newCookieContext(t, svc, loginCookie(t, svc, admin, "laptop"))
+		require.NoError(t, svc.apiStartImpersonation(c, &StartImpersonationRequest{UserID: userID}))
+		return sessionCookieOf(t, rec)
+	}
+
+	t.Run("Session Carries Both Identities", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookie := impersonate(t, svc, 42)
+
+		c, _ := newCookieContext(t, svc, cookie)
+		user, ok := GetUserFromContext(c)
+		require.True(t, ok)
+		require.Equal(t, int64(42), user.ID)
+		require.Equal(t, shared.RoleCustomer, user.Role)
+		require.NotNil(t, user.Impersonation)
+		require.Equal(t, admin.ID, user.Impersonation.Admin.ID)
+		require.WithinDuration(t, time.Now().Add(ImpersonationExpire), user.Impersonation.ExpiresAt, time.Minute)
+
+		err := svc.RefuseImpersonation()(func(echo.Context) error { return nil })(c)
+		require.ErrorIs(t, err, errImpersonationForbidden)
+	})
+
+	t.Run("Stop Restores The Admin", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		svc.storage.Auth.(*fakeAuthRepo).users[admin.Email] = &authSqlc.GetUserByEmailRow{ID: admin.ID, Email: admin.Email, Role: shared.RoleAdmin}
+		cookie := impersonate(t, svc, 42)
+
+		c, rec := newCookieContext(t, svc, cookie)
+		require.NoError(t, svc.apiStopImpersonation(c))
+		require.False(t, store.has(cookieSessionKey(cookie)))
+
+		restored, _ := newCookieContext(t, svc, sessionCookieOf(t, rec))
+		user, ok := GetUserFromContext(restored)
+		require.True(t, ok)
+		require.Equal(t, admin.ID, user.ID)
+		require.True(t, user.SecondFactor, "the admin session is restored as it was")
+		require.Nil(t, user.Impersonation)
+
+		require.ErrorIs(t, svc.apiStopImpersonation(restored), errNotImpersonating)
+
+		auditRepo := svc.storage.Audit.(*fakeAuditRepo)
+		require.Equal(t, []string{"auth.login", "auth.impersonation_start", "auth.impersonation_stop"}, auditRepo.actions())
+		for _, event := range auditRepo.events[1:] {
+			require.Equal(t, admin.ID, event.ActorID.Int64)
+			require.Equal(t, int64(42), event.TargetID.Int64)
+		}
+	})
+
+	t.Run("Stop Reloads The Admin", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		users := svc.storage.Auth.(*fakeAuthRepo).users
+		users[admin.Email] = &authSqlc.GetUserByEmailRow{ID: admin.ID, Email: admin.Email, Role: shared.RoleAdmin}
+
+		// a demotion while impersonating is applied when the admin session comes back
+		cookie := impersonate(t, svc, 42)
+		users[admin.Email].Role = shared.RoleCustomer
+		c, rec := newCookieContext(t, svc, cookie)
+		require.NoError(t, svc.apiStopImpersonation(c))
+		restored, _ := newCookieContext(t, svc, sessionCookieOf(t, rec))
+		user, ok := GetUserFromContext(restored)
+		require.True(t, ok)
+		require.Equal(t, shared.RoleCustomer, user.Role)
+
+		// an admin who was suspended or deleted is just logged out
+		users[admin.Email].Role = shared.RoleAdmin
+		cookie = impersonate(t, svc, 42)
+		delete(users, admin.Email)
+		c, rec = newCookieContext(t, svc, cookie)
+		require.ErrorIs(t, svc.apiStopImpersonation(c), shared.ErrSessionUnauthorized)
+		require.False(t, store.has(cookieSessionKey(cookie)))
+		for _, set := range rec.Result().Cookies() {
+			if set.Name == SessionCookieName {
+				require.Empty(t, set.Value)
+			}
+		}
+	})
+
+	t.Run("Session Is Listed Under The Admin", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		phone := loginCookie(t, svc, &User{ID: 42, Email: "jane@example.com"}, "phone")
+		cookie := impersonate(t, svc, 42)
+		id := publicSessionID(cookieSessionKey(cookie))
+
+		// the target neither sees nor can revoke it, and it doesn't count towards their limit
+		c, _ := newCookieContext(t, svc, phone)
+		sessions, err := svc.apiListSessions(c)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.ErrorIs(t, svc.apiRevokeSession(c, &RevokeSessionRequest{ID: id}), errSessionUnknown)
+
+		adminSessions, err := svc.listSessions(context.Background(), admin.ID)
+		require.NoError(t, err)
+		require.Contains(t, adminSessions, id)
+
+		// the admin's own logout ends it
+		require.NoError(t, svc.LogoutAll(context.Background(), admin.ID))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, err = svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.Error(t, err)
+	})
+
+	t.Run("Expired Impersonation Ends The Session", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		cookie := impersonate(t, svc, 42)
+		key := cookieSessionKey(cookie)
+		ctx := context.Background()
+
+		sessions := svc.sessions.(*cookieSessions)
+		session, err := sessions.load(ctx, key)
+		require.NoError(t, err)
+		session.User.Impersonation.ExpiresAt = time.Now().Add(-time.Second)
+		require.NoError(t, sessions.save(ctx, key, session))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, err = svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.ErrorIs(t, err, errImpersonationExpired)
+		require.False(t, store.has(key))
+	})
+
+	t.Run("Refused Targets", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		svc.storage.Auth.(*fakeAuthRepo).users["root@example.com"] = &authSqlc.GetUserByEmailRow{ID: 2, Email: "root@example.com", Role: shared.RoleAdmin}
+
+		c, _ := newCookieContext(t, svc, loginCookie(t, svc, admin, "laptop"))
+		require.ErrorIs(t, svc.apiStartImpersonation(c, &StartImpersonationRequest{UserID: admin.ID}), errImpersonationSelf)
+		require.ErrorIs(t, svc.apiStartImpersonation(c, &StartImpersonationRequest{UserID: 2}), errImpersonationAdmin)
+		require.ErrorIs(t, svc.apiStartImpersonation(c, &StartImpersonationRequest{UserID: 404}), errImpersonationUserNotFound)
+
+		impersonated, _ := newCookieContext(t, svc, impersonate(t, svc, 42))
+		require.ErrorIs(t, svc.apiStartImpersonation(impersonated, &StartImpersonationRequest{UserID: 42}), errImpersonationNested)
+	})
+}
@@ -0,0 +1,455 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/utils"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// REFRESH_FAMILY:<family ID> -> user and current refresh token of one login
+	RefreshFamilyKeyPrefix = "REFRESH_FAMILY:"
+	// REFRESH_TOKEN:<token hash> -> family ID, kept after rotation so a reused token is recognised
+	RefreshTokenKeyPrefix = "REFRESH_TOKEN:"
+
+	// RefreshTokenPrefix tells opaque refresh tokens apart from access tokens
+	RefreshTokenPrefix = "rt_"
+
+	TokenContextKey       shared.ContextKey = "tokens"
+	tokenFamilyContextKey shared.ContextKey = "token_family"
+)
+
+// refreshFamily is the server side state of a login, every refresh token issued
+// for it belongs to the same family and only the latest one may be used
+type refreshFamily struct {
+	User    *User  `json:"user"`
+	Current string `json:"current"`
+}
+
+// accessTokenClaims hold only what authorization needs. A token can be read by anyone
+// holding it, so the profile of the user stays out and is loaded from the database instead
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	Family        string           `json:"fam"`
+	Role          string           `json:"role"`
+	EmailVerified bool             `json:"email_verified,omitempty"`
+	SecondFactor  bool             `json:"2fa,omitempty"`
+	AuthTime      *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Actor is the admin impersonating the subject, as the act claim of RFC 8693
+	Actor *actorClaims `json:"act,omitempty"`
+}
+
+type actorClaims struct {
+	Subject      string           `json:"sub"`
+	SecondFactor bool             `json:"2fa,omitempty"`
+	AuthTime     *jwt.NumericDate `json:"auth_time,omitempty"`
+	// end of the impersonation, which may come before the token expires
+	ExpiresAt *jwt.NumericDate `json:"exp"`
+}
+
+// newAccessTokenClaims describes user in the claims of an access token
+func newAccessTokenClaims(user *User) *accessTokenClaims {
+	claims := &accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)},
+		Role:             user.Role,
+		EmailVerified:    user.EmailVerified,
+		SecondFactor:     user.SecondFactor,
+		AuthTime:         numericDate(user.AuthenticatedAt),
+	}
+	if user.Impersonation != nil {
+		admin := user.Impersonation.Admin
+		claims.Actor = &actorClaims{
+			Subject:      strconv.FormatInt(admin.ID, 10),
+			SecondFactor: admin.SecondFactor,
+			AuthTime:     numericDate(admin.AuthenticatedAt),
+			ExpiresAt:    jwt.NewNumericDate(user.Impersonation.ExpiresAt),
+		}
+	}
+	return claims
+}
+
+// user returns the session user described by the claims, it carries no profile data
+func (claims *accessTokenClaims) user() (*User, error) {
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, errAccessTokenInvalid
+	}
+	user := &User{
+		ID:              id,
+		Role:            claims.Role,
+		EmailVerified:   claims.EmailVerified,
+		SecondFactor:    claims.SecondFactor,
+		AuthenticatedAt: timeOf(claims.AuthTime),
+	}
+
+	if claims.Actor != nil {
+		adminID, err := strconv.ParseInt(claims.Actor.Subject, 10, 64)
+		if err != nil || claims.Actor.ExpiresAt == nil {
+			return nil, errAccessTokenInvalid
+		}
+		user.Impersonation = &Impersonation{
+			Admin: &User{
+				ID:              adminID,
+				SecondFactor:    claims.Actor.SecondFactor,
+				AuthenticatedAt: timeOf(claims.Actor.AuthTime),
+			},
+			ExpiresAt: claims.Actor.ExpiresAt.Time,
+		}
+	}
+	return user, nil
+}
+
+// numericDate leaves out unset times
+func numericDate(t time.Time) *jwt.NumericDate {
+	if t.IsZero() {
+		return nil
+	}
+	return jwt.NewNumericDate(t)
+}
+
+func timeOf(date *jwt.NumericDate) time.Time {
+	if date == nil {
+		return time.Time{}
+	}
+	return date.Time
+}
+
+// jwtSessions issues signed access tokens and rotating refresh tokens kept in the session store
+type jwtSessions struct {
+	cfg    *config.JWTConfig
+	store  storageSession.SessionStore
+	logger log.CustomLogger
+
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+func newJWTSessions(cfg *config.JWTConfig, store storageSession.SessionStore, logger log.CustomLogger) *jwtSessions {
+	m := &jwtSessions{cfg: cfg, store: store, logger: logger}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		m.method = jwt.SigningMethodHS256
+		m.signKey = []byte(cfg.Secret)
+		m.verifyKey = []byte(cfg.Secret)
+	case jwt.SigningMethodEdDSA.Alg():
+		block, _ := pem.Decode([]byte(cfg.PrivateKey))
+		if block == nil {
+			panic("invalid JWT private key: no PEM block found")
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			panic("invalid JWT private key: " + err.Error())
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			panic("invalid JWT private key: not an Ed25519 key")
+		}
+		m.method = jwt.SigningMethodEdDSA
+		m.signKey = privateKey
+		m.verifyKey = privateKey.Public()
+	default:
+		panic("unsupported JWT algorithm: " + cfg.Algorithm)
+	}
+
+	return m
+}
+
+// Login starts a refresh token family and hands out the first token pair
+func (m *jwtSessions) Login(c echo.Context, user *User) error {
+	ctx := c.Request().Context()
+
+	familyID, err := utils.GenerateToken(16)
+	if err != nil {
+		return errSessionGenID
+	}
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return errSessionGenID
+	}
+
+	familyJSON, err := json.Marshal(&refreshFamily{User: user, Current: utils.HashToken(refreshToken)})
+	if err != nil {
+		return errSessionSerialize
+	}
+
+	familyKey := RefreshFamilyKeyPrefix + familyID
+	infoJSON, err := newSessionInfo(c, familyKey)
+	if err != nil {
+		return err
+	}
+	indexKey := userSessionsKey(sessionOwnerID(user))
+
+	if err := m.store.Set(ctx, familyKey, string(familyJSON), m.cfg.RefreshTokenTTL); err != nil {
+		return errSessionStore
+	}
+	if err := m.store.Set(ctx, RefreshTokenKeyPrefix+utils.HashToken(refreshToken), familyID, m.cfg.RefreshTokenTTL); err != nil {
+		return errSessionStore
+	}
+	if err := m.store.HSet(ctx, indexKey, publicSessionID(familyKey), string(infoJSON), m.cfg.RefreshTokenTTL); err != nil {
+		return errSessionStore
+	}
+
+	return m.issue(c, user, familyID, refreshToken)
+}
+
+// Logout revokes the refresh token family of the presented access token, expired ones included
+func (m *jwtSessions) Logout(c echo.Context) error {
+	token, ok := bearerToken(c)
+	if !ok {
+		return nil
+	}
+
+	claims := new(accessTokenClaims)
+	if _, err := m.parse(token, claims, jwt.WithoutClaimsValidation()); err != nil {
+		return nil
+	}
+	user, err := claims.user()
+	if err != nil {
+		return nil
+	}
+
+	ctx := c.Request().Context()
+	familyKey := RefreshFamilyKeyPrefix + claims.Family
+
+	if err := m.revoke(ctx, sessionOwnerID(user), familyKey); err != nil {
+		m.logger.WarnWithContext(ctx, "failed to revoke refresh token family", m.logger.Err(err))
+	}
+	return nil
+}
+
+// Refresh rotates the refresh token sent as the bearer token when user is nil, otherwise it
+// stores the updated user in the family of the current access token and re-issues the latter
+func (m *jwtSessions) Refresh(c echo.Context, user *User) error {
+	if user != nil {
+		return m.update(c, user)
+	}
+
+	token, ok := bearerToken(c)
+	if !ok || !strings.HasPrefix(token, RefreshTokenPrefix) {
+		return errRefreshTokenInvalid
+	}
+
+	ctx := c.Request().Context()
+	tokenHash := utils.HashToken(token)
+
+	familyID, err := m.store.Get(ctx, RefreshTokenKeyPrefix+tokenHash)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return errRefreshTokenInvalid
+	}
+	if err != nil {
+		return errSessionCheckExist
+	}
+
+	familyKey := RefreshFamilyKeyPrefix + familyID
+	newRefreshToken, err := generateRefreshToken()
+	if err != nil {
+		return errSessionGenID
+	}
+
+	familyJSON, err := m.store.Get(ctx, familyKey)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		// revoked by a logout, an earlier reuse or the session limit. Access tokens
+		// aren't looked up in the store, so an eviction surfaces on the next refresh
+		if sessionEvicted(ctx, m.store, familyKey) {
+			return errSessionEvicted
+		}
+		return errRefreshTokenInvalid
+	}
+	if err != nil {
+		return errSessionCheckExist
+	}
+
+	var family refreshFamily
+	if err := json.Unmarshal([]byte(familyJSON), &family); err != nil {
+		return errSessionDeserialize
+	}
+
+	// an already rotated token was presented, either the client or an attacker
+	// holds a stolen copy so the whole family is revoked
+	if family.Current != tokenHash {
+		if err := m.revoke(ctx, sessionOwnerID(family.User), familyKey); err != nil {
+			return errSessionRevoke
+		}
+		m.logger.WarnWithContext(ctx, "refresh token reuse detected, token family revoked",
+			m.logger.Int("userID", int(family.User.ID)),
+		)
+		return errRefreshTokenReused
+	}
+
+	family.Current = utils.HashToken(newRefreshToken)
+	updatedJSON, err := json.Marshal(&family)
+	if err != nil {
+		return errSessionSerialize
+	}
+
+	swapped, err := m.store.CompareAndSwap(ctx, familyKey, familyJSON, string(updatedJSON), m.cfg.RefreshTokenTTL)
+	if err != nil {
+		return errSessionStore
+	}
+	if !swapped {
+		// a concurrent refresh with the same token won the race
+		return errRefreshTokenInvalid
+	}
+	if err := m.store.Set(ctx, RefreshTokenKeyPrefix+family.Current, familyID, m.cfg.RefreshTokenTTL); err != nil {
+		return errSessionStore
+	}
+	if err := m.store.Extend(ctx, userSessionsKey(sessionOwnerID(family.User)), m.cfg.RefreshTokenTTL); err != nil {
+		return errSessionStore
+	}
+
+	return m.issue(c, family.User, familyID, newRefreshToken)
+}
+
+// update replaces the user stored in the family of the current access token
+func (m *jwtSessions) update(c echo.Context, user *User) error {
+	familyID, ok := c.Get(string(tokenFamilyContextKey)).(string)
+	if !ok {
+		if _, err := m.Check(c); err != nil {
+			return err
+		}
+		familyID, _ = c.Get(string(tokenFamilyContextKey)).(string)
+	}
+
+	ctx := c.Request().Context()
+	familyKey := RefreshFamilyKeyPrefix + familyID
+
+	familyJSON, err := m.store.Get(ctx, familyKey)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return errSessionNotFound
+	}
+	if err != nil {
+		return errSessionCheckExist
+	}
+
+	var family refreshFamily
+	if err := json.Unmarshal([]byte(familyJSON), &family); err != nil {
+		return errSessionDeserialize
+	}
+	family.User = user
+
+	updatedJSON, err := json.Marshal(&family)
+	if err != nil {
+		return errSessionSerialize
+	}
+	if err := m.store.Set(ctx, familyKey, string(updatedJSON), storageSession.KeepTTL); err != nil {
+		return errSessionStore
+	}
+
+	return m.issue(c, user, familyID, "")
+}
+
+// revoke deletes a refresh token family and its entry in the index of its user
+func (m *jwtSessions) revoke(ctx context.Context, userID int64, familyKey string) error {
+	if _, err := m.store.Del(ctx, familyKey); err != nil {
+		return err
+	}
+	return m.store.HDel(ctx, userSessionsKey(userID), publicSessionID(familyKey))
+}
+
+// Check verifies the access token sent as "Authorization: Bearer"
+func (m *jwtSessions) Check(c echo.Context) (*User, error) {
+	token, ok := bearerToken(c)
+	if !ok {
+		return nil, errBearerTokenNotFound
+	}
+	if strings.HasPrefix(token, RefreshTokenPrefix) {
+		return nil, errAccessTokenInvalid
+	}
+
+	claims := new(accessTokenClaims)
+	if _, err := m.parse(token, claims, jwt.WithIssuer(m.cfg.Issuer), jwt.WithExpirationRequired()); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errAccessTokenExpired
+		}
+		return nil, errAccessTokenInvalid
+	}
+	user, err := claims.user()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set(string(tokenFamilyContextKey), claims.Family)
+	return user, nil
+}
+
+// currentKey returns the family of the access token checked or issued during the request
+func (m *jwtSessions) currentKey(c echo.Context) (string, bool) {
+	familyID, ok := c.Get(string(tokenFamilyContextKey)).(string)
+	if !ok || familyID == "" {
+		return "", false
+	}
+	return RefreshFamilyKeyPrefix + familyID, true
+}
+
+func (m *jwtSessions) parse(token string, claims *accessTokenClaims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{m.method.Alg()}))
+	return jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return m.verifyKey, nil
+	}, opts...)
+}
+
+// issue signs an access token and makes the token pair available to the handler,
+// refreshToken is empty when only the access token changed
+func (m *jwtSessions) issue(c echo.Context, user *User, familyID, refreshToken string) error {
+	now := time.Now()
+	claims := newAccessTokenClaims(user)
+	claims.Issuer = m.cfg.Issuer
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(m.cfg.AccessTokenTTL))
+	claims.Family = familyID
+
+	accessToken, err := jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+	if err != nil {
+		return errTokenSign
+	}
+
+	c.Set(string(TokenContextKey), &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(m.cfg.AccessTokenTTL.Seconds()),
+	})
+	c.Set(string(tokenFamilyContextKey), familyID)
+	return nil
+}
+
+// GetTokensFromContext returns the tokens issued during the request, only the JWT service issues any
+func GetTokensFromContext(c echo.Context) (*TokenResponse, bool) {
+	tokens, ok := c.Get(string(TokenContextKey)).(*TokenResponse)
+	return tokens, ok
+}
+
+func bearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
+
+func generateRefreshToken() (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	return RefreshTokenPrefix + token, nil
+}
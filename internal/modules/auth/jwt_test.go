@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/storage"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func newJWTTestService(t *testing.T, jwtCfg *config.JWTConfig) (*service, *testStore) {
+	t.Helper()
+
+	store := newTestStore()
+
+	serverCfg := &config.ServerConfig{Environment: "local", BaseURL: "http://localhost:8080"}
+	logger, err := log.NewCustomLogger(serverCfg)
+	require.NoError(t, err)
+
+	authCfg := &config.AuthConfig{
+		WebAuthn:     &config.WebAuthnConfig{RPID: "localhost", RPDisplayName: "Test", RPOrigins: []string{"http://localhost"}},
+		OIDC:         &config.OIDCConfig{},
+		JWT:          jwtCfg,
+		PasswordHash: testPasswordHash,
+		SessionLimit: &config.SessionLimitConfig{},
+	}
+
+	svc := NewJWTService(serverCfg, authCfg, logger, newCaptureAlarmer(), store, storage.NewStorage(nil, nil, nil, &fakeAuditRepo{}), &captureMailer{})
+	return svc.(*service), store
+}
+
+func hs256Config() *config.JWTConfig {
+	return &config.JWTConfig{
+		Algorithm:       "HS256",
+		Secret:          "test-secret",
+		Issuer:          "test",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+	}
+}
+
+// newBearerContext builds a request carrying the given bearer token
+func newBearerContext(token string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+// loginTokens logs the user in and returns the issued token pair
+func loginTokens(t *testing.T, svc *service, user *User) *TokenResponse {
+	t.Helper()
+
+	c := newBearerContext("")
+	require.NoError(t, svc.Login(c, user))
+
+	tokens, ok := GetTokensFromContext(c)
+	require.True(t, ok)
+	require.NotEmpty(t, tokens.AccessToken)
+	require.NotEmpty(t, tokens.RefreshToken)
+	return tokens
+}
+
+// refreshTokens rotates the given refresh token
+func refreshTokens(svc *service, refreshToken string) (*TokenResponse, error) {
+	c := newBearerContext(refreshToken)
+	if err := svc.Refresh(c, nil); err != nil {
+		return nil, err
+	}
+	tokens, _ := GetTokensFromContext(c)
+	return tokens, nil
+}
+
+func TestJWTService(t *testing.T) {
+	user := &User{ID: 42, Name: "Jane", Email: "jane@example.com", Role: "user", EmailVerified: true}
+
+	t.Run("Access Token Resolves User", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		tokens := loginTokens(t, svc, user)
+
+		c := newBearerContext(tokens.AccessToken)
+		checked, err := svc.Check(c)
+		require.NoError(t, err)
+		// the login time is kept in seconds in the token
+		require.WithinDuration(t, user.AuthenticatedAt, checked.AuthenticatedAt, time.Second)
+		require.Equal(t, &User{ID: 42, Role: "user", EmailVerified: true, AuthenticatedAt: checked.AuthenticatedAt}, checked)
+
+		fromCtx, ok := GetUserFromContext(c)
+		require.True(t, ok)
+		require.Equal(t, checked, fromCtx)
+	})
+
+	t.Run("Access Token Carries No Profile", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		impersonated := &User{ID: 42, Name: "Jane", Email: "jane@example.com", Role: "user", Impersonation: &Impersonation{
+			Admin:     &User{ID: 1, Name: "Root", Email: "root@example.com", Role: "admin", SecondFactor: true},
+			ExpiresAt: time.Now().Add(ImpersonationExpire),
+		}}
+		tokens := loginTokens(t, svc, impersonated)
+
+		parts := strings.Split(tokens.AccessToken, ".")
+		require.Len(t, parts, 3)
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		for _, pii := range []string{"Jane", "jane@example.com", "Root", "root@example.com"} {
+			require.NotContains(t, string(payload), pii)
+		}
+
+		checked, err := svc.Check(newBearerContext(tokens.AccessToken))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), checked.Impersonation.Admin.ID)
+		require.True(t, checked.Impersonation.Admin.SecondFactor)
+		require.WithinDuration(t, impersonated.Impersonation.ExpiresAt, checked.Impersonation.ExpiresAt, time.Second)
+	})
+
+	t.Run("CheckAuth Rejects Missing And Foreign Tokens", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		tokens := loginTokens(t, svc, user)
+
+		next := func(c echo.Context) error { return nil }
+		require.Error(t, svc.CheckAuth(false)(next)(newBearerContext("")))
+		require.Error(t, svc.CheckAuth(false)(next)(newBearerContext(tokens.RefreshToken)), "refresh tokens are not access tokens")
+
+		otherCfg := hs256Config()
+		otherCfg.Secret = "other-secret"
+		other, _ := newJWTTestService(t, otherCfg)
+		_, err := other.Check(newBearerContext(tokens.AccessToken))
+		require.ErrorIs(t, err, errAccessTokenInvalid)
+
+		// unsigned tokens must never be accepted
+		unsigned := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJpc3MiOiJ0ZXN0IiwidXNyIjp7IklEIjo0Mn19."
+		_, err = svc.Check(newBearerContext(unsigned))
+		require.ErrorIs(t, err, errAccessTokenInvalid)
+	})
+
+	t.Run("Expired Access Token Is Rejected", func(t *testing.T) {
+		cfg := hs256Config()
+		cfg.AccessTokenTTL = -time.Minute
+		svc, _ := newJWTTestService(t, cfg)
+		tokens := loginTokens(t, svc, user)
+
+		_, err := svc.Check(newBearerContext(tokens.AccessToken))
+		require.ErrorIs(t, err, errAccessTokenExpired)
+	})
+
+	t.Run("Refresh Rotates Tokens", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		tokens := loginTokens(t, svc, user)
+
+		rotated, err := refreshTokens(svc, tokens.RefreshToken)
+		require.NoError(t, err)
+		require.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+		checked, err := svc.Check(newBearerContext(rotated.AccessToken))
+		require.NoError(t, err)
+		require.Equal(t, user.ID, checked.ID)
+
+		_, err = refreshTokens(svc, rotated.RefreshToken)
+		require.NoError(t, err)
+	})
+
+	t.Run("Reused Refresh Token Revokes Family", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		tokens := loginTokens(t, svc, user)
+
+		rotated, err := refreshTokens(svc, tokens.RefreshToken)
+		require.NoError(t, err)
+
+		// the old token shows up again, e.g. replayed by an attacker
+		_, err = refreshTokens(svc, tokens.RefreshToken)
+		require.ErrorIs(t, err, errRefreshTokenReused)
+
+		// the legitimate client is logged out as well
+		_, err = refreshTokens(svc, rotated.RefreshToken)
+		require.ErrorIs(t, err, errRefreshTokenInvalid)
+	})
+
+	t.Run("Refresh Updates Stored User", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		tokens := loginTokens(t, svc, &User{ID: 42, Email: "jane@example.com"})
+
+		c := newBearerContext(tokens.AccessToken)
+		current, err := svc.Check(c)
+		require.NoError(t, err)
+
+		current.SecondFactor = true
+		require.NoError(t, svc.Refresh(c, current))
+
+		updated, ok := GetTokensFromContext(c)
+		require.True(t, ok)
+		require.Empty(t, updated.RefreshToken, "the refresh token is not rotated")
+		checked, err := svc.Check(newBearerContext(updated.AccessToken))
+		require.NoError(t, err)
+		require.True(t, checked.SecondFactor)
+
+		// tokens issued later for the same login carry the update too
+		rotated, err := refreshTokens(svc, tokens.RefreshToken)
+		require.NoError(t, err)
+		checked, err = svc.Check(newBearerContext(rotated.AccessToken))
+		require.NoError(t, err)
+		require.True(t, checked.SecondFactor)
+	})
+
+	t.Run("Logout And LogoutAll Revoke Refresh Tokens", func(t *testing.T) {
+		svc, store := newJWTTestService(t, hs256Config())
+		first := loginTokens(t, svc, user)
+		second := loginTokens(t, svc, user)
+
+		require.NoError(t, svc.Logout(newBearerContext(first.AccessToken)))
+		_, err := refreshTokens(svc, first.RefreshToken)
+		require.ErrorIs(t, err, errRefreshTokenInvalid)
+
+		_, err = refreshTokens(svc, second.RefreshToken)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.LogoutAll(newBearerContext("").Request().Context(), user.ID))
+		for _, key := range store.Keys() {
+			require.NotContains(t, key, RefreshFamilyKeyPrefix)
+		}
+	})
+
+	t.Run("EdDSA Keys", func(t *testing.T) {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		require.NoError(t, err)
+
+		cfg := hs256Config()
+		cfg.Algorithm = "EdDSA"
+		cfg.Secret = ""
+		cfg.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		svc, _ := newJWTTestService(t, cfg)
+
+		tokens := loginTokens(t, svc, user)
+		checked, err := svc.Check(newBearerContext(tokens.AccessToken))
+		require.NoError(t, err)
+		require.Equal(t, user.ID, checked.ID)
+
+		// an HS256 token must not pass as EdDSA
+		hsSvc, _ := newJWTTestService(t, hs256Config())
+		hsTokens := loginTokens(t, hsSvc, user)
+		_, err = svc.Check(newBearerContext(hsTokens.AccessToken))
+		require.ErrorIs(t, err, errAccessTokenInvalid)
+	})
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/shared/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// LOGIN_FAILURES:<scope> -> failed password logins within the lockout window
+	LoginFailuresKeyPrefix = "LOGIN_FAILURES:"
+	// LOGIN_LOCK:<scope> -> set while logins of the scope are locked, the TTL is the remaining lockout
+	LoginLockKeyPrefix = "LOGIN_LOCK:"
+	// LOGIN_LOCKOUTS:<scope> -> consecutive lockouts, each one doubles the next lockout
+	LoginLockoutsKeyPrefix = "LOGIN_LOCKOUTS:"
+	// LOGIN_SPRAY:<IP> -> set of email hashes the IP failed to log in to within the lockout window
+	LoginSprayKeyPrefix = "LOGIN_SPRAY:"
+	// LOGIN_SPRAY_ALARM:<IP> -> set once the spraying alarm of the IP fired within the lockout window
+	LoginSprayAlarmKeyPrefix = "LOGIN_SPRAY_ALARM:"
+)
+
+// loginScopes returns the email and IP scopes a login attempt is counted in
+func loginScopes(c echo.Context, email string) []string {
+	return []string{
+		"EMAIL:" + strings.ToLower(email),
+		"IP:" + c.RealIP(),
+	}
+}
+
+// accountLockedErr reports a locked login, the message and Retry-After header carry the wait time
+func accountLockedErr(c echo.Context, retryAfter time.Duration) error {
+	seconds := int(retryAfter.Round(time.Second).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	// copied so that the arguments of concurrent requests don't mix
+	lockedErr := *errAccountLocked
+	return lockedErr.WithArgs(seconds)
+}
+
+// checkLoginLock rejects the attempt while the email or the client IP is locked
+func (s *service) checkLoginLock(c echo.Context, email string) error {
+	ctx := c.Request().Context()
+	scopes := loginScopes(c, email)
+
+	var retryAfter time.Duration
+	for _, scope := range scopes {
+		ttl, err := s.store.TTL(ctx, LoginLockKeyPrefix+scope)
+		if err != nil {
+			return errLoginLockCheck
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	if retryAfter > 0 {
+		return accountLockedErr(c, retryAfter)
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt in every scope and locks the ones over their limit.
+// Unknown emails are counted too so that lockouts don't reveal which accounts exist
+func (s *service) recordLoginFailure(c echo.Context, email string) error {
+	ctx := c.Request().Context()
+	cfg := s.authCfg.Lockout
+	scopes := loginScopes(c, email)
+	limits := []int{cfg.MaxAttemptsPerEmail, cfg.MaxAttemptsPerIP}
+	sprayKey := LoginSprayKeyPrefix + c.RealIP()
+
+	counts := make([]int64, len(scopes))
+	for i, scope := range scopes {
+		count, err := s.store.Incr(ctx, LoginFailuresKeyPrefix+scope, cfg.Window)
+		if err != nil {
+			s.logger.WarnWithContext(ctx, "failed to record failed login", s.logger.Err(err))
+			return nil
+		}
+		counts[i] = count
+	}
+	accounts, err := s.store.SAdd(ctx, sprayKey, utils.HashToken(strings.ToLower(email)), cfg.Window)
+	if err != nil {
+		s.logger.WarnWithContext(ctx, "failed to record failed login", s.logger.Err(err))
+		return nil
+	}
+
+	if accounts >= int64(cfg.SprayThreshold) {
+		s.alarmPasswordSpraying(ctx, c.RealIP(), accounts)
+	}
+
+	var retryAfter time.Duration
+	for i, scope := range scopes {
+		if counts[i] < int64(limits[i]) {
+			continue
+		}
+		duration, err := s.lockLogin(ctx, scope)
+		if err != nil {
+			s.logger.WarnWithContext(ctx, "failed to lock login", s.logger.Err(err))
+			continue
+		}
+		if duration > retryAfter {
+			retryAfter = duration
+		}
+	}
+	if retryAfter > 0 {
+		return accountLockedErr(c, retryAfter)
+	}
+	return nil
+}
+
+// lockLogin locks the scope, every consecutive lockout lasts twice as long as the previous one
+func (s *service) lockLogin(ctx context.Context, scope string) (time.Duration, error) {
+	cfg := s.authCfg.Lockout
+
+	lockouts, err := s.store.Incr(ctx, LoginLockoutsKeyPrefix+scope, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	duration := cfg.MaxDuration
+	if shift := lockouts - 1; shift < 32 && cfg.BaseDuration<<shift < cfg.MaxDuration {
+		duration = cfg.BaseDuration << shift
+	}
+
+	if err := s.store.Set(ctx, LoginLockKeyPrefix+scope, strconv.FormatInt(lockouts, 10), duration); err != nil {
+		return 0, err
+	}
+	if _, err := s.store.Del(ctx, LoginFailuresKeyPrefix+scope); err != nil {
+		return 0, err
+	}
+	// the escalation is forgotten once the scope stays quiet for MaxDuration after the lockout
+	if err := s.store.Expire(ctx, LoginLockoutsKeyPrefix+scope, duration+cfg.MaxDuration); err != nil {
+		return 0, err
+	}
+
+	s.logger.WarnWithContext(ctx, "login locked after too many failed attempts",
+		s.logger.String("scope", scope),
+		s.logger.String("duration", duration.String()),
+	)
+	return duration, nil
+}
+
+// clearLoginFailures resets the counters of an email after a successful login, the IP
+// counters are kept so that logging in to an own account doesn't reset a guessing attempt
+func (s *service) clearLoginFailures(c echo.Context, email string) {
+	ctx := c.Request().Context()
+	scope := loginScopes(c, email)[0]
+
+	if _, err := s.store.Del(ctx, LoginFailuresKeyPrefix+scope, LoginLockoutsKeyPrefix+scope); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to clear failed logins", s.logger.Err(err))
+	}
+}
+
+// alarmPasswordSpraying notifies once per window when an IP fails to log in to many accounts
+func (s *service) alarmPasswordSpraying(ctx context.Context, ip string, accounts int64) {
+	cfg := s.authCfg.Lockout
+
+	first, err := s.store.SetNX(ctx, LoginSprayAlarmKeyPrefix+ip, "1", cfg.Window)
+	if err != nil || !first {
+		return
+	}
+
+	s.logger.WarnWithContext(ctx, "password spraying suspected",
+		s.logger.String("ip", ip),
+		s.logger.Int("accounts", int(accounts)),
+	)
+	message := fmt.Sprintf("Password spraying suspected: IP %s failed to log in to %d accounts within %s",
+		ip, accounts, cfg.Window)
+	go s.alarmer.Alarm(message)
+}
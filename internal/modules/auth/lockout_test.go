@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/response"
+	"go-echo-template/internal/shared/utils"
+	authSqlc "go-echo-template/internal/storage/auth/sqlc"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// captureAlarmer hands alarms over a channel, they are raised from a goroutine
+type captureAlarmer struct {
+	messages chan string
+}
+
+func newCaptureAlarmer() *captureAlarmer {
+	return &captureAlarmer{messages: make(chan string, 10)}
+}
+
+func (a *captureAlarmer) Alarm(message string) {
+	a.messages <- message
+}
+
+func (r *fakeAuthRepo) GetUserTotp(ctx context.Context, userID int64) (*authSqlc.UserTotp, error) {
+	secret, ok := r.totpSecrets[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &authSqlc.UserTotp{UserID: userID, Secret: secret, ConfirmedAt: time.Now()}, nil
+}
+
+// loginFrom attempts a password login from the given client IP
+func loginFrom(svc *service, ip, email, password string) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(echo.HeaderXRealIP, ip)
+	rec := httptest.NewRecorder()
+	_, err := svc.apiLogin(echo.New().NewContext(req, rec), &LoginRequest{Email: email, Password: password})
+	return rec, err
+}
+
+// requireLocked asserts an ERR:AUTH_ACCOUNT_LOCKED error with the given Retry-After
+func requireLocked(t *testing.T, rec *httptest.ResponseRecorder, err error, retryAfter time.Duration) {
+	t.Helper()
+
+	var customErr *response.CustomErr
+	require.True(t, errors.As(err, &customErr), "expected a custom error, got %v", err)
+	require.Equal(t, errAccountLocked.Code, customErr.Code)
+	require.Equal(t, http.StatusTooManyRequests, customErr.Status)
+	require.Equal(t, fmt.Sprint(int(retryAfter.Seconds())), rec.Header().Get("Retry-After"))
+}
+
+func TestLoginLockout(t *testing.T) {
+	const password = "Passw0rd!"
+	hash, err := utils.HashPassword(password, testPasswordHash)
+	require.NoError(t, err)
+
+	newLockoutTestService := func(t *testing.T) (*service, *captureAlarmer) {
+		svc, _, _, alarmer, _ := newLoginTestService(t)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = hash
+		return svc, alarmer
+	}
+
+	t.Run("Email Is Locked After Repeated Failures", func(t *testing.T) {
+		svc, _ := newLockoutTestService(t)
+
+		for range 2 {
+			_, err := loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+			require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+		}
+		rec, err := loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+		requireLocked(t, rec, err, time.Minute)
+
+		// the right password doesn't help, not even from another IP
+		rec, err = loginFrom(svc, "198.51.100.2", "JANE@example.com", password)
+		requireLocked(t, rec, err, time.Minute)
+	})
+
+	t.Run("Lockouts Grow Exponentially Up To The Maximum", func(t *testing.T) {
+		svc, _, _, _, store := newLoginTestService(t)
+
+		for i, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+			// a new IP every round keeps the IP scope below its limit
+			ip := fmt.Sprintf("198.51.100.%d", i+1)
+
+			var rec *httptest.ResponseRecorder
+			var err error
+			for range 3 {
+				rec, err = loginFrom(svc, ip, "nobody@example.com", "wrong")
+			}
+			requireLocked(t, rec, err, expected)
+			store.FastForward(expected)
+		}
+	})
+
+	t.Run("Successful Login Resets Failures", func(t *testing.T) {
+		svc, _ := newLockoutTestService(t)
+
+		for range 2 {
+			_, err := loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+			require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+		}
+		_, err := loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		require.NoError(t, err)
+
+		_, err = loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+		require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+	})
+
+	t.Run("Second Factor Guesses Span Pending Logins", func(t *testing.T) {
+		svc, _ := newLockoutTestService(t)
+		secret, err := utils.GenerateTOTPSecret()
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).totpSecrets = map[int64]string{42: secret}
+
+		startLogin := func() string {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(echo.HeaderXRealIP, "198.51.100.1")
+			res, err := svc.apiLogin(echo.New().NewContext(req, httptest.NewRecorder()), &LoginRequest{Email: "jane@example.com", Password: password})
+			require.NoError(t, err)
+			require.True(t, res.TwoFactorRequired)
+			return res.TwoFactorToken
+		}
+
+		// every password check opens a new pending login, the guesses still add up per user
+		for range LoginTwoFactorMaxAttempts {
+			err := svc.apiLoginTwoFactor(newTestContext(), &LoginTwoFactorRequest{Token: startLogin(), Code: "000000"})
+			require.ErrorIs(t, err, errTOTPCodeInvalid)
+		}
+		code, err := utils.GenerateTOTP(secret, time.Now())
+		require.NoError(t, err)
+		err = svc.apiLoginTwoFactor(newTestContext(), &LoginTwoFactorRequest{Token: startLogin(), Code: code})
+		require.ErrorIs(t, err, errTwoFactorAttemptsExceeded)
+	})
+
+	t.Run("Password Alone Doesn't Reset Failures With Two Factor", func(t *testing.T) {
+		svc, _ := newLockoutTestService(t)
+		secret, err := utils.GenerateTOTPSecret()
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).totpSecrets = map[int64]string{42: secret}
+
+		for range 2 {
+			_, err := loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+			require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+		}
+		_, err = loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		require.NoError(t, err)
+
+		rec, err := loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+		requireLocked(t, rec, err, time.Minute)
+	})
+
+	t.Run("IP Is Locked Across Accounts", func(t *testing.T) {
+		svc, _ := newLockoutTestService(t)
+
+		for i := range 9 {
+			_, err := loginFrom(svc, "198.51.100.1", fmt.Sprintf("user%d@example.com", i), "wrong")
+			require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+		}
+		rec, err := loginFrom(svc, "198.51.100.1", "user9@example.com", "wrong")
+		requireLocked(t, rec, err, time.Minute)
+
+		rec, err = loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		requireLocked(t, rec, err, time.Minute)
+
+		// other clients are not affected
+		_, err = loginFrom(svc, "198.51.100.2", "jane@example.com", password)
+		require.NoError(t, err)
+	})
+
+	t.Run("Password Spraying Raises One Alarm", func(t *testing.T) {
+		svc, alarmer := newLockoutTestService(t)
+
+		for i := range 8 {
+			loginFrom(svc, "198.51.100.1", fmt.Sprintf("user%d@example.com", i), "wrong")
+		}
+
+		select {
+		case message := <-alarmer.messages:
+			require.Contains(t, message, "198.51.100.1")
+		case <-time.After(time.Second):
+			t.Fatal("no alarm raised")
+		}
+		select {
+		case message := <-alarmer.messages:
+			t.Fatalf("unexpected second alarm: %s", message)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestLoginAudit(t *testing.T) {
+	const password = "Passw0rd!"
+	hash, err := utils.HashPassword(password, testPasswordHash)
+	require.NoError(t, err)
+
+	svc, _, _, _, _ := newLoginTestService(t)
+	svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = hash
+	auditRepo := svc.storage.Audit.(*fakeAuditRepo)
+
+	_, err = loginFrom(svc, "198.51.100.1", "nobody@example.com", "wrong")
+	require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+	_, err = loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+	require.ErrorIs(t, err, shared.ErrSessionUnauthorized)
+	rec, err := loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(sessionCookieOf(t, rec))
+	require.NoError(t, svc.Logout(echo.New().NewContext(req, httptest.NewRecorder())))
+
+	require.Equal(t, []string{"auth.login_failed", "auth.login_failed", "auth.login", "auth.logout"}, auditRepo.actions())
+	require.False(t, auditRepo.events[0].TargetID.Valid, "unknown emails have no target")
+	require.Equal(t, int64(42), auditRepo.events[1].TargetID.Int64)
+	require.False(t, auditRepo.events[1].ActorID.Valid)
+	require.Equal(t, int64(42), auditRepo.events[3].ActorID.Int64)
+	require.Equal(t, "198.51.100.1", auditRepo.events[2].Ip)
+}
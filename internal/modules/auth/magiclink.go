@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MagicLinkExpire   = 15 * time.Minute
+	MagicLinkCooldown = time.Minute
+
+	magicLinkPath = "/magic-link"
+)
+
+// apiRequestMagicLink mails a single-use login link. Like the password reset, the
+// outcome is never revealed so the endpoint can't be used to enumerate registered emails.
+func (s *service) apiRequestMagicLink(c echo.Context, req *MagicLinkRequest) error {
+	return s.sendEmailToken(c, magicLinkToken, req.Email)
+}
+
+// apiConsumeMagicLink exchanges the link token for a session, users with two-factor
+// authentication still finish the login on /login/2fa
+func (s *service) apiConsumeMagicLink(c echo.Context, req *ConsumeMagicLinkRequest) (*LoginResponse, error) {
+	ctx := c.Request().Context()
+
+	userID, err := s.consumeEmailToken(ctx, magicLinkToken, req.Token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user may have been deleted since the link was sent
+	userRow, err := s.storage.Auth.GetUserById(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, errMagicLinkTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.storage.Auth.GetUserTotp(ctx, userRow.ID)
+	if err == nil {
+		return s.startTwoFactorLogin(c, userRow.ID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	user := &User{
+		ID:        userRow.ID,
+		Name:      userRow.Name,
+		Email:     userRow.Email,
+		Phone:     userRow.Phone.String,
+		Role:      userRow.Role,
+		CreatedAt: userRow.CreatedAt,
+		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+	}
+
+	return nil, s.Login(c, user)
+}
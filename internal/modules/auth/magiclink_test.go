@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"go-echo-template/internal/shared/i18n"
+	"go-echo-template/internal/shared/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+var magicLinkTokenPattern = regexp.MustCompile(`magic-link\?token=([0-9a-f]{64})`)
+
+// consumeMagicLink exchanges the token on a fresh request and returns the recorder
+func consumeMagicLink(svc *service, token string) (*httptest.ResponseRecorder, *LoginResponse, error) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	resData, err := svc.apiConsumeMagicLink(c, &ConsumeMagicLinkRequest{Token: token})
+	return rec, resData, err
+}
+
+func TestMagicLink(t *testing.T) {
+	t.Run("Unknown Email Sends Nothing", func(t *testing.T) {
+		svc, mailer, _, _, _ := newLoginTestService(t)
+
+		err := svc.apiRequestMagicLink(newTestContext(), &MagicLinkRequest{Email: "nobody@example.com"})
+		require.NoError(t, err, "unknown emails must not be revealed")
+		require.Empty(t, mailer.messages)
+	})
+
+	t.Run("Link Logs In Once", func(t *testing.T) {
+		svc, mailer, _, _, store := newLoginTestService(t)
+
+		require.NoError(t, svc.apiRequestMagicLink(newTestContext(), &MagicLinkRequest{Email: "jane@example.com"}))
+		require.Len(t, mailer.messages, 1)
+		require.Equal(t, "jane@example.com", mailer.messages[0].To)
+
+		match := magicLinkTokenPattern.FindStringSubmatch(mailer.messages[0].Body)
+		require.Len(t, match, 2, "mail should contain the login link")
+		token := match[1]
+
+		// only the token hash is stored
+		require.False(t, store.has(magicLinkToken.tokenKey(token)))
+		require.True(t, store.has(magicLinkToken.tokenKey(utils.HashToken(token))))
+
+		rec, resData, err := consumeMagicLink(svc, token)
+		require.NoError(t, err)
+		require.Nil(t, resData)
+
+		c, _ := newCookieContext(t, svc, sessionCookieOf(t, rec))
+		user, ok := GetUserFromContext(c)
+		require.True(t, ok)
+		require.Equal(t, int64(42), user.ID)
+
+		_, _, err = consumeMagicLink(svc, token)
+		require.ErrorIs(t, err, errMagicLinkTokenInvalid)
+	})
+
+	t.Run("Repeated Requests Are Throttled", func(t *testing.T) {
+		svc, mailer, _, _, store := newLoginTestService(t)
+
+		req := &MagicLinkRequest{Email: "jane@example.com"}
+		require.NoError(t, svc.apiRequestMagicLink(newTestContext(), req))
+		require.NoError(t, svc.apiRequestMagicLink(newTestContext(), req))
+		require.Len(t, mailer.messages, 1)
+
+		// after the cooldown a new link replaces the old one
+		store.FastForward(MagicLinkCooldown)
+		require.NoError(t, svc.apiRequestMagicLink(newTestContext(), req))
+		require.Len(t, mailer.messages, 2)
+
+		oldToken := magicLinkTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+		_, _, err := consumeMagicLink(svc, oldToken)
+		require.ErrorIs(t, err, errMagicLinkTokenInvalid)
+	})
+
+	t.Run("Expired Link Is Rejected", func(t *testing.T) {
+		svc, mailer, _, _, store := newLoginTestService(t)
+
+		require.NoError(t, svc.apiRequestMagicLink(newTestContext(), &MagicLinkRequest{Email: "jane@example.com"}))
+		token := magicLinkTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+
+		store.FastForward(MagicLinkExpire)
+		_, _, err := consumeMagicLink(svc, token)
+		require.ErrorIs(t, err, errMagicLinkTokenInvalid)
+	})
+
+	t.Run("Mail Follows The Request Locale", func(t *testing.T) {
+		svc, mailer, _, _, _ := newLoginTestService(t)
+
+		c := newTestContext()
+		c.Set("locale", i18n.EN_US)
+		require.NoError(t, svc.apiRequestMagicLink(c, &MagicLinkRequest{Email: "jane@example.com"}))
+		require.Equal(t, "Your login link", mailer.messages[0].Subject)
+	})
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"go-echo-template/internal/mail"
+	"go-echo-template/internal/shared/i18n"
+)
+
+// Mail Templates
+var (
+	// args: name, reset link, expiry in minutes
+	mailPasswordReset = &mail.Template{
+		Subject: map[i18n.Locale]string{
+			i18n.EN_US: "Reset your password",
+			i18n.TR_TR: "Şifrenizi sıfırlayın",
+		},
+		Body: map[i18n.Locale]string{
+			i18n.EN_US: "Hi %s,\n\n" +
+				"We received a request to reset your password. Use the link below to choose a new one:\n\n" +
+				"%s\n\n" +
+				"The link expires in %d minutes and can only be used once. " +
+				"If you didn't request a password reset, you can safely ignore this email.\n",
+			i18n.TR_TR: "Merhaba %s,\n\n" +
+				"Şifrenizi sıfırlamak için bir talep aldık. Yeni bir şifre belirlemek için aşağıdaki bağlantıyı kullanın:\n\n" +
+				"%s\n\n" +
+				"Bağlantı %d dakika içinde geçerliliğini yitirir ve yalnızca bir kez kullanılabilir. " +
+				"Bu talebi siz yapmadıysanız bu e-postayı görmezden gelebilirsiniz.\n",
+		},
+	}
+
+	// args: name, login link, expiry in minutes
+	mailMagicLink = &mail.Template{
+		Subject: map[i18n.Locale]string{
+			i18n.EN_US: "Your login link",
+			i18n.TR_TR: "Giriş bağlantınız",
+		},
+		Body: map[i18n.Locale]string{
+			i18n.EN_US: "Hi %s,\n\n" +
+				"Use the link below to log in to your account:\n\n" +
+				"%s\n\n" +
+				"The link expires in %d minutes and can only be used once. " +
+				"If you didn't request a login link, you can safely ignore this email.\n",
+			i18n.TR_TR: "Merhaba %s,\n\n" +
+				"Hesabınıza giriş yapmak için aşağıdaki bağlantıyı kullanın:\n\n" +
+				"%s\n\n" +
+				"Bağlantı %d dakika içinde geçerliliğini yitirir ve yalnızca bir kez kullanılabilir. " +
+				"Bu talebi siz yapmadıysanız bu e-postayı görmezden gelebilirsiniz.\n",
+		},
+	}
+
+	// args: name, verification link, expiry in hours
+	mailEmailVerification = &mail.Template{
+		Subject: map[i18n.Locale]string{
+			i18n.EN_US: "Verify your email address",
+			i18n.TR_TR: "E-posta adresinizi doğrulayın",
+		},
+		Body: map[i18n.Locale]string{
+			i18n.EN_US: "Hi %s,\n\n" +
+				"Please confirm that this is your email address by opening the link below:\n\n" +
+				"%s\n\n" +
+				"The link expires in %d hours. If you didn't create an account, you can safely ignore this email.\n",
+			i18n.TR_TR: "Merhaba %s,\n\n" +
+				"Bu e-posta adresinin size ait olduğunu onaylamak için aşağıdaki bağlantıyı açın:\n\n" +
+				"%s\n\n" +
+				"Bağlantı %d saat içinde geçerliliğini yitirir. Bir hesap oluşturmadıysanız bu e-postayı görmezden gelebilirsiniz.\n",
+		},
+	}
+
+	// args: name, confirmation link, expiry in minutes
+	mailEmailChangeConfirm = &mail.Template{
+		Subject: map[i18n.Locale]string{
+			i18n.EN_US: "Confirm your new email address",
+			i18n.TR_TR: "Yeni e-posta adresinizi onaylayın",
+		},
+		Body: map[i18n.Locale]string{
+			i18n.EN_US: "Hi %s,\n\n" +
+				"Please confirm that you want to use this email address for your account by opening the link below:\n\n" +
+				"%s\n\n" +
+				"The link expires in %d minutes and can only be used once. " +
+				"If you didn't request this change, you can safely ignore this email.\n",
+			i18n.TR_TR: "Merhaba %s,\n\n" +
+				"Bu e-posta adresini hesabınız için kullanmak istediğinizi onaylamak için aşağıdaki bağlantıyı açın:\n\n" +
+				"%s\n\n" +
+				"Bağlantı %d dakika içinde geçerliliğini yitirir ve yalnızca bir kez kullanılabilir. " +
+				"Bu değişikliği siz talep etmediyseniz bu e-postayı görmezden gelebilirsiniz.\n",
+		},
+	}
+
+	// args: name, new email
+	mailEmailChangeNotice = &mail.Template{
+		Subject: map[i18n.Locale]string{
+			i18n.EN_US: "Your email address is about to change",
+			i18n.TR_TR: "E-posta adresiniz değiştirilmek üzere",
+		},
+		Body: map[i18n.Locale]string{
+			i18n.EN_US: "Hi %s,\n\n" +
+				"A change of your account's email address to %s was requested. " +
+				"It takes effect once the new address is confirmed.\n\n" +
+				"If you didn't request this change, change your password and log out of all sessions right away.\n",
+			i18n.TR_TR: "Merhaba %s,\n\n" +
+				"Hesabınızın e-posta adresinin %s olarak değiştirilmesi talep edildi. " +
+				"Değişiklik yeni adres onaylandığında geçerli olur.\n\n" +
+				"Bu değişikliği siz talep etmediyseniz hemen şifrenizi değiştirin ve tüm oturumlardan çıkış yapın.\n",
+		},
+	}
+)
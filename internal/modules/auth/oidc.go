@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/utils"
+	"go-echo-template/internal/storage"
+	"go-echo-template/internal/storage/auth/sqlc"
+	storageSession "go-echo-template/internal/storage/session"
+	userSqlc "go-echo-template/internal/storage/user/sqlc"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+const (
+	OIDCStateExpire     = 10 * time.Minute
+	OIDCStateCookieName = "oidc_state"
+
+	// OIDC_STATE:<state hash> -> provider, nonce and PKCE verifier of a pending login
+	OIDCStateKeyPrefix = "OIDC_STATE:"
+)
+
+// oidcState is what the callback needs to finish the authorization code flow
+type oidcState struct {
+	Provider    string `json:"provider"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirectURL"`
+}
+
+// oidcClaims are the ID token claims used to find or create the local user
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// oidcProvider discovers the provider configuration on first use so that an
+// unreachable identity provider doesn't keep the server from starting
+type oidcProvider struct {
+	name string
+	cfg  *config.OIDCProviderConfig
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProviders(cfg *config.OIDCConfig) map[string]*oidcProvider {
+	providers := make(map[string]*oidcProvider, len(cfg.Providers))
+	for name, providerCfg := range cfg.Providers {
+		providers[name] = &oidcProvider{name: name, cfg: providerCfg}
+	}
+	return providers
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	// the provider keeps the context for fetching signing keys later, it must outlive the request
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, p.cfg.Scopes...),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth2, p.verifier, nil
+}
+
+// apiStartOIDCLogin returns the provider URL the browser should be sent to
+func (s *service) apiStartOIDCLogin(c echo.Context, req *StartOIDCLoginRequest) (string, error) {
+	ctx := c.Request().Context()
+
+	provider, ok := s.oidcProviders[req.Provider]
+	if !ok {
+		return "", errOIDCProviderNotFound
+	}
+
+	oauth2Cfg, _, err := provider.discover(ctx)
+	if err != nil {
+		s.logger.ErrorWithContext(ctx, "oidc discovery failed", s.logger.String("provider", provider.name), s.logger.Err(err))
+		return "", errOIDCProviderUnavailable
+	}
+
+	state, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", errOIDCGenState
+	}
+	nonce, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", errOIDCGenState
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	// the callback lives next to this route, whichever group the handler is mounted on
+	startPath := c.Request().URL.Path
+	redirectURL := s.cfg.BaseURL + startPath + "/callback"
+
+	stateJSON, err := json.Marshal(&oidcState{
+		Provider:    provider.name,
+		Nonce:       nonce,
+		Verifier:    verifier,
+		RedirectURL: redirectURL,
+	})
+	if err != nil {
+		return "", errOIDCStore
+	}
+	if err := s.store.Set(ctx, OIDCStateKeyPrefix+utils.HashToken(state), string(stateJSON), OIDCStateExpire); err != nil {
+		return "", errOIDCStore
+	}
+
+	// binds the flow to this browser, a callback URL started elsewhere is rejected
+	c.SetCookie(&http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    state,
+		Path:     startPath,
+		MaxAge:   int(OIDCStateExpire.Seconds()),
+		HttpOnly: true,
+		Secure:   s.cfg.IsProduction(),
+		// the callback is a top-level navigation coming from the provider's site
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return oauth2Cfg.AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("redirect_uri", redirectURL),
+	), nil
+}
+
+// apiFinishOIDCLogin returns the URL the browser should land on after the login
+func (s *service) apiFinishOIDCLogin(c echo.Context, req *FinishOIDCLoginRequest) (string, error) {
+	ctx := c.Request().Context()
+
+	provider, ok := s.oidcProviders[req.Provider]
+	if !ok {
+		return "", errOIDCProviderNotFound
+	}
+
+	if req.Error != "" {
+		return "", errOIDCDenied
+	}
+
+	cookie, err := c.Cookie(OIDCStateCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.State)) != 1 {
+		return "", errOIDCStateInvalid
+	}
+	s.clearOIDCStateCookie(c)
+
+	stateJSON, err := s.store.GetDel(ctx, OIDCStateKeyPrefix+utils.HashToken(req.State))
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return "", errOIDCStateInvalid
+	}
+	if err != nil {
+		return "", errOIDCStore
+	}
+
+	var pending oidcState
+	if err := json.Unmarshal([]byte(stateJSON), &pending); err != nil || pending.Provider != provider.name {
+		return "", errOIDCStateInvalid
+	}
+
+	oauth2Cfg, verifier, err := provider.discover(ctx)
+	if err != nil {
+		s.logger.ErrorWithContext(ctx, "oidc discovery failed", s.logger.String("provider", provider.name), s.logger.Err(err))
+		return "", errOIDCProviderUnavailable
+	}
+
+	token, err := oauth2Cfg.Exchange(
+		ctx,
+		req.Code,
+		oauth2.VerifierOption(pending.Verifier),
+		oauth2.SetAuthURLParam("redirect_uri", pending.RedirectURL),
+	)
+	if err != nil {
+		s.logger.WarnWithContext(ctx, "oidc code exchange failed", s.logger.String("provider", provider.name), s.logger.Err(err))
+		return "", errOIDCExchange
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", errOIDCTokenInvalid
+	}
+
+	// checks the signature against the provider's JWKS, the issuer, audience and expiry
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		s.logger.WarnWithContext(ctx, "oidc id token rejected", s.logger.String("provider", provider.name), s.logger.Err(err))
+		return "", errOIDCTokenInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(pending.Nonce)) != 1 {
+		return "", errOIDCTokenInvalid
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return "", errOIDCTokenInvalid
+	}
+
+	userID, err := s.resolveOIDCUser(c, provider.name, idToken.Subject, &claims)
+	if err != nil {
+		return "", err
+	}
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, userID)
+	if err == sql.ErrNoRows {
+		return "", shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the provider only replaces the password, the second factor is still required
+	_, err = s.storage.Auth.GetUserTotp(ctx, userRow.ID)
+	if err == nil {
+		pendingLogin, err := s.startTwoFactorLogin(c, userRow.ID)
+		if err != nil {
+			return "", err
+		}
+		return s.cfg.BaseURL + "/login/2fa#token=" + url.QueryEscape(pendingLogin.TwoFactorToken), nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	user := &User{
+		ID:        userRow.ID,
+		Name:      userRow.Name,
+		Email:     userRow.Email,
+		Phone:     userRow.Phone.String,
+		Role:      userRow.Role,
+		CreatedAt: userRow.CreatedAt,
+		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+	}
+
+	if err := s.Login(c, user); err != nil {
+		return "", err
+	}
+
+	// token clients receive their tokens in the fragment, it never reaches a server
+	if tokens, ok := GetTokensFromContext(c); ok {
+		fragment := url.Values{
+			"access_token":  {tokens.AccessToken},
+			"refresh_token": {tokens.RefreshToken},
+			"token_type":    {tokens.TokenType},
+			"expires_in":    {strconv.Itoa(tokens.ExpiresIn)},
+		}
+		return s.cfg.BaseURL + "/#" + fragment.Encode(), nil
+	}
+	return s.cfg.BaseURL + "/", nil
+}
+
+// resolveOIDCUser finds the local user of an external identity, linking or creating one when needed
+func (s *service) resolveOIDCUser(c echo.Context, provider, subject string, claims *oidcClaims) (int64, error) {
+	ctx := c.Request().Context()
+
+	identity, err := s.storage.Auth.GetUserIdentity(ctx, sqlc.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  subject,
+	})
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	identityParams := sqlc.CreateUserIdentityParams{
+		Provider: provider,
+		Subject:  subject,
+		Email:    sql.NullString{String: claims.Email, Valid: claims.Email != ""},
+	}
+
+	// a logged in user is linking another way to sign in
+	if current, err := s.Check(c); err == nil {
+		identityParams.UserID = current.ID
+		return current.ID, s.storage.Auth.CreateUserIdentity(ctx, identityParams)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, errOIDCEmailNotVerified
+	}
+
+	userRow, err := s.storage.Auth.GetUserByEmail(ctx, claims.Email)
+	if err == nil {
+		// linking to an unverified account would hand it to whoever pre-registered the address
+		if !userRow.EmailVerifiedAt.Valid {
+			return 0, errOIDCEmailInUse
+		}
+		identityParams.UserID = userRow.ID
+		return userRow.ID, s.storage.Auth.CreateUserIdentity(ctx, identityParams)
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	// first login, the account gets an unusable random password until one is set through a reset
+	randomPassword, err := utils.GenerateToken(32)
+	if err != nil {
+		return 0, errOIDCGenState
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword, s.authCfg.PasswordHash)
+	if err != nil {
+		return 0, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	var userID int64
+	err = s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		userID, err = storageTx.User.CreateUser(ctx, userSqlc.CreateUserParams{
+			Name:     name,
+			Email:    claims.Email,
+			Role:     shared.RoleCustomer,
+			Password: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := storageTx.User.MarkUserEmailVerified(ctx, userSqlc.MarkUserEmailVerifiedParams{
+			ID:    userID,
+			Email: claims.Email,
+		}); err != nil {
+			return err
+		}
+
+		identityParams.UserID = userID
+		return storageTx.Auth.CreateUserIdentity(ctx, identityParams)
+	})
+	return userID, err
+}
+
+func (s *service) clearOIDCStateCookie(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    "",
+		Path:     strings.TrimSuffix(c.Request().URL.Path, "/callback"),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.cfg.IsProduction(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
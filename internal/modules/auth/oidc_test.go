@@ -0,0 +1,405 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/storage"
+	storageAuth "go-echo-template/internal/storage/auth"
+	authSqlc "go-echo-template/internal/storage/auth/sqlc"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stubClientID     = "test-client"
+	stubClientSecret = "test-secret"
+)
+
+// stubAuthorization is what the stub provider remembers about an issued code
+type stubAuthorization struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	claims      map[string]any
+}
+
+// stubIdP is a minimal OpenID Connect provider: discovery, JWKS and a token endpoint
+type stubIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*stubAuthorization
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &stubIdP{key: key, codes: make(map[string]*stubAuthorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := idp.server.URL
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer,
+			"authorization_endpoint":                issuer + "/authorize",
+			"token_endpoint":                        issuer + "/token",
+			"jwks_uri":                              issuer + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	publicKey := &key.PublicKey
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: publicKey, KeyID: "stub", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", idp.handleToken)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (p *stubIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if clientID, clientSecret, ok := r.BasicAuth(); !ok || clientID != stubClientID || clientSecret != stubClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	authorization, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	if r.PostFormValue("redirect_uri") != authorization.redirectURI {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	// PKCE: the verifier must hash to the challenge sent with the authorization request
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != authorization.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "stub-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.signIDToken(authorization.nonce, authorization.claims),
+	})
+}
+
+func (p *stubIdP) signIDToken(nonce string, claims map[string]any) string {
+	payload := map[string]any{
+		"iss":   p.server.URL,
+		"aud":   stubClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	payloadJSON, _ := json.Marshal(payload)
+
+	signer, _ := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: p.key, KeyID: "stub"}},
+		nil,
+	)
+	signed, _ := signer.Sign(payloadJSON)
+	token, _ := signed.CompactSerialize()
+	return token
+}
+
+// authorize plays the user consenting on the provider, it returns the code and state of the redirect
+func (p *stubIdP) authorize(t *testing.T, authURL string, claims map[string]any) (string, string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := u.Query()
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.Equal(t, "http://localhost:8080/api/v1/auth/oidc/stub/callback", query.Get("redirect_uri"))
+	require.NotEmpty(t, query.Get("nonce"))
+
+	code := "code-" + query.Get("state")[:8]
+	p.mu.Lock()
+	p.codes[code] = &stubAuthorization{
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		redirectURI: query.Get("redirect_uri"),
+		claims:      claims,
+	}
+	p.mu.Unlock()
+	return code, query.Get("state")
+}
+
+// fakeOIDCAuthRepo keeps users and identities in memory
+type fakeOIDCAuthRepo struct {
+	storageAuth.AuthRepository
+	users      map[int64]*authSqlc.GetUserByIdRow
+	identities map[string]int64
+}
+
+func (r *fakeOIDCAuthRepo) GetUserById(ctx context.Context, userID int64) (*authSqlc.GetUserByIdRow, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (r *fakeOIDCAuthRepo) GetUserByEmail(ctx context.Context, email string) (*authSqlc.GetUserByEmailRow, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			row := authSqlc.GetUserByEmailRow(*user)
+			return &row, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeOIDCAuthRepo) GetUserTotp(ctx context.Context, userID int64) (*authSqlc.UserTotp, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeOIDCAuthRepo) GetUserIdentity(ctx context.Context, params authSqlc.GetUserIdentityParams) (*authSqlc.UserIdentity, error) {
+	userID, ok := r.identities[params.Provider+":"+params.Subject]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &authSqlc.UserIdentity{UserID: userID, Provider: params.Provider, Subject: params.Subject}, nil
+}
+
+func (r *fakeOIDCAuthRepo) CreateUserIdentity(ctx context.Context, params authSqlc.CreateUserIdentityParams) error {
+	r.identities[params.Provider+":"+params.Subject] = params.UserID
+	return nil
+}
+
+func newOIDCTestService(t *testing.T, idp *stubIdP) (*service, *fakeOIDCAuthRepo, *testStore) {
+	t.Helper()
+
+	store := newTestStore()
+
+	serverCfg := &config.ServerConfig{Environment: "local", BaseURL: "http://localhost:8080"}
+	logger, err := log.NewCustomLogger(serverCfg)
+	require.NoError(t, err)
+
+	authRepo := &fakeOIDCAuthRepo{
+		users: map[int64]*authSqlc.GetUserByIdRow{
+			42: {ID: 42, Name: "Jane", Email: "jane@example.com", Role: "user", EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true}},
+			43: {ID: 43, Name: "John", Email: "john@example.com", Role: "user"},
+		},
+		identities: map[string]int64{"stub:jane-subject": 42},
+	}
+
+	authCfg := &config.AuthConfig{
+		Session: &config.SessionConfig{
+			SESSION_SECRET:  "test-session-secret",
+			CookieSameSite:  http.SameSiteStrictMode,
+			IdleTimeout:     24 * time.Hour,
+			AbsoluteTimeout: 720 * time.Hour,
+			SlideInterval:   5 * time.Minute,
+		},
+		WebAuthn: &config.WebAuthnConfig{RPID: "localhost", RPDisplayName: "Test", RPOrigins: []string{"http://localhost"}},
+		OIDC: &config.OIDCConfig{Providers: map[string]*config.OIDCProviderConfig{
+			"stub": {Issuer: idp.server.URL, ClientID: stubClientID, ClientSecret: stubClientSecret, Scopes: []string{"email", "profile"}},
+		}},
+		PasswordHash: testPasswordHash,
+		SessionLimit: &config.SessionLimitConfig{},
+	}
+
+	svc := NewSessionCookieService(serverCfg, authCfg, logger, newCaptureAlarmer(), store, storage.NewStorage(nil, nil, authRepo, &fakeAuditRepo{}), &captureMailer{})
+	return svc.(*service), authRepo, store
+}
+
+// startOIDC runs the start endpoint and returns the provider URL and the state cookie
+func startOIDC(t *testing.T, svc *service) (string, *http.Cookie) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/stub", nil), rec)
+
+	authURL, err := svc.apiStartOIDCLogin(c, &StartOIDCLoginRequest{Provider: "stub"})
+	require.NoError(t, err)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == OIDCStateCookieName {
+			return authURL, cookie
+		}
+	}
+	t.Fatal("state cookie not set")
+	return "", nil
+}
+
+// finishOIDC runs the callback endpoint, it returns the redirect URL and the response cookies
+func finishOIDC(svc *service, stateCookie *http.Cookie, code, state string) (string, []*http.Cookie, error) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/stub/callback", nil)
+	if stateCookie != nil {
+		req.AddCookie(stateCookie)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	redirectURL, err := svc.apiFinishOIDCLogin(c, &FinishOIDCLoginRequest{Provider: "stub", Code: code, State: state})
+	return redirectURL, rec.Result().Cookies(), err
+}
+
+func hasSessionCookie(cookies []*http.Cookie) bool {
+	for _, cookie := range cookies {
+		if cookie.Name == SessionCookieName && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOIDCLogin(t *testing.T) {
+	t.Run("Linked Identity Logs In", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, _, store := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{"sub": "jane-subject"})
+
+		redirectURL, cookies, err := finishOIDC(svc, stateCookie, code, state)
+		require.NoError(t, err)
+		require.Equal(t, "http://localhost:8080/", redirectURL)
+		require.True(t, hasSessionCookie(cookies))
+		require.True(t, store.has(UserSessionsKeyPrefix+"42"), "login must go through the regular session storage")
+	})
+
+	t.Run("Verified Email Links Existing Account", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, authRepo, _ := newOIDCTestService(t, idp)
+		delete(authRepo.identities, "stub:jane-subject")
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{
+			"sub":            "new-subject",
+			"email":          "jane@example.com",
+			"email_verified": true,
+		})
+
+		_, cookies, err := finishOIDC(svc, stateCookie, code, state)
+		require.NoError(t, err)
+		require.True(t, hasSessionCookie(cookies))
+		require.Equal(t, int64(42), authRepo.identities["stub:new-subject"])
+	})
+
+	t.Run("Unverified Local Account Is Not Linked", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, authRepo, _ := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{
+			"sub":            "john-subject",
+			"email":          "john@example.com",
+			"email_verified": true,
+		})
+
+		_, _, err := finishOIDC(svc, stateCookie, code, state)
+		require.ErrorIs(t, err, errOIDCEmailInUse)
+		require.NotContains(t, authRepo.identities, "stub:john-subject")
+	})
+
+	t.Run("Unverified Provider Email Is Rejected", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, _, _ := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{
+			"sub":            "other-subject",
+			"email":          "jane@example.com",
+			"email_verified": false,
+		})
+
+		_, _, err := finishOIDC(svc, stateCookie, code, state)
+		require.ErrorIs(t, err, errOIDCEmailNotVerified)
+	})
+
+	t.Run("State Must Match Cookie And Is Single Use", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, _, _ := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{"sub": "jane-subject"})
+
+		// a callback URL opened in another browser
+		_, _, err := finishOIDC(svc, nil, code, state)
+		require.ErrorIs(t, err, errOIDCStateInvalid)
+
+		_, _, err = finishOIDC(svc, stateCookie, code, state)
+		require.NoError(t, err)
+
+		_, _, err = finishOIDC(svc, stateCookie, code, state)
+		require.ErrorIs(t, err, errOIDCStateInvalid)
+	})
+
+	t.Run("Expired State Is Rejected", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, _, store := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{"sub": "jane-subject"})
+
+		store.FastForward(OIDCStateExpire)
+		_, _, err := finishOIDC(svc, stateCookie, code, state)
+		require.ErrorIs(t, err, errOIDCStateInvalid)
+	})
+
+	t.Run("Nonce Mismatch Is Rejected", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, _, _ := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{"sub": "jane-subject"})
+		idp.codes[code].nonce = "replayed-nonce"
+
+		_, cookies, err := finishOIDC(svc, stateCookie, code, state)
+		require.ErrorIs(t, err, errOIDCTokenInvalid)
+		require.False(t, hasSessionCookie(cookies))
+	})
+
+	t.Run("Token Signed With Unknown Key Is Rejected", func(t *testing.T) {
+		idp := newStubIdP(t)
+		svc, _, _ := newOIDCTestService(t, idp)
+
+		authURL, stateCookie := startOIDC(t, svc)
+		code, state := idp.authorize(t, authURL, map[string]any{"sub": "jane-subject"})
+
+		// the JWKS still publishes the old public key
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		idp.key = otherKey
+		_, _, err = finishOIDC(svc, stateCookie, code, state)
+		require.ErrorIs(t, err, errOIDCTokenInvalid)
+	})
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/shared/utils"
+	"go-echo-template/internal/storage"
+	userSqlc "go-echo-template/internal/storage/user/sqlc"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	PasswordResetExpire   = 30 * time.Minute
+	PasswordResetCooldown = time.Minute
+
+	passwordResetPath = "/reset-password"
+)
+
+// apiForgotPassword mails a single-use reset link. The outcome is never revealed
+// to the caller so the endpoint can't be used to enumerate registered emails.
+func (s *service) apiForgotPassword(c echo.Context, req *ForgotPasswordRequest) error {
+	return s.sendEmailToken(c, passwordResetToken, req.Email)
+}
+
+// apiResetPassword consumes the reset token, sets the new password and
+// revokes every session of the user
+func (s *service) apiResetPassword(c echo.Context, req *ResetPasswordRequest) error {
+	ctx := c.Request().Context()
+
+	userID, err := s.consumeEmailToken(ctx, passwordResetToken, req.Token, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := s.UpdatePassword(c, userID, req.Password, audit.ActionUserPasswordReset); err != nil {
+		return err
+	}
+
+	return s.LogoutAll(ctx, userID)
+}
+
+// UpdatePassword replaces the password of a user after checking it against the password
+// history, the replaced password moves to the history and the change is audited as action
+func (s *service) UpdatePassword(c echo.Context, userID int64, password string, action audit.Action) error {
+	ctx := c.Request().Context()
+
+	user, err := s.storage.Auth.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPasswordHistory(ctx, userID, user.Password, password); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(password, s.authCfg.PasswordHash)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		if err := storageTx.User.UpdateUserPassword(ctx, userSqlc.UpdateUserPasswordParams{
+			ID:       userID,
+			Password: hash,
+		}); err != nil {
+			return err
+		}
+
+		// the replaced password moves to the history, which keeps the ones before it
+		if keep := s.authCfg.PasswordHistory - 1; keep > 0 {
+			if err := storageTx.User.CreatePasswordHistory(ctx, userSqlc.CreatePasswordHistoryParams{
+				UserID:   userID,
+				Password: user.Password,
+			}); err != nil {
+				return err
+			}
+			if err := storageTx.User.PrunePasswordHistory(ctx, userSqlc.PrunePasswordHistoryParams{
+				UserID: userID,
+				Keep:   int32(keep),
+			}); err != nil {
+				return err
+			}
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    userID,
+			Action:     action,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+		})
+	})
+}
+
+// checkPasswordHistory rejects a password matching the current one or one of the
+// latest in the history
+func (s *service) checkPasswordHistory(ctx context.Context, userID int64, current, password string) error {
+	size := s.authCfg.PasswordHistory
+	if size <= 0 {
+		return nil
+	}
+
+	hashes := []string{current}
+	if size > 1 {
+		previous, err := s.storage.User.ListPasswordHistory(ctx, userSqlc.ListPasswordHistoryParams{
+			UserID: userID,
+			Limit:  int32(size - 1),
+		})
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	for _, hash := range hashes {
+		if utils.CheckPasswordHash(password, hash) {
+			return errPasswordReused.WithArgs(size)
+		}
+	}
+	return nil
+}
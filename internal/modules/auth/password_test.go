@@ -0,0 +1,364 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/mail"
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/utils"
+	"go-echo-template/internal/storage"
+	storageAudit "go-echo-template/internal/storage/audit"
+	auditSqlc "go-echo-template/internal/storage/audit/sqlc"
+	storageAuth "go-echo-template/internal/storage/auth"
+	authSqlc "go-echo-template/internal/storage/auth/sqlc"
+	storageSession "go-echo-template/internal/storage/session"
+	storageUser "go-echo-template/internal/storage/user"
+	userSqlc "go-echo-template/internal/storage/user/sqlc"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// captureMailer keeps sent messages in memory instead of delivering them
+type captureMailer struct {
+	messages []*mail.Message
+}
+
+func (m *captureMailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// fakeAuditRepo keeps the written audit events in memory
+type fakeAuditRepo struct {
+	storageAudit.AuditRepository
+	events []auditSqlc.CreateAuditEventParams
+}
+
+func (r *fakeAuditRepo) CreateAuditEvent(ctx context.Context, params auditSqlc.CreateAuditEventParams) error {
+	r.events = append(r.events, params)
+	return nil
+}
+
+// actions returns the actions of the written events in order
+func (r *fakeAuditRepo) actions() []string {
+	actions := make([]string, len(r.events))
+	for i, event := range r.events {
+		actions[i] = event.Action
+	}
+	return actions
+}
+
+func (r *fakeAuditRepo) WithTx(tx *sql.Tx) storageAudit.AuditRepository {
+	return r
+}
+
+// fakeAuthRepo serves users from memory, unused methods panic through the nil embedded interface
+type fakeAuthRepo struct {
+	storageAuth.AuthRepository
+	users map[string]*authSqlc.GetUserByEmailRow
+	// totpSecrets are the confirmed TOTP secrets by user ID
+	totpSecrets map[int64]string
+}
+
+func (r *fakeAuthRepo) GetUserByEmail(ctx context.Context, email string) (*authSqlc.GetUserByEmailRow, error) {
+	user, ok := r.users[email]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (r *fakeAuthRepo) GetUserById(ctx context.Context, userID int64) (*authSqlc.GetUserByIdRow, error) {
+	for _, user := range r.users {
+		if user.ID == userID {
+			return &authSqlc.GetUserByIdRow{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role, Password: user.Password}, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeAuthRepo) WithTx(tx *sql.Tx) storageAuth.AuthRepository {
+	return r
+}
+
+type fakeUserRepo struct {
+	storageUser.UserRepository
+	passwords map[int64]string
+	// history are the previous password hashes by user ID, newest first
+	history map[int64][]string
+}
+
+func (r *fakeUserRepo) WithTx(tx *sql.Tx) storageUser.UserRepository {
+	return r
+}
+
+func (r *fakeUserRepo) ListPasswordHistory(ctx context.Context, params userSqlc.ListPasswordHistoryParams) ([]string, error) {
+	history := r.history[params.UserID]
+	return history[:min(len(history), int(params.Limit))], nil
+}
+
+func (r *fakeUserRepo) CreatePasswordHistory(ctx context.Context, params userSqlc.CreatePasswordHistoryParams) error {
+	r.history[params.UserID] = append([]string{params.Password}, r.history[params.UserID]...)
+	return nil
+}
+
+func (r *fakeUserRepo) PrunePasswordHistory(ctx context.Context, params userSqlc.PrunePasswordHistoryParams) error {
+	history := r.history[params.UserID]
+	r.history[params.UserID] = history[:min(len(history), int(params.Keep))]
+	return nil
+}
+
+// noopTxConnector opens connections whose transactions do nothing, so that storage.WithTx
+// runs against the in-memory repos
+type noopTxConnector struct{}
+
+func (noopTxConnector) Connect(context.Context) (driver.Conn, error) { return noopTxConn{}, nil }
+func (noopTxConnector) Driver() driver.Driver                        { return nil }
+
+type noopTxConn struct{}
+
+func (noopTxConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("no statements") }
+func (noopTxConn) Close() error                        { return nil }
+func (noopTxConn) Begin() (driver.Tx, error)           { return noopTxConn{}, nil }
+func (noopTxConn) Commit() error                       { return nil }
+func (noopTxConn) Rollback() error                     { return nil }
+
+func (r *fakeUserRepo) UpdateUserPassword(ctx context.Context, params userSqlc.UpdateUserPasswordParams) error {
+	r.passwords[params.ID] = params.Password
+	return nil
+}
+
+func (r *fakeUserRepo) RehashUserPassword(ctx context.Context, params userSqlc.RehashUserPasswordParams) (int64, error) {
+	r.passwords[params.ID] = params.NewPassword
+	return 1, nil
+}
+
+// testStore is the in-memory session store with a clock the tests move forward
+type testStore struct {
+	*storageSession.MemoryStore
+	now time.Time
+}
+
+func newTestStore() *testStore {
+	store := &testStore{now: time.Now()}
+	store.MemoryStore = storageSession.NewMemoryStore(storageSession.WithClock(func() time.Time { return store.now }))
+	return store
+}
+
+func (s *testStore) FastForward(d time.Duration) {
+	s.now = s.now.Add(d)
+}
+
+// has reports whether the key exists and hasn't expired
+func (s *testStore) has(key string) bool {
+	exists, err := s.Exists(context.Background(), key)
+	return err == nil && exists
+}
+
+var resetTokenPattern = regexp.MustCompile(`token=([0-9a-f]{64})`)
+
+// testPasswordHash keeps argon2id cheap enough for tests
+var testPasswordHash = &utils.PasswordHashParams{
+	Algorithm:         utils.PasswordHashArgon2id,
+	Argon2Memory:      64,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+}
+
+func newPasswordResetTestService(t *testing.T) (*service, *captureMailer, *fakeUserRepo, *testStore) {
+	svc, mailer, userRepo, _, store := newLoginTestService(t)
+	return svc, mailer, userRepo, store
+}
+
+func newLoginTestService(t *testing.T) (*service, *captureMailer, *fakeUserRepo, *captureAlarmer, *testStore) {
+	t.Helper()
+
+	store := newTestStore()
+
+	serverCfg := &config.ServerConfig{Environment: "local", BaseURL: "http://localhost:8080"}
+	logger, err := log.NewCustomLogger(serverCfg)
+	require.NoError(t, err)
+
+	authRepo := &fakeAuthRepo{users: map[string]*authSqlc.GetUserByEmailRow{
+		"jane@example.com": {ID: 42, Name: "Jane", Email: "jane@example.com", Role: "user"},
+	}}
+	userRepo := &fakeUserRepo{passwords: map[int64]string{}, history: map[int64][]string{}}
+	mailer := &captureMailer{}
+	alarmer := newCaptureAlarmer()
+
+	svc := NewSessionCookieService(serverCfg, &config.AuthConfig{
+		Session: &config.SessionConfig{
+			SESSION_SECRET:  "test-session-secret",
+			CookieSameSite:  http.SameSiteStrictMode,
+			IdleTimeout:     24 * time.Hour,
+			AbsoluteTimeout: 720 * time.Hour,
+			SlideInterval:   5 * time.Minute,
+		},
+		WebAuthn:     &config.WebAuthnConfig{RPID: "localhost", RPDisplayName: "Test", RPOrigins: []string{"http://localhost"}},
+		OIDC:         &config.OIDCConfig{},
+		PasswordHash: testPasswordHash,
+		SessionLimit: &config.SessionLimitConfig{},
+		Lockout: &config.LockoutConfig{
+			MaxAttemptsPerEmail: 3,
+			MaxAttemptsPerIP:    10,
+			Window:              15 * time.Minute,
+			BaseDuration:        time.Minute,
+			MaxDuration:         4 * time.Minute,
+			SprayThreshold:      5,
+		},
+	}, logger, alarmer, store, storage.NewStorage(sql.OpenDB(noopTxConnector{}), userRepo, authRepo, &fakeAuditRepo{}), mailer)
+	return svc.(*service), mailer, userRepo, alarmer, store
+}
+
+func newTestContext() echo.Context {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestPasswordReset(t *testing.T) {
+	t.Run("Unknown Email Sends Nothing", func(t *testing.T) {
+		svc, mailer, _, _ := newPasswordResetTestService(t)
+
+		err := svc.apiForgotPassword(newTestContext(), &ForgotPasswordRequest{Email: "nobody@example.com"})
+		require.NoError(t, err, "unknown emails must not be revealed")
+		require.Empty(t, mailer.messages)
+	})
+
+	t.Run("Reset Updates Password And Revokes Sessions", func(t *testing.T) {
+		svc, mailer, userRepo, store := newPasswordResetTestService(t)
+
+		// an existing session of the user
+		require.NoError(t, svc.Login(newTestContext(), &User{ID: 42, Email: "jane@example.com"}))
+		require.Len(t, store.Keys(), 2, "session and user session index")
+
+		require.NoError(t, svc.apiForgotPassword(newTestContext(), &ForgotPasswordRequest{Email: "jane@example.com"}))
+		require.Len(t, mailer.messages, 1)
+		require.Equal(t, "jane@example.com", mailer.messages[0].To)
+
+		match := resetTokenPattern.FindStringSubmatch(mailer.messages[0].Body)
+		require.Len(t, match, 2, "mail should contain the reset link")
+		token := match[1]
+
+		// only the token hash is stored
+		require.False(t, store.has(passwordResetToken.tokenKey(token)))
+		require.True(t, store.has(passwordResetToken.tokenKey(utils.HashToken(token))))
+
+		newPassword := "N3wPassword!"
+		require.NoError(t, svc.apiResetPassword(newTestContext(), &ResetPasswordRequest{Token: token, Password: newPassword}))
+		require.True(t, utils.CheckPasswordHash(newPassword, userRepo.passwords[42]))
+
+		for _, key := range store.Keys() {
+			require.NotContains(t, key, SessionKeyPrefix, "every session must be revoked")
+		}
+		require.False(t, store.has(UserSessionsKeyPrefix+strconv.Itoa(42)))
+
+		// tokens are single-use
+		err := svc.apiResetPassword(newTestContext(), &ResetPasswordRequest{Token: token, Password: newPassword})
+		require.ErrorIs(t, err, errPasswordResetTokenInvalid)
+	})
+
+	t.Run("Repeated Requests Are Throttled", func(t *testing.T) {
+		svc, mailer, _, store := newPasswordResetTestService(t)
+
+		req := &ForgotPasswordRequest{Email: "jane@example.com"}
+		require.NoError(t, svc.apiForgotPassword(newTestContext(), req))
+		require.NoError(t, svc.apiForgotPassword(newTestContext(), req))
+		require.Len(t, mailer.messages, 1)
+
+		// after the cooldown a new token replaces the old one
+		store.FastForward(PasswordResetCooldown)
+		require.NoError(t, svc.apiForgotPassword(newTestContext(), req))
+		require.Len(t, mailer.messages, 2)
+
+		oldToken := resetTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+		err := svc.apiResetPassword(newTestContext(), &ResetPasswordRequest{Token: oldToken, Password: "N3wPassword!"})
+		require.ErrorIs(t, err, errPasswordResetTokenInvalid)
+	})
+
+	t.Run("Expired Token Is Rejected", func(t *testing.T) {
+		svc, mailer, _, store := newPasswordResetTestService(t)
+
+		require.NoError(t, svc.apiForgotPassword(newTestContext(), &ForgotPasswordRequest{Email: "jane@example.com"}))
+		token := resetTokenPattern.FindStringSubmatch(mailer.messages[0].Body)[1]
+
+		store.FastForward(PasswordResetExpire)
+		err := svc.apiResetPassword(newTestContext(), &ResetPasswordRequest{Token: token, Password: "N3wPassword!"})
+		require.ErrorIs(t, err, errPasswordResetTokenInvalid)
+	})
+
+	t.Run("Reset Checks And Records The Password History", func(t *testing.T) {
+		svc, mailer, userRepo, store := newPasswordResetTestService(t)
+		svc.authCfg.PasswordHistory = 3
+		current, err := utils.HashPassword("0ldPassword!", testPasswordHash)
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = current
+
+		reset := func(password string) error {
+			store.FastForward(PasswordResetCooldown)
+			require.NoError(t, svc.apiForgotPassword(newTestContext(), &ForgotPasswordRequest{Email: "jane@example.com"}))
+			token := resetTokenPattern.FindStringSubmatch(mailer.messages[len(mailer.messages)-1].Body)[1]
+			return svc.apiResetPassword(newTestContext(), &ResetPasswordRequest{Token: token, Password: password})
+		}
+
+		require.ErrorIs(t, reset("0ldPassword!"), errPasswordReused)
+		require.Empty(t, userRepo.passwords)
+
+		require.NoError(t, reset("N3wPassword!"))
+		require.Equal(t, []string{current}, userRepo.history[42], "the replaced password moves to the history")
+		require.Equal(t, []string{"user.password_reset"}, svc.storage.Audit.(*fakeAuditRepo).actions())
+	})
+}
+
+func TestPasswordRehash(t *testing.T) {
+	const password = "Passw0rd!"
+
+	t.Run("Legacy Hash Is Upgraded On Login", func(t *testing.T) {
+		svc, _, userRepo, _, _ := newLoginTestService(t)
+		legacy, err := utils.HashPassword(password, &utils.PasswordHashParams{Algorithm: utils.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost})
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = legacy
+
+		_, err = loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		require.NoError(t, err)
+
+		upgraded := userRepo.passwords[42]
+		require.True(t, strings.HasPrefix(upgraded, "$argon2id$"))
+		require.True(t, utils.CheckPasswordHash(password, upgraded))
+		require.False(t, utils.PasswordNeedsRehash(upgraded, testPasswordHash))
+	})
+
+	t.Run("Current Hash Is Kept", func(t *testing.T) {
+		svc, _, userRepo, _, _ := newLoginTestService(t)
+		current, err := utils.HashPassword(password, testPasswordHash)
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = current
+
+		_, err = loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		require.NoError(t, err)
+		require.Empty(t, userRepo.passwords)
+	})
+
+	t.Run("Failed Login Doesn't Rehash", func(t *testing.T) {
+		svc, _, userRepo, _, _ := newLoginTestService(t)
+		legacy, err := utils.HashPassword(password, &utils.PasswordHashParams{Algorithm: utils.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost})
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = legacy
+
+		_, err = loginFrom(svc, "198.51.100.1", "jane@example.com", "wrong")
+		require.Error(t, err)
+		require.Empty(t, userRepo.passwords)
+	})
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"slices"
+
+	"go-echo-template/internal/shared"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Permission names an action on a resource as "<resource>:<action>:<scope>", where
+// the scope is "self" for the user's own resources and "any" for everyone's
+type Permission string
+
+const (
+	PermUsersReadSelf      Permission = "users:read:self"
+	PermUsersReadAny       Permission = "users:read:any"
+	PermUsersUpdateSelf    Permission = "users:update:self"
+	PermUsersUpdateAny     Permission = "users:update:any"
+	PermUsersDeleteSelf    Permission = "users:delete:self"
+	PermUsersDeleteAny     Permission = "users:delete:any"
+	PermUsersSuspendAny    Permission = "users:suspend:any"
+	PermUsersUpdateRoleAny Permission = "users:update_role:any"
+	PermAuditReadAny       Permission = "audit:read:any"
+)
+
+type roleDefinition struct {
+	// roles whose permissions are granted as well
+	inherits    []string
+	permissions []Permission
+}
+
+var roleDefinitions = map[string]roleDefinition{
+	shared.RoleCustomer: {
+		permissions: []Permission{PermUsersReadSelf, PermUsersUpdateSelf, PermUsersDeleteSelf},
+	},
+	shared.RoleSubadmin: {
+		inherits:    []string{shared.RoleCustomer},
+		permissions: []Permission{PermUsersReadAny},
+	},
+	shared.RoleAdmin: {
+		inherits:    []string{shared.RoleSubadmin},
+		permissions: []Permission{PermUsersUpdateAny, PermUsersDeleteAny, PermUsersSuspendAny, PermUsersUpdateRoleAny, PermAuditReadAny},
+	},
+}
+
+// rolePermissions is every role resolved to its full permission set, inherited ones included
+var rolePermissions = resolveRolePermissions(roleDefinitions)
+
+func resolveRolePermissions(definitions map[string]roleDefinition) map[string]map[Permission]struct{} {
+	resolved := make(map[string]map[Permission]struct{}, len(definitions))
+
+	var resolve func(role string, visiting map[string]bool) map[Permission]struct{}
+	resolve = func(role string, visiting map[string]bool) map[Permission]struct{} {
+		if permissions, ok := resolved[role]; ok {
+			return permissions
+		}
+		definition, ok := definitions[role]
+		if !ok {
+			panic("rbac: unknown role " + role)
+		}
+		if visiting[role] {
+			panic("rbac: inheritance cycle at role " + role)
+		}
+		visiting[role] = true
+
+		permissions := make(map[Permission]struct{})
+		for _, parent := range definition.inherits {
+			for permission := range resolve(parent, visiting) {
+				permissions[permission] = struct{}{}
+			}
+		}
+		for _, permission := range definition.permissions {
+			permissions[permission] = struct{}{}
+		}
+
+		resolved[role] = permissions
+		return permissions
+	}
+
+	for role := range definitions {
+		resolve(role, map[string]bool{})
+	}
+	return resolved
+}
+
+// HasPermission reports whether the role grants the permission, unknown roles grant nothing
+func HasPermission(role string, permission Permission) bool {
+	_, ok := rolePermissions[role][permission]
+	return ok
+}
+
+// Can reports whether the user's role grants the permission, personal access
+// tokens additionally need it among their scopes
+func (u *User) Can(permission Permission) bool {
+	if !HasPermission(u.Role, permission) {
+		return false
+	}
+	return u.APIKeyID == 0 || slices.Contains(u.Scopes, permission)
+}
+
+// RequirePermission only lets users through whose role grants every given permission.
+// Users without a session get a 401, users lacking a permission a 403
+func (s *service) RequirePermission(permissions ...Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := GetUserFromContext(c)
+			if !ok {
+				var err error
+				if user, err = s.Check(c); err != nil {
+					return shared.ErrSessionUnauthorized
+				}
+			}
+
+			for _, permission := range permissions {
+				if !user.Can(permission) {
+					return shared.ErrForbidden
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// AuthorizeOwner checks access to a resource owned by ownerID, the user needs the self
+// permission on their own resources and the any permission on everyone else's
+func AuthorizeOwner(c echo.Context, ownerID int64, selfPermission, anyPermission Permission) error {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	if user.Can(anyPermission) {
+		return nil
+	}
+	if user.ID == ownerID && user.Can(selfPermission) {
+		return nil
+	}
+	return shared.ErrForbidden
+}
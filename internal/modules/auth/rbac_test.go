@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-echo-template/internal/shared"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// newUserContext builds a request whose user was already resolved by CheckAuth
+func newUserContext(user *User) echo.Context {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if user != nil {
+		c.Set(string(UserContextKey), user)
+	}
+	return c
+}
+
+func TestRBAC(t *testing.T) {
+	next := func(c echo.Context) error { return nil }
+
+	t.Run("Roles Inherit Permissions", func(t *testing.T) {
+		require.True(t, HasPermission(shared.RoleCustomer, PermUsersReadSelf))
+		require.False(t, HasPermission(shared.RoleCustomer, PermUsersReadAny))
+
+		require.True(t, HasPermission(shared.RoleSubadmin, PermUsersReadSelf), "inherited from user")
+		require.True(t, HasPermission(shared.RoleSubadmin, PermUsersReadAny))
+		require.False(t, HasPermission(shared.RoleSubadmin, PermUsersDeleteAny))
+
+		require.True(t, HasPermission(shared.RoleAdmin, PermUsersReadSelf), "inherited through subadmin")
+		require.True(t, HasPermission(shared.RoleAdmin, PermUsersDeleteAny))
+		require.True(t, HasPermission(shared.RoleAdmin, PermUsersSuspendAny))
+		require.False(t, HasPermission(shared.RoleSubadmin, PermUsersUpdateRoleAny))
+
+		require.False(t, HasPermission("unknown", PermUsersReadSelf))
+	})
+
+	t.Run("Invalid Definitions Panic", func(t *testing.T) {
+		require.Panics(t, func() {
+			resolveRolePermissions(map[string]roleDefinition{"a": {inherits: []string{"missing"}}})
+		})
+		require.Panics(t, func() {
+			resolveRolePermissions(map[string]roleDefinition{
+				"a": {inherits: []string{"b"}},
+				"b": {inherits: []string{"a"}},
+			})
+		})
+	})
+
+	t.Run("RequirePermission", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		middleware := svc.RequirePermission(PermUsersReadAny)
+
+		require.ErrorIs(t, middleware(next)(newUserContext(nil)), shared.ErrSessionUnauthorized)
+		require.ErrorIs(t, middleware(next)(newUserContext(&User{ID: 1, Role: shared.RoleCustomer})), shared.ErrForbidden)
+		require.NoError(t, middleware(next)(newUserContext(&User{ID: 1, Role: shared.RoleSubadmin})))
+
+		// every listed permission is required
+		both := svc.RequirePermission(PermUsersReadAny, PermUsersDeleteAny)
+		require.ErrorIs(t, both(next)(newUserContext(&User{ID: 1, Role: shared.RoleSubadmin})), shared.ErrForbidden)
+		require.NoError(t, both(next)(newUserContext(&User{ID: 1, Role: shared.RoleAdmin})))
+	})
+
+	t.Run("Role Mismatch Is Forbidden", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		cookie := loginCookie(t, svc, &User{ID: 1, Role: shared.RoleCustomer}, "laptop")
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		require.ErrorIs(t, svc.CheckAuth(false, WithRoles(shared.RoleAdmin))(next)(c), shared.ErrForbidden)
+	})
+
+	t.Run("AuthorizeOwner", func(t *testing.T) {
+		customer := newUserContext(&User{ID: 1, Role: shared.RoleCustomer})
+		require.NoError(t, AuthorizeOwner(customer, 1, PermUsersUpdateSelf, PermUsersUpdateAny))
+		require.ErrorIs(t, AuthorizeOwner(customer, 2, PermUsersUpdateSelf, PermUsersUpdateAny), shared.ErrForbidden)
+
+		subadmin := newUserContext(&User{ID: 3, Role: shared.RoleSubadmin})
+		require.NoError(t, AuthorizeOwner(subadmin, 2, PermUsersReadSelf, PermUsersReadAny))
+		require.ErrorIs(t, AuthorizeOwner(subadmin, 2, PermUsersUpdateSelf, PermUsersUpdateAny), shared.ErrForbidden)
+
+		admin := newUserContext(&User{ID: 4, Role: shared.RoleAdmin})
+		require.NoError(t, AuthorizeOwner(admin, 2, PermUsersDeleteSelf, PermUsersDeleteAny))
+
+		require.ErrorIs(t, AuthorizeOwner(newUserContext(nil), 1, PermUsersReadSelf, PermUsersReadAny), shared.ErrSessionUnauthorized)
+	})
+}
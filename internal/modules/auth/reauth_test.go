@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/shared/response"
+	"go-echo-template/internal/shared/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReauthentication(t *testing.T) {
+	const password = "Passw0rd!"
+	hash, err := utils.HashPassword(password, testPasswordHash)
+	require.NoError(t, err)
+
+	// newStaleSession logs in with the password and backdates the authentication time of the session
+	newStaleSession := func(t *testing.T) (*service, echo.Context) {
+		svc, _, _, _, _ := newLoginTestService(t)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = hash
+
+		rec, err := loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		require.NoError(t, err)
+		c, _ := newCookieContext(t, svc, sessionCookieOf(t, rec))
+
+		user, _ := GetUserFromContext(c)
+		user.AuthenticatedAt = time.Now().Add(-time.Hour)
+		require.NoError(t, svc.Refresh(c, user))
+		return svc, c
+	}
+	next := func(c echo.Context) error { return nil }
+
+	t.Run("Fresh Login Counts As Recent", func(t *testing.T) {
+		svc, _, _, _, _ := newLoginTestService(t)
+		svc.storage.Auth.(*fakeAuthRepo).users["jane@example.com"].Password = hash
+
+		rec, err := loginFrom(svc, "198.51.100.1", "jane@example.com", password)
+		require.NoError(t, err)
+		c, _ := newCookieContext(t, svc, sessionCookieOf(t, rec))
+		require.NoError(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c))
+	})
+
+	t.Run("Password Renews A Stale Session", func(t *testing.T) {
+		svc, c := newStaleSession(t)
+		require.ErrorIs(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c), errReauthenticationRequired)
+
+		require.NoError(t, svc.apiReauthenticate(c, &ReauthenticateRequest{Password: password}))
+		require.NoError(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c))
+
+		// the renewal is stored in the session, not only in the request
+		cookie := c.Request().Cookies()[0]
+		renewed, _ := newCookieContext(t, svc, cookie)
+		require.NoError(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(renewed))
+	})
+
+	t.Run("Wrong Password Is Rejected", func(t *testing.T) {
+		svc, c := newStaleSession(t)
+
+		err := svc.apiReauthenticate(c, &ReauthenticateRequest{Password: "wrong"})
+		require.ErrorIs(t, err, errReauthenticationFailed)
+		require.ErrorIs(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c), errReauthenticationRequired)
+	})
+
+	t.Run("Code Guesses Are Limited", func(t *testing.T) {
+		svc, c := newStaleSession(t)
+		secret, err := utils.GenerateTOTPSecret()
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).totpSecrets = map[int64]string{42: secret}
+
+		for range LoginTwoFactorMaxAttempts {
+			err := svc.apiReauthenticate(c, &ReauthenticateRequest{Code: "000000"})
+			require.ErrorIs(t, err, errTOTPCodeInvalid)
+		}
+
+		// even the right code is refused once the guesses are used up
+		code, err := utils.GenerateTOTP(secret, time.Now())
+		require.NoError(t, err)
+		err = svc.apiReauthenticate(c, &ReauthenticateRequest{Code: code})
+		require.ErrorIs(t, err, errTwoFactorAttemptsExceeded)
+		require.ErrorIs(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c), errReauthenticationRequired)
+
+		svc.store.(*testStore).FastForward(TwoFactorAttemptsWindow)
+		require.NoError(t, svc.apiReauthenticate(c, &ReauthenticateRequest{Code: code}))
+		require.NoError(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c))
+	})
+
+	t.Run("Disabling TOTP Shares The Guess Limit", func(t *testing.T) {
+		svc, c := newStaleSession(t)
+		secret, err := utils.GenerateTOTPSecret()
+		require.NoError(t, err)
+		svc.storage.Auth.(*fakeAuthRepo).totpSecrets = map[int64]string{42: secret}
+
+		for range LoginTwoFactorMaxAttempts {
+			require.ErrorIs(t, svc.apiDisableTOTP(c, &DisableTOTPRequest{Code: "000000"}), errTOTPCodeInvalid)
+		}
+		require.ErrorIs(t, svc.apiDisableTOTP(c, &DisableTOTPRequest{Code: "000000"}), errTwoFactorAttemptsExceeded)
+
+		code, err := utils.GenerateTOTP(secret, time.Now())
+		require.NoError(t, err)
+		require.ErrorIs(t, svc.apiReauthenticate(c, &ReauthenticateRequest{Code: code}), errTwoFactorAttemptsExceeded)
+	})
+
+	t.Run("Credential Routes Need A Recent Login", func(t *testing.T) {
+		svc, c := newStaleSession(t)
+		cookie := c.Request().Cookies()[0]
+
+		e := echo.New()
+		e.Validator = response.NewValidator()
+		e.HTTPErrorHandler = response.CustomHTTPErrorHandler
+		NewAuthHandler(svc.logger, svc.alarmer, svc).RegisterRoutes(e.Group("/api"))
+
+		for _, path := range []string{
+			"/api/v1/auth/webauthn/register/begin",
+			"/api/v1/auth/webauthn/register/finish",
+			"/api/v1/auth/2fa/totp/enroll",
+			"/api/v1/auth/api-keys",
+		} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, errReauthenticationRequired.Status, rec.Code, path)
+			require.Contains(t, rec.Body.String(), errReauthenticationRequired.Code, path)
+		}
+	})
+
+	t.Run("API Keys Never Count As Recent", func(t *testing.T) {
+		svc, _, _, _, _ := newLoginTestService(t)
+		c := newTestContext()
+		c.Set(string(UserContextKey), &User{ID: 42, APIKeyID: 7})
+
+		require.ErrorIs(t, svc.RequireRecentAuth(RecentAuthMaxAge)(next)(c), errReauthenticationRequired)
+		require.Error(t, svc.apiReauthenticate(c, &ReauthenticateRequest{Password: password}))
+	})
+}
@@ -15,6 +15,125 @@ var (
 			i18n.TR_TR: "Giriş başarılı",
 		},
 	}
+	succLoginTwoFactorRequired = &response.SuccessMessage{
+		Code: "SUCC:LOGIN_TWO_FACTOR_REQUIRED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Enter the code from your authenticator app to continue",
+			i18n.TR_TR: "Devam etmek için kimlik doğrulama uygulamanızdaki kodu girin",
+		},
+	}
+	succPasswordResetRequested = &response.SuccessMessage{
+		Code: "SUCC:PASSWORD_RESET_REQUESTED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "If an account with that email exists, a password reset link has been sent",
+			i18n.TR_TR: "Bu e-posta adresine ait bir hesap varsa şifre sıfırlama bağlantısı gönderildi",
+		},
+	}
+	succPasswordReset = &response.SuccessMessage{
+		Code: "SUCC:PASSWORD_RESET",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Password has been reset successfully",
+			i18n.TR_TR: "Şifre başarıyla sıfırlandı",
+		},
+	}
+	succMagicLinkRequested = &response.SuccessMessage{
+		Code: "SUCC:MAGIC_LINK_REQUESTED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "If an account with that email exists, a login link has been sent",
+			i18n.TR_TR: "Bu e-posta adresine ait bir hesap varsa giriş bağlantısı gönderildi",
+		},
+	}
+	succReauthenticated = &response.SuccessMessage{
+		Code: "SUCC:REAUTHENTICATED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Identity confirmed",
+			i18n.TR_TR: "Kimlik doğrulandı",
+		},
+	}
+	succEmailVerified = &response.SuccessMessage{
+		Code: "SUCC:EMAIL_VERIFIED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Email address verified successfully",
+			i18n.TR_TR: "E-posta adresi başarıyla doğrulandı",
+		},
+	}
+	succEmailVerificationSent = &response.SuccessMessage{
+		Code: "SUCC:EMAIL_VERIFICATION_SENT",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Verification email sent",
+			i18n.TR_TR: "Doğrulama e-postası gönderildi",
+		},
+	}
+	succTOTPEnabled = &response.SuccessMessage{
+		Code: "SUCC:TOTP_ENABLED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Two-factor authentication enabled, store your recovery codes somewhere safe",
+			i18n.TR_TR: "İki adımlı doğrulama etkinleştirildi, kurtarma kodlarınızı güvenli bir yerde saklayın",
+		},
+	}
+	succTOTPDisabled = &response.SuccessMessage{
+		Code: "SUCC:TOTP_DISABLED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Two-factor authentication disabled",
+			i18n.TR_TR: "İki adımlı doğrulama devre dışı bırakıldı",
+		},
+	}
+	succWebAuthnRegistered = &response.SuccessMessage{
+		Code: "SUCC:WEBAUTHN_REGISTERED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Passkey registered successfully",
+			i18n.TR_TR: "Geçiş anahtarı başarıyla kaydedildi",
+		},
+	}
+	succWebAuthnDeleted = &response.SuccessMessage{
+		Code: "SUCC:WEBAUTHN_DELETED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Passkey removed successfully",
+			i18n.TR_TR: "Geçiş anahtarı başarıyla kaldırıldı",
+		},
+	}
+	succSessionRevoked = &response.SuccessMessage{
+		Code: "SUCC:SESSION_REVOKED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Session logged out successfully",
+			i18n.TR_TR: "Oturum başarıyla sonlandırıldı",
+		},
+	}
+	succImpersonationStarted = &response.SuccessMessage{
+		Code: "SUCC:IMPERSONATION_STARTED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "You are now acting as the user",
+			i18n.TR_TR: "Artık kullanıcı olarak işlem yapıyorsunuz",
+		},
+	}
+	succImpersonationStopped = &response.SuccessMessage{
+		Code: "SUCC:IMPERSONATION_STOPPED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "You are back in your own session",
+			i18n.TR_TR: "Kendi oturumunuza geri döndünüz",
+		},
+	}
+	succAPIKeyCreated = &response.SuccessMessage{
+		Code: "SUCC:API_KEY_CREATED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API key created, copy the token now as it won't be shown again",
+			i18n.TR_TR: "API anahtarı oluşturuldu, tekrar gösterilmeyeceği için anahtarı şimdi kopyalayın",
+		},
+	}
+	succAPIKeyDeleted = &response.SuccessMessage{
+		Code: "SUCC:API_KEY_DELETED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API key deleted successfully",
+			i18n.TR_TR: "API anahtarı başarıyla silindi",
+		},
+	}
+	succLogoutAll = &response.SuccessMessage{
+		Code: "SUCC:LOGOUT_ALL",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Logged out of all sessions",
+			i18n.TR_TR: "Tüm oturumlardan çıkış yapıldı",
+		},
+	}
 )
 
 // Error Messages
@@ -43,6 +162,14 @@ var (
 			i18n.TR_TR: "Boş oturum ID'si",
 		},
 	}
+	errSessionInvalidID = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:SESSION_INVALID_ID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Session ID is invalid",
+			i18n.TR_TR: "Oturum ID'si geçersiz",
+		},
+	}
 	errSessionNotFound = &response.CustomErr{
 		Status: http.StatusUnauthorized,
 		Code:   "ERR:SESSION_NOT_FOUND",
@@ -99,4 +226,564 @@ var (
 			i18n.TR_TR: "Kullanıcı verisi çözümlenemedi",
 		},
 	}
+	errSessionRevoke = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:SESSION_REVOKE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to revoke sessions",
+			i18n.TR_TR: "Oturumlar sonlandırılamadı",
+		},
+	}
+	errSessionUnknown = &response.CustomErr{
+		Status: http.StatusNotFound,
+		Code:   "ERR:AUTH_SESSION_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Session not found",
+			i18n.TR_TR: "Oturum bulunamadı",
+		},
+	}
+	errAccountLocked = &response.CustomErr{
+		Status: http.StatusTooManyRequests,
+		Code:   "ERR:AUTH_ACCOUNT_LOCKED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Too many failed login attempts, try again in %d seconds",
+			i18n.TR_TR: "Çok fazla başarısız giriş denemesi, %d saniye sonra tekrar deneyin",
+		},
+	}
+	errLoginLockCheck = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_LOGIN_LOCK_CHECK",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to check login attempts",
+			i18n.TR_TR: "Giriş denemeleri kontrol edilemedi",
+		},
+	}
+	errCSRFTokenMissing = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_CSRF_TOKEN_MISSING",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "CSRF token is missing",
+			i18n.TR_TR: "CSRF belirteci eksik",
+		},
+	}
+	errCSRFTokenInvalid = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_CSRF_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "CSRF token is invalid",
+			i18n.TR_TR: "CSRF belirteci geçersiz",
+		},
+	}
+	errCSRFTokenGenerate = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_CSRF_TOKEN_GENERATE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to generate CSRF token",
+			i18n.TR_TR: "CSRF belirteci oluşturulamadı",
+		},
+	}
+	errCSRFNotApplicable = &response.CustomErr{
+		Status: http.StatusNotFound,
+		Code:   "ERR:AUTH_CSRF_NOT_APPLICABLE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "CSRF tokens are only used with session cookies",
+			i18n.TR_TR: "CSRF belirteçleri yalnızca oturum çerezleriyle kullanılır",
+		},
+	}
+	errPasswordResetTokenInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_PASSWORD_RESET_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Password reset link is invalid or expired",
+			i18n.TR_TR: "Şifre sıfırlama bağlantısı geçersiz veya süresi dolmuş",
+		},
+	}
+	errPasswordReused = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_PASSWORD_REUSED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The new password must differ from your last %v passwords",
+			i18n.TR_TR: "Yeni şifre son %v şifrenizden farklı olmalıdır",
+		},
+	}
+	errEmailTokenGen = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_EMAIL_TOKEN_GENERATE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to generate link token",
+			i18n.TR_TR: "Bağlantı anahtarı oluşturulamadı",
+		},
+	}
+	errEmailTokenStore = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_EMAIL_TOKEN_STORE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to store link token",
+			i18n.TR_TR: "Bağlantı anahtarı kaydedilemedi",
+		},
+	}
+	errMagicLinkTokenInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_MAGIC_LINK_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Login link is invalid or expired",
+			i18n.TR_TR: "Giriş bağlantısı geçersiz veya süresi dolmuş",
+		},
+	}
+	errEmailChangeTokenInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_EMAIL_CHANGE_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Email change link is invalid or expired",
+			i18n.TR_TR: "E-posta değişikliği bağlantısı geçersiz veya süresi dolmuş",
+		},
+	}
+	errEmailChangeThrottled = &response.CustomErr{
+		Status: http.StatusTooManyRequests,
+		Code:   "ERR:AUTH_EMAIL_CHANGE_THROTTLED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Please wait before requesting another email change",
+			i18n.TR_TR: "Yeni bir e-posta değişikliği istemeden önce lütfen bekleyin",
+		},
+	}
+	errEmailChangeMail = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_EMAIL_CHANGE_MAIL",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to send email change confirmation",
+			i18n.TR_TR: "E-posta değişikliği onayı gönderilemedi",
+		},
+	}
+	errEmailVerificationTokenInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_EMAIL_VERIFICATION_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Email verification link is invalid or expired",
+			i18n.TR_TR: "E-posta doğrulama bağlantısı geçersiz veya süresi dolmuş",
+		},
+	}
+	errEmailAlreadyVerified = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:AUTH_EMAIL_ALREADY_VERIFIED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Email address is already verified",
+			i18n.TR_TR: "E-posta adresi zaten doğrulanmış",
+		},
+	}
+	errEmailNotVerified = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_EMAIL_NOT_VERIFIED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Email address is not verified",
+			i18n.TR_TR: "E-posta adresi doğrulanmamış",
+		},
+	}
+	errEmailVerificationThrottled = &response.CustomErr{
+		Status: http.StatusTooManyRequests,
+		Code:   "ERR:AUTH_EMAIL_VERIFICATION_THROTTLED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Please wait before requesting another verification email",
+			i18n.TR_TR: "Yeni bir doğrulama e-postası istemeden önce lütfen bekleyin",
+		},
+	}
+	errEmailVerificationStore = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_EMAIL_VERIFICATION_STORE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to store email verification state",
+			i18n.TR_TR: "E-posta doğrulama durumu kaydedilemedi",
+		},
+	}
+	errEmailVerificationMail = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_EMAIL_VERIFICATION_MAIL",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to send verification email",
+			i18n.TR_TR: "Doğrulama e-postası gönderilemedi",
+		},
+	}
+	errTOTPAlreadyEnabled = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:AUTH_TOTP_ALREADY_ENABLED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Two-factor authentication is already enabled",
+			i18n.TR_TR: "İki adımlı doğrulama zaten etkin",
+		},
+	}
+	errTOTPEnrollmentNotFound = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_TOTP_ENROLLMENT_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Two-factor enrolment expired or not started, please start again",
+			i18n.TR_TR: "İki adımlı doğrulama kaydının süresi dolmuş veya başlatılmamış, lütfen tekrar başlayın",
+		},
+	}
+	errTOTPCodeInvalid = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_TOTP_CODE_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Verification code is invalid",
+			i18n.TR_TR: "Doğrulama kodu geçersiz",
+		},
+	}
+	errTOTPGenSecret = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_TOTP_GENERATE_SECRET",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to generate two-factor secret",
+			i18n.TR_TR: "İki adımlı doğrulama anahtarı oluşturulamadı",
+		},
+	}
+	errTwoFactorTokenInvalid = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_TWO_FACTOR_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Two-factor login expired, please log in again",
+			i18n.TR_TR: "İki adımlı giriş süresi doldu, lütfen tekrar giriş yapın",
+		},
+	}
+	errTwoFactorStore = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_TWO_FACTOR_STORE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to store two-factor state",
+			i18n.TR_TR: "İki adımlı doğrulama durumu kaydedilemedi",
+		},
+	}
+	errTwoFactorAttemptsExceeded = &response.CustomErr{
+		Status: http.StatusTooManyRequests,
+		Code:   "ERR:AUTH_TWO_FACTOR_ATTEMPTS_EXCEEDED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Too many invalid codes, try again later",
+			i18n.TR_TR: "Çok fazla geçersiz kod girildi, daha sonra tekrar deneyin",
+		},
+	}
+	errSecondFactorRequired = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_SECOND_FACTOR_REQUIRED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "This action requires a session established with two-factor authentication",
+			i18n.TR_TR: "Bu işlem iki adımlı doğrulama ile açılmış bir oturum gerektirir",
+		},
+	}
+	errWebAuthnBegin = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_WEBAUTHN_BEGIN",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to start passkey ceremony",
+			i18n.TR_TR: "Geçiş anahtarı işlemi başlatılamadı",
+		},
+	}
+	errWebAuthnStore = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_WEBAUTHN_STORE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to store passkey challenge",
+			i18n.TR_TR: "Geçiş anahtarı doğrulama sorusu kaydedilemedi",
+		},
+	}
+	errWebAuthnChallengeInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_WEBAUTHN_CHALLENGE_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Passkey challenge is invalid or expired, please try again",
+			i18n.TR_TR: "Geçiş anahtarı doğrulama sorusu geçersiz veya süresi dolmuş, lütfen tekrar deneyin",
+		},
+	}
+	errWebAuthnResponseInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_WEBAUTHN_RESPONSE_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Passkey response could not be verified",
+			i18n.TR_TR: "Geçiş anahtarı yanıtı doğrulanamadı",
+		},
+	}
+	errWebAuthnCloneWarning = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_WEBAUTHN_CLONE_WARNING",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "This passkey may have been copied and can no longer be used, please register a new one",
+			i18n.TR_TR: "Bu geçiş anahtarı kopyalanmış olabilir ve artık kullanılamaz, lütfen yeni bir tane kaydedin",
+		},
+	}
+	errWebAuthnCredentialNotFound = &response.CustomErr{
+		Status: http.StatusNotFound,
+		Code:   "ERR:AUTH_WEBAUTHN_CREDENTIAL_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Passkey not found",
+			i18n.TR_TR: "Geçiş anahtarı bulunamadı",
+		},
+	}
+	errOIDCProviderNotFound = &response.CustomErr{
+		Status: http.StatusNotFound,
+		Code:   "ERR:AUTH_OIDC_PROVIDER_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Login provider not found",
+			i18n.TR_TR: "Giriş sağlayıcısı bulunamadı",
+		},
+	}
+	errOIDCProviderUnavailable = &response.CustomErr{
+		Status: http.StatusServiceUnavailable,
+		Code:   "ERR:AUTH_OIDC_PROVIDER_UNAVAILABLE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Login provider is currently unavailable, please try again later",
+			i18n.TR_TR: "Giriş sağlayıcısına şu anda ulaşılamıyor, lütfen daha sonra tekrar deneyin",
+		},
+	}
+	errOIDCGenState = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_OIDC_GENERATE_STATE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to generate login state",
+			i18n.TR_TR: "Giriş durumu oluşturulamadı",
+		},
+	}
+	errOIDCStore = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_OIDC_STORE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to store login state",
+			i18n.TR_TR: "Giriş durumu kaydedilemedi",
+		},
+	}
+	errOIDCDenied = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_OIDC_DENIED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Login was cancelled or denied by the provider",
+			i18n.TR_TR: "Giriş iptal edildi veya sağlayıcı tarafından reddedildi",
+		},
+	}
+	errOIDCStateInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_OIDC_STATE_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Login request is invalid or expired, please try again",
+			i18n.TR_TR: "Giriş isteği geçersiz veya süresi dolmuş, lütfen tekrar deneyin",
+		},
+	}
+	errOIDCExchange = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_OIDC_EXCHANGE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to complete login with the provider",
+			i18n.TR_TR: "Sağlayıcı ile giriş tamamlanamadı",
+		},
+	}
+	errOIDCTokenInvalid = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_OIDC_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Identity returned by the provider could not be verified",
+			i18n.TR_TR: "Sağlayıcının döndürdüğü kimlik doğrulanamadı",
+		},
+	}
+	errOIDCEmailNotVerified = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_OIDC_EMAIL_NOT_VERIFIED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The provider didn't confirm a verified email address for this account",
+			i18n.TR_TR: "Sağlayıcı bu hesap için doğrulanmış bir e-posta adresi bildirmedi",
+		},
+	}
+	errOIDCEmailInUse = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:AUTH_OIDC_EMAIL_IN_USE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "An account with this email already exists, log in with your password to link this provider",
+			i18n.TR_TR: "Bu e-posta ile bir hesap zaten var, bu sağlayıcıyı bağlamak için şifrenizle giriş yapın",
+		},
+	}
+	errBearerTokenNotFound = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_BEARER_TOKEN_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Bearer token not found",
+			i18n.TR_TR: "Bearer anahtarı bulunamadı",
+		},
+	}
+	errAccessTokenInvalid = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_ACCESS_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Access token is invalid",
+			i18n.TR_TR: "Erişim anahtarı geçersiz",
+		},
+	}
+	errAccessTokenExpired = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_ACCESS_TOKEN_EXPIRED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Access token has expired",
+			i18n.TR_TR: "Erişim anahtarının süresi doldu",
+		},
+	}
+	errRefreshTokenInvalid = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_REFRESH_TOKEN_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Refresh token is invalid or expired, please log in again",
+			i18n.TR_TR: "Yenileme anahtarı geçersiz veya süresi dolmuş, lütfen tekrar giriş yapın",
+		},
+	}
+	errRefreshTokenReused = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_REFRESH_TOKEN_REUSED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Refresh token was already used, the session has been revoked for your security",
+			i18n.TR_TR: "Yenileme anahtarı zaten kullanılmış, güvenliğiniz için oturum sonlandırıldı",
+		},
+	}
+	errAPIKeyInvalid = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_API_KEY_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API key is invalid or has been deleted",
+			i18n.TR_TR: "API anahtarı geçersiz veya silinmiş",
+		},
+	}
+	errAPIKeyExpired = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_API_KEY_EXPIRED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API key has expired",
+			i18n.TR_TR: "API anahtarının süresi doldu",
+		},
+	}
+	errAPIKeyManagement = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_API_KEY_MANAGEMENT",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API keys can only be managed after logging in",
+			i18n.TR_TR: "API anahtarları yalnızca giriş yapıldıktan sonra yönetilebilir",
+		},
+	}
+	errAPIKeyForbidden = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_API_KEY_FORBIDDEN",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "This action needs a login, API keys can't be used for it",
+			i18n.TR_TR: "Bu işlem için giriş yapılması gerekir, API anahtarları kullanılamaz",
+		},
+	}
+	errAPIKeyScopeInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_API_KEY_SCOPE_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API key scopes must be permissions granted to your role",
+			i18n.TR_TR: "API anahtarı kapsamları rolünüze tanınmış yetkiler olmalıdır",
+		},
+	}
+	errAPIKeyGenerate = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_API_KEY_GENERATE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to generate API key",
+			i18n.TR_TR: "API anahtarı oluşturulamadı",
+		},
+	}
+	errAPIKeyNotFound = &response.CustomErr{
+		Status: http.StatusNotFound,
+		Code:   "ERR:AUTH_API_KEY_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "API key not found",
+			i18n.TR_TR: "API anahtarı bulunamadı",
+		},
+	}
+	errTokenSign = &response.CustomErr{
+		Status: http.StatusInternalServerError,
+		Code:   "ERR:AUTH_TOKEN_SIGN",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Failed to sign access token",
+			i18n.TR_TR: "Erişim anahtarı imzalanamadı",
+		},
+	}
+	errImpersonationUserNotFound = &response.CustomErr{
+		Status: http.StatusNotFound,
+		Code:   "ERR:AUTH_IMPERSONATION_USER_NOT_FOUND",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "User to impersonate not found",
+			i18n.TR_TR: "Kimliğine bürünülecek kullanıcı bulunamadı",
+		},
+	}
+	errImpersonationSelf = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_IMPERSONATION_SELF",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "You can't impersonate yourself",
+			i18n.TR_TR: "Kendi kimliğinize bürünemezsiniz",
+		},
+	}
+	errImpersonationAdmin = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_IMPERSONATION_ADMIN",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Admins can't be impersonated",
+			i18n.TR_TR: "Yöneticilerin kimliğine bürünülemez",
+		},
+	}
+	errImpersonationNested = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:AUTH_IMPERSONATION_NESTED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Stop the current impersonation first",
+			i18n.TR_TR: "Önce mevcut kimliğe bürünmeyi sonlandırın",
+		},
+	}
+	errNotImpersonating = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:AUTH_NOT_IMPERSONATING",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The session isn't impersonating a user",
+			i18n.TR_TR: "Oturum bir kullanıcının kimliğine bürünmüyor",
+		},
+	}
+	errImpersonationExpired = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_IMPERSONATION_EXPIRED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Impersonation expired, log in again",
+			i18n.TR_TR: "Kimliğe bürünme süresi doldu, tekrar giriş yapın",
+		},
+	}
+	errReauthenticationRequired = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_REAUTHENTICATION_REQUIRED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Please confirm your identity again to continue",
+			i18n.TR_TR: "Devam etmek için lütfen kimliğinizi yeniden doğrulayın",
+		},
+	}
+	errReauthenticationFailed = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_REAUTHENTICATION_FAILED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Password is incorrect",
+			i18n.TR_TR: "Şifre hatalı",
+		},
+	}
+	errSessionLimitReached = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:AUTH_SESSION_LIMIT_REACHED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Maximum number of active sessions reached, log out on another device first",
+			i18n.TR_TR: "Azami aktif oturum sayısına ulaşıldı, önce başka bir cihazda oturumu kapatın",
+		},
+	}
+	errSessionEvicted = &response.CustomErr{
+		Status: http.StatusUnauthorized,
+		Code:   "ERR:AUTH_SESSION_EVICTED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "You were logged out because your account was signed in on another device",
+			i18n.TR_TR: "Hesabınıza başka bir cihazdan giriş yapıldığı için oturumunuz kapatıldı",
+		},
+	}
+	errImpersonationForbidden = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:AUTH_IMPERSONATION_FORBIDDEN",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "This action isn't allowed while impersonating a user",
+			i18n.TR_TR: "Bu işleme kullanıcı kimliğine bürünülmüşken izin verilmez",
+		},
+	}
 )
@@ -1,28 +1,40 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
-	"net/http"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
+	"go-echo-template/internal/alarm"
+	"go-echo-template/internal/audit"
 	"go-echo-template/internal/config"
+	"go-echo-template/internal/mail"
 	"go-echo-template/internal/shared"
 	"go-echo-template/internal/shared/log"
 	"go-echo-template/internal/shared/utils"
 	"go-echo-template/internal/storage"
+	storageSession "go-echo-template/internal/storage/session"
+	userSqlc "go-echo-template/internal/storage/user/sqlc"
 
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/labstack/echo/v4"
-	"github.com/redis/go-redis/v9"
 )
 
 const (
-	SessionDefaultExpire = 7 * 24 * time.Hour
-	SessionKeyPrefix     = "SESSION:"
-	SessionCookieName    = "session"
+	SessionKeyPrefix  = "SESSION:"
+	SessionCookieName = "session"
+
+	// USER_SESSIONS:<user ID> -> hash of public session ID to session info, covering
+	// cookie sessions and refresh token families so that all of them can be listed and revoked.
+	// Impersonation sessions are indexed under the admin
+	UserSessionsKeyPrefix = "USER_SESSIONS:"
 
 	UserContextKey shared.ContextKey = "user"
+	// rememberMeContextKey carries the remember me choice of the login to the session manager
+	rememberMeContextKey shared.ContextKey = "remember_me"
 )
 
 type AuthService interface {
@@ -31,13 +43,63 @@ type AuthService interface {
 	Logout(c echo.Context) error
 	Refresh(c echo.Context, user *User) error
 	Check(c echo.Context) (*User, error)
+	LogoutAll(ctx context.Context, userID int64) error
+	LogoutOthers(c echo.Context, userID int64) error
 
 	// Middleware for general session enforcement
-	CheckAuth(isOptional bool, roles ...string) echo.MiddlewareFunc
+	CheckAuth(isOptional bool, opts ...CheckAuthOption) echo.MiddlewareFunc
+	// Middleware for permission-based access control
+	RequirePermission(permissions ...Permission) echo.MiddlewareFunc
+	// Middleware enforcing CSRF tokens on unsafe methods of cookie sessions
+	CSRF() echo.MiddlewareFunc
+	// Middleware refusing sessions of an impersonating admin
+	RefuseImpersonation() echo.MiddlewareFunc
+	// Middleware refusing requests authenticated with a personal access token
+	RefuseAPIKey() echo.MiddlewareFunc
+	// Middleware requiring a login or reauthentication within maxAge
+	RequireRecentAuth(maxAge time.Duration) echo.MiddlewareFunc
+
+	// Email verification
+	SendEmailVerification(c echo.Context, userID int64, name, email string) error
+
+	// Password
+	UpdatePassword(c echo.Context, userID int64, password string, action audit.Action) error
+
+	// Email change
+	RequestEmailChange(c echo.Context, name string, change *EmailChange) error
+	ConsumeEmailChange(c echo.Context, token string) (*EmailChange, error)
 
 	// Auth API methods (handler specific)
-	apiLogin(c echo.Context, req *LoginRequest) error
+	apiLogin(c echo.Context, req *LoginRequest) (*LoginResponse, error)
+	apiLoginTwoFactor(c echo.Context, req *LoginTwoFactorRequest) error
 	apiRefresh(c echo.Context) error
+	apiForgotPassword(c echo.Context, req *ForgotPasswordRequest) error
+	apiResetPassword(c echo.Context, req *ResetPasswordRequest) error
+	apiRequestMagicLink(c echo.Context, req *MagicLinkRequest) error
+	apiConsumeMagicLink(c echo.Context, req *ConsumeMagicLinkRequest) (*LoginResponse, error)
+	apiVerifyEmail(c echo.Context, req *VerifyEmailRequest) error
+	apiResendEmailVerification(c echo.Context) error
+	apiEnrollTOTP(c echo.Context) (*EnrollTOTPResponse, error)
+	apiConfirmTOTP(c echo.Context, req *ConfirmTOTPRequest) (*ConfirmTOTPResponse, error)
+	apiDisableTOTP(c echo.Context, req *DisableTOTPRequest) error
+	apiBeginWebAuthnRegistration(c echo.Context) (*protocol.CredentialCreation, error)
+	apiFinishWebAuthnRegistration(c echo.Context, req *FinishWebAuthnRegistrationRequest) error
+	apiBeginWebAuthnLogin(c echo.Context) (*protocol.CredentialAssertion, error)
+	apiFinishWebAuthnLogin(c echo.Context, req *FinishWebAuthnLoginRequest) error
+	apiListWebAuthnCredentials(c echo.Context) ([]WebAuthnCredentialResponse, error)
+	apiDeleteWebAuthnCredential(c echo.Context, req *DeleteWebAuthnCredentialRequest) error
+	apiStartOIDCLogin(c echo.Context, req *StartOIDCLoginRequest) (string, error)
+	apiFinishOIDCLogin(c echo.Context, req *FinishOIDCLoginRequest) (string, error)
+	apiListSessions(c echo.Context) ([]SessionResponse, error)
+	apiRevokeSession(c echo.Context, req *RevokeSessionRequest) error
+	apiLogoutAll(c echo.Context) error
+	apiReauthenticate(c echo.Context, req *ReauthenticateRequest) error
+	apiCSRFToken(c echo.Context) (*CSRFTokenResponse, error)
+	apiCreateAPIKey(c echo.Context, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	apiListAPIKeys(c echo.Context) ([]APIKeyResponse, error)
+	apiDeleteAPIKey(c echo.Context, req *DeleteAPIKeyRequest) error
+	apiStartImpersonation(c echo.Context, req *StartImpersonationRequest) error
+	apiStopImpersonation(c echo.Context) error
 }
 
 // Session user data
@@ -49,178 +111,189 @@ type User struct {
 	Role      string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	EmailVerified bool
+	// SecondFactor is set when the session was established with a TOTP or recovery code,
+	// or with a user-verified passkey
+	SecondFactor bool
+	// APIKeyID is set when the request was authenticated with a personal access token,
+	// which is limited to its Scopes on top of the permissions of the role
+	APIKeyID int64
+	Scopes   []Permission
+	// Impersonation is set while an admin acts as the user
+	Impersonation *Impersonation
+	// AuthenticatedAt is when the user last proved their credentials, at login or on
+	// reauthentication. It is zero for API keys and impersonated sessions
+	AuthenticatedAt time.Time
+}
+
+// sessionManager issues and checks what a client presents on every request,
+// the API flows of the service are shared by all of its implementations
+type sessionManager interface {
+	Login(c echo.Context, user *User) error
+	Logout(c echo.Context) error
+	Refresh(c echo.Context, user *User) error
+	Check(c echo.Context) (*User, error)
+
+	// currentKey returns the store key of the session presented by the request
+	currentKey(c echo.Context) (string, bool)
 }
 
 type service struct {
-	cfg     *config.ServerConfig
-	cache   *redis.Client
-	logger  log.CustomLogger
-	storage *storage.Storage
+	cfg      *config.ServerConfig
+	authCfg  *config.AuthConfig
+	store    storageSession.SessionStore
+	logger   log.CustomLogger
+	alarmer  alarm.Alarmer
+	storage  *storage.Storage
+	mailer   mail.Mailer
+	sessions sessionManager
+
+	webAuthn      *webauthn.WebAuthn
+	oidcProviders map[string]*oidcProvider
 }
 
+func newService(
+	cfg *config.ServerConfig,
+	authCfg *config.AuthConfig,
+	logger log.CustomLogger,
+	alarmer alarm.Alarmer,
+	store storageSession.SessionStore,
+	storage *storage.Storage,
+	mailer mail.Mailer,
+	sessions sessionManager,
+) *service {
+	return &service{
+		logger:   logger,
+		alarmer:  alarmer,
+		storage:  storage,
+		store:    store,
+		cfg:      cfg,
+		authCfg:  authCfg,
+		mailer:   mailer,
+		sessions: sessions,
+		webAuthn: newWebAuthn(authCfg.WebAuthn),
+
+		oidcProviders: newOIDCProviders(authCfg.OIDC),
+	}
+}
+
+// NewSessionCookieService authenticates browsers with an HttpOnly session cookie
 func NewSessionCookieService(
 	cfg *config.ServerConfig,
+	authCfg *config.AuthConfig,
+	logger log.CustomLogger,
+	alarmer alarm.Alarmer,
+	store storageSession.SessionStore,
+	storage *storage.Storage,
+	mailer mail.Mailer,
+) AuthService {
+	sessions := &cookieSessions{cfg: cfg, sessionCfg: authCfg.Session, store: store, logger: logger}
+	return newService(cfg, authCfg, logger, alarmer, store, storage, mailer, sessions)
+}
+
+// NewJWTService authenticates API clients with short-lived signed access tokens sent as
+// "Authorization: Bearer" and rotating refresh tokens, configured by authCfg.JWT
+func NewJWTService(
+	cfg *config.ServerConfig,
+	authCfg *config.AuthConfig,
 	logger log.CustomLogger,
-	cache *redis.Client,
+	alarmer alarm.Alarmer,
+	store storageSession.SessionStore,
 	storage *storage.Storage,
+	mailer mail.Mailer,
 ) AuthService {
-	return &service{logger: logger, storage: storage, cache: cache, cfg: cfg}
+	sessions := newJWTSessions(authCfg.JWT, store, logger)
+	return newService(cfg, authCfg, logger, alarmer, store, storage, mailer, sessions)
 }
 
 // --- GENERIC SESSION METHODS ---
 
-// Login sets the session in cookie and redis
+// Login starts a session for an already authenticated user
 func (s *service) Login(c echo.Context, user *User) error {
-	// Generate a secure session ID
-	sessionID, err := s.generateSessionID()
-	if err != nil {
-		return errSessionGenID
+	if err := s.enforceSessionLimit(c, user); err != nil {
+		return err
 	}
 
-	sessionKey := SessionKeyPrefix + sessionID
-
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return errSessionSerialize
+	user.AuthenticatedAt = time.Now()
+	if err := s.sessions.Login(c, user); err != nil {
+		return err
 	}
 
-	if err := s.cache.Set(
-		c.Request().Context(),
-		sessionKey,
-		userJSON,
-		SessionDefaultExpire,
-	).Err(); err != nil {
-		return errSessionStore
-	}
-
-	cookie := &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   int(SessionDefaultExpire.Seconds()),
-		HttpOnly: true,
-		Secure:   s.cfg.IsProduction(),
-		SameSite: http.SameSiteStrictMode,
-	}
-	c.SetCookie(cookie)
+	s.recordEvent(c, &audit.Event{ActorID: user.ID, Action: audit.ActionLogin, TargetType: audit.TargetUser, TargetID: user.ID})
 	return nil
 }
 
 func (s *service) Logout(c echo.Context) error {
-	cookie, err := c.Cookie(SessionCookieName)
-	if err != nil {
-		// If the session cookie does not exist, nothing to do, just return
-		return nil
+	// the logout route doesn't require a valid session, the user is only known when there is one
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		user, _ = s.sessions.Check(c)
 	}
 
-	sessionID := cookie.Value
-	if sessionID == "" {
-		// If the cookie value is empty, nothing to do, just return
-		return nil
+	if err := s.sessions.Logout(c); err != nil {
+		return err
 	}
 
-	sessionKey := SessionKeyPrefix + sessionID
-	if err := s.cache.Del(
-		c.Request().Context(),
-		sessionKey,
-	).Err(); err != nil {
-		// just log error if needed
+	if user != nil {
+		s.recordEvent(c, &audit.Event{ActorID: user.ID, Action: audit.ActionLogout, TargetType: audit.TargetUser, TargetID: user.ID})
 	}
-
-	expiredCookie := &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   s.cfg.IsProduction(),
-		SameSite: http.SameSiteStrictMode,
-	}
-	c.SetCookie(expiredCookie)
 	return nil
 }
 
-func (s *service) Refresh(c echo.Context, user *User) error {
-	cookie, err := c.Cookie(SessionCookieName)
-	if err != nil {
-		return errSessionCookieNotFound
-	}
-	sessionID := cookie.Value
-	if sessionID == "" {
-		return errEmptySessionID
-	}
-
-	sessionKey := SessionKeyPrefix + sessionID
-	exists, err := s.cache.Exists(c.Request().Context(), sessionKey).Result()
-	if err != nil {
-		return errSessionCheckExist
-	}
-	if exists == 0 {
-		return errSessionNotFound
+// recordEvent writes an audit event that isn't part of a transaction, a failed
+// write is logged and doesn't fail the request
+func (s *service) recordEvent(c echo.Context, event *audit.Event) {
+	if err := audit.Write(c, s.storage.Audit, event); err != nil {
+		s.logger.ErrorWithContext(c.Request().Context(), "failed to write audit event",
+			s.logger.String("action", string(event.Action)),
+			s.logger.Err(err),
+		)
 	}
+}
 
-	if user == nil {
-		if err := s.cache.Expire(
-			c.Request().Context(),
-			sessionKey,
-			SessionDefaultExpire,
-		).Err(); err != nil {
-			return errSessionStore
-		}
-	} else {
-		userJSON, err := json.Marshal(user)
-		if err != nil {
-			return errSessionSerialize
-		}
-
-		if err := s.cache.Set(
-			c.Request().Context(),
-			sessionKey,
-			userJSON,
-			SessionDefaultExpire,
-		).Err(); err != nil {
-			return errSessionStore
+// Refresh extends the current session, a non-nil user replaces the stored one
+func (s *service) Refresh(c echo.Context, user *User) error {
+	// the impersonation and authentication time belong to the session, not to the user data
+	if current, ok := GetUserFromContext(c); ok && user != nil && user.ID == current.ID {
+		user.Impersonation = current.Impersonation
+		if user.AuthenticatedAt.IsZero() {
+			user.AuthenticatedAt = current.AuthenticatedAt
 		}
 	}
-
-	refreshedCookie := &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   int(SessionDefaultExpire.Seconds()),
-		HttpOnly: true,
-		Secure:   s.cfg.IsProduction(),
-		SameSite: http.SameSiteStrictMode,
-	}
-	c.SetCookie(refreshedCookie)
-	return nil
+	return s.sessions.Refresh(c, user)
 }
 
+// Check resolves the user of the current request and stores it in the context,
+// personal access tokens are accepted next to whatever the session manager issues
 func (s *service) Check(c echo.Context) (*User, error) {
-	cookie, err := c.Cookie(SessionCookieName)
-	if err != nil {
-		return nil, errSessionCookieNotFound
-	}
-	sessionID := cookie.Value
-	if sessionID == "" {
-		return nil, errEmptySessionID
+	if token, ok := bearerToken(c); ok && strings.HasPrefix(token, APIKeyPrefix) {
+		user, err := s.checkAPIKey(c, token)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(string(UserContextKey), user)
+		return user, nil
 	}
 
-	sessionKey := SessionKeyPrefix + sessionID
-	userJSON, err := s.cache.Get(c.Request().Context(), sessionKey).Result()
+	user, err := s.sessions.Check(c)
 	if err != nil {
-		if err == redis.Nil {
-			return nil, errSessionNotFound
+		return nil, err
+	}
+	if user.Impersonation != nil {
+		if err := s.checkImpersonation(c, user); err != nil {
+			return nil, err
 		}
-		return nil, errSessionCheckExist
 	}
+	c.Set(string(UserContextKey), user)
+	s.touchSession(c, sessionOwnerID(user))
+	return user, nil
+}
 
-	var user User
-	if err := json.Unmarshal([]byte(userJSON), &user); err != nil {
-		return nil, errSessionDeserialize
-	}
-	c.Set(string(UserContextKey), &user)
-	return &user, nil
+// rememberMe reports whether the login of the request asked for a persistent session
+func rememberMe(c echo.Context) bool {
+	remember, _ := c.Get(string(rememberMeContextKey)).(bool)
+	return remember
 }
 
 // GetUserFromContext retrieves the user from the echo context
@@ -229,8 +302,43 @@ func GetUserFromContext(c echo.Context) (*User, bool) {
 	return user, ok
 }
 
-// CheckAuth is authentication middleware, with optional and role-based support
-func (s *service) CheckAuth(isOptional bool, roles ...string) echo.MiddlewareFunc {
+// CheckAuthOption configures the CheckAuth middleware
+type CheckAuthOption func(*checkAuthOptions)
+
+type checkAuthOptions struct {
+	roles                []string
+	requireVerifiedEmail bool
+	requireSecondFactor  bool
+}
+
+// WithRoles only lets users with one of the given roles through
+func WithRoles(roles ...string) CheckAuthOption {
+	return func(o *checkAuthOptions) {
+		o.roles = append(o.roles, roles...)
+	}
+}
+
+// WithVerifiedEmail rejects users who haven't verified their email address yet
+func WithVerifiedEmail() CheckAuthOption {
+	return func(o *checkAuthOptions) {
+		o.requireVerifiedEmail = true
+	}
+}
+
+// WithSecondFactor rejects sessions that weren't established with a second factor
+func WithSecondFactor() CheckAuthOption {
+	return func(o *checkAuthOptions) {
+		o.requireSecondFactor = true
+	}
+}
+
+// CheckAuth is authentication middleware, with optional, role-based, email verification and second factor support
+func (s *service) CheckAuth(isOptional bool, opts ...CheckAuthOption) echo.MiddlewareFunc {
+	options := new(checkAuthOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			user, err := s.Check(c)
@@ -238,22 +346,40 @@ func (s *service) CheckAuth(isOptional bool, roles ...string) echo.MiddlewareFun
 				if isOptional {
 					return next(c)
 				}
+				// the SPA tells the user why they were logged out
+				if errors.Is(err, errSessionEvicted) {
+					return errSessionEvicted
+				}
 				return shared.ErrSessionUnauthorized
 			}
 
-			if len(roles) > 0 {
+			if len(options.roles) > 0 {
 				hasRole := false
-				for _, requiredRole := range roles {
+				for _, requiredRole := range options.roles {
 					if user.Role == requiredRole {
 						hasRole = true
 						break
 					}
 				}
 				if !hasRole {
-					return shared.ErrSessionUnauthorized
+					return shared.ErrForbidden
+				}
+			}
+
+			if options.requireVerifiedEmail && !user.EmailVerified {
+				verified, err := s.reloadEmailVerified(c, user)
+				if err != nil {
+					return err
+				}
+				if !verified {
+					return errEmailNotVerified
 				}
 			}
 
+			if options.requireSecondFactor && !user.SecondFactor {
+				return errSecondFactorRequired
+			}
+
 			return next(c)
 		}
 	}
@@ -261,17 +387,50 @@ func (s *service) CheckAuth(isOptional bool, roles ...string) echo.MiddlewareFun
 
 // --- AUTH API METHODS ---
 
-func (s *service) apiLogin(c echo.Context, req *LoginRequest) error {
+func (s *service) apiLogin(c echo.Context, req *LoginRequest) (*LoginResponse, error) {
+	ctx := c.Request().Context()
+
+	c.Set(string(rememberMeContextKey), req.RememberMe)
+
+	// Locked emails and IPs are refused before the password is looked at
+	if err := s.checkLoginLock(c, req.Email); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
-	userRow, err := s.storage.Auth.GetUserByEmail(c.Request().Context(), req.Email)
+	userRow, err := s.storage.Auth.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		return shared.ErrSessionUnauthorized
+		s.recordEvent(c, &audit.Event{Action: audit.ActionLoginFailed, TargetType: audit.TargetUser})
+		if lockErr := s.recordLoginFailure(c, req.Email); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, shared.ErrSessionUnauthorized
 	}
 
-	// Check password using bcrypt helper
+	// Check password, the algorithm is taken from the stored hash
 	if !utils.CheckPasswordHash(req.Password, userRow.Password) {
-		return shared.ErrSessionUnauthorized
+		s.recordEvent(c, &audit.Event{Action: audit.ActionLoginFailed, TargetType: audit.TargetUser, TargetID: userRow.ID})
+		if lockErr := s.recordLoginFailure(c, req.Email); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, shared.ErrSessionUnauthorized
+	}
+
+	// Hashes of older algorithms or parameters are upgraded while the password is at hand
+	if utils.PasswordNeedsRehash(userRow.Password, s.authCfg.PasswordHash) {
+		s.rehashPassword(c, userRow.ID, userRow.Password, req.Password)
+	}
+
+	// Users with two-factor authentication finish the login on /login/2fa, the failed
+	// logins are only cleared once the second factor is checked as well
+	_, err = s.storage.Auth.GetUserTotp(ctx, userRow.ID)
+	if err == nil {
+		return s.startTwoFactorLogin(c, userRow.ID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
 	}
+	s.clearLoginFailures(c, userRow.Email)
 
 	user := &User{
 		ID:        userRow.ID,
@@ -281,9 +440,30 @@ func (s *service) apiLogin(c echo.Context, req *LoginRequest) error {
 		Role:      userRow.Role,
 		CreatedAt: userRow.CreatedAt,
 		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+	}
+
+	return nil, s.Login(c, user)
+}
+
+// rehashPassword replaces an outdated hash, failures are logged since the login itself succeeded
+func (s *service) rehashPassword(c echo.Context, userID int64, oldHash, password string) {
+	ctx := c.Request().Context()
+
+	newHash, err := utils.HashPassword(password, s.authCfg.PasswordHash)
+	if err != nil {
+		s.logger.WarnWithContext(ctx, "failed to rehash password", s.logger.Err(err))
+		return
 	}
 
-	return s.Login(c, user)
+	if _, err := s.storage.User.RehashUserPassword(ctx, userSqlc.RehashUserPasswordParams{
+		NewPassword: newHash,
+		ID:          userID,
+		OldPassword: oldHash,
+	}); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to store rehashed password", s.logger.Err(err))
+	}
 }
 
 // APIRefresh: handler-specific auth method for /refresh
@@ -296,13 +476,3 @@ func (s *service) apiRefresh(c echo.Context) error {
 func (s *service) APILogout(c echo.Context) error {
 	return s.Logout(c)
 }
-
-// generateSessionID creates a cryptographically secure random session ID
-func (s *service) generateSessionID() (string, error) {
-	bytes := make([]byte, 32) // 256 bits
-	_, err := rand.Read(bytes)
-	if err != nil {
-		return "", errSessionGenID
-	}
-	return hex.EncodeToString(bytes), nil
-}
@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/utils"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SessionTouchInterval throttles how often the last seen time of a session is written
+const SessionTouchInterval = time.Minute
+
+// SESSION_EVICTED:<public session ID> -> marks a session ended by the session limit, for as long as it would have lived
+const SessionEvictedKeyPrefix = "SESSION_EVICTED:"
+
+// sessionInfo is stored in the USER_SESSIONS:<user ID> hash under the public ID of the session
+type sessionInfo struct {
+	// store key of the cookie session or refresh token family
+	Key        string    `json:"key"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+}
+
+// userSessionsKey returns the key of the session index of a user
+func userSessionsKey(userID int64) string {
+	return UserSessionsKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// sessionOwnerID returns the user whose index lists the session of user. A session of an
+// impersonation belongs to the admin, it is kept out of the sessions and the limit of the target
+func sessionOwnerID(user *User) int64 {
+	if user.Impersonation != nil {
+		return user.Impersonation.Admin.ID
+	}
+	return user.ID
+}
+
+// publicSessionID identifies a session in the API without revealing the secret it's looked up by
+func publicSessionID(key string) string {
+	return utils.HashToken(key)[:32]
+}
+
+// newSessionInfo describes a session created by the current request
+func newSessionInfo(c echo.Context, key string) ([]byte, error) {
+	now := time.Now()
+	infoJSON, err := json.Marshal(&sessionInfo{
+		Key:        key,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		IP:         c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+	})
+	if err != nil {
+		return nil, errSessionSerialize
+	}
+	return infoJSON, nil
+}
+
+// touchSession records when and from where the current session was last used
+func (s *service) touchSession(c echo.Context, userID int64) {
+	key, ok := s.sessions.currentKey(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request().Context()
+	indexKey := userSessionsKey(userID)
+	id := publicSessionID(key)
+
+	infoJSON, err := s.store.HGet(ctx, indexKey, id)
+	if err != nil {
+		if !errors.Is(err, storageSession.ErrNotFound) {
+			s.logger.WarnWithContext(ctx, "failed to read session info", s.logger.Err(err))
+		}
+		return
+	}
+
+	var info sessionInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return
+	}
+	if time.Since(info.LastSeenAt) < SessionTouchInterval {
+		return
+	}
+
+	info.LastSeenAt = time.Now()
+	info.IP = c.RealIP()
+	updatedJSON, err := json.Marshal(&info)
+	if err != nil {
+		return
+	}
+	if err := s.store.HSet(ctx, indexKey, id, string(updatedJSON), 0); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to update session info", s.logger.Err(err))
+	}
+}
+
+// listSessions returns the live sessions of a user, entries of expired sessions are pruned
+func (s *service) listSessions(ctx context.Context, userID int64) (map[string]*sessionInfo, error) {
+	indexKey := userSessionsKey(userID)
+
+	entries, err := s.store.HGetAll(ctx, indexKey)
+	if err != nil {
+		return nil, errSessionCheckExist
+	}
+
+	sessions := make(map[string]*sessionInfo, len(entries))
+	var stale []string
+	for id, infoJSON := range entries {
+		info := new(sessionInfo)
+		if err := json.Unmarshal([]byte(infoJSON), info); err != nil {
+			return nil, errSessionDeserialize
+		}
+
+		exists, err := s.store.Exists(ctx, info.Key)
+		if err != nil {
+			return nil, errSessionCheckExist
+		}
+		if !exists {
+			stale = append(stale, id)
+			continue
+		}
+		sessions[id] = info
+	}
+	if len(stale) > 0 {
+		if err := s.store.HDel(ctx, indexKey, stale...); err != nil {
+			s.logger.WarnWithContext(ctx, "failed to prune expired sessions", s.logger.Err(err))
+		}
+	}
+
+	return sessions, nil
+}
+
+// enforceSessionLimit makes room for a new session within the limit of the user's role,
+// evicting the oldest sessions or rejecting the login depending on the policy
+func (s *service) enforceSessionLimit(c echo.Context, user *User) error {
+	limit := s.authCfg.SessionLimit.PerRole[user.Role]
+	if limit <= 0 {
+		return nil
+	}
+
+	ctx := c.Request().Context()
+	sessions, err := s.listSessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	excess := len(sessions) - limit + 1
+	if excess <= 0 {
+		return nil
+	}
+	if s.authCfg.SessionLimit.Policy == config.SessionLimitReject {
+		return errSessionLimitReached
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for id := range sessions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return sessions[ids[i]].CreatedAt.Before(sessions[ids[j]].CreatedAt)
+	})
+
+	for _, id := range ids[:excess] {
+		if err := s.evictSession(ctx, user.ID, id, sessions[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evictSession removes a session and leaves a marker behind, so that its next use is
+// told apart from an expired or logged out session
+func (s *service) evictSession(ctx context.Context, userID int64, id string, info *sessionInfo) error {
+	ttl, err := s.store.TTL(ctx, info.Key)
+	if err != nil {
+		return errSessionRevoke
+	}
+	if ttl > 0 {
+		if err := s.store.Set(ctx, SessionEvictedKeyPrefix+id, "1", ttl); err != nil {
+			return errSessionRevoke
+		}
+	}
+
+	if _, err := s.store.Del(ctx, info.Key); err != nil {
+		return errSessionRevoke
+	}
+	if err := s.store.HDel(ctx, userSessionsKey(userID), id); err != nil {
+		return errSessionRevoke
+	}
+	return nil
+}
+
+// sessionEvicted reports whether the session stored under key was ended by the session limit
+func sessionEvicted(ctx context.Context, store storageSession.SessionStore, key string) bool {
+	evicted, err := store.Exists(ctx, SessionEvictedKeyPrefix+publicSessionID(key))
+	return err == nil && evicted
+}
+
+// LogoutAll removes every session of the given user, the caller's cookie or token is
+// left untouched and simply stops resolving to a session. Access tokens already handed
+// out stay valid until they expire, which is why they are short-lived
+func (s *service) LogoutAll(ctx context.Context, userID int64) error {
+	return s.logoutAllExcept(ctx, userID, "")
+}
+
+// LogoutOthers removes every session of the given user but the one of the request
+func (s *service) LogoutOthers(c echo.Context, userID int64) error {
+	key, _ := s.sessions.currentKey(c)
+	return s.logoutAllExcept(c.Request().Context(), userID, key)
+}
+
+// logoutAllExcept removes the sessions of a user, keeping the one stored under keepKey if set
+func (s *service) logoutAllExcept(ctx context.Context, userID int64, keepKey string) error {
+	indexKey := userSessionsKey(userID)
+
+	// the index holds cookie sessions and refresh token families alike
+	entries, err := s.store.HGetAll(ctx, indexKey)
+	if err != nil {
+		return errSessionRevoke
+	}
+
+	keys := make([]string, 0, len(entries)+1)
+	ids := make([]string, 0, len(entries))
+	for id, infoJSON := range entries {
+		var info sessionInfo
+		if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+			continue
+		}
+		if keepKey != "" && info.Key == keepKey {
+			continue
+		}
+		keys = append(keys, info.Key)
+		ids = append(ids, id)
+	}
+
+	// without a session to keep, the whole index goes
+	if keepKey == "" {
+		keys = append(keys, indexKey)
+	} else if len(ids) > 0 {
+		if err := s.store.HDel(ctx, indexKey, ids...); err != nil {
+			return errSessionRevoke
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	if _, err := s.store.Del(ctx, keys...); err != nil {
+		return errSessionRevoke
+	}
+	return nil
+}
+
+// --- SESSION API METHODS ---
+
+func (s *service) apiListSessions(c echo.Context) ([]SessionResponse, error) {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return nil, shared.ErrSessionUnauthorized
+	}
+
+	sessions, err := s.listSessions(c.Request().Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	currentID := ""
+	if key, ok := s.sessions.currentKey(c); ok {
+		currentID = publicSessionID(key)
+	}
+
+	resData := make([]SessionResponse, 0, len(sessions))
+	for id, info := range sessions {
+		resData = append(resData, SessionResponse{
+			ID:         id,
+			CreatedAt:  info.CreatedAt,
+			LastSeenAt: info.LastSeenAt,
+			IP:         info.IP,
+			UserAgent:  info.UserAgent,
+			Current:    id == currentID,
+		})
+	}
+	sort.Slice(resData, func(i, j int) bool {
+		return resData[i].LastSeenAt.After(resData[j].LastSeenAt)
+	})
+	return resData, nil
+}
+
+func (s *service) apiRevokeSession(c echo.Context, req *RevokeSessionRequest) error {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	// revoking the current session is a plain logout, which also clears the cookie
+	if key, ok := s.sessions.currentKey(c); ok && publicSessionID(key) == req.ID {
+		return s.Logout(c)
+	}
+
+	ctx := c.Request().Context()
+	indexKey := userSessionsKey(user.ID)
+
+	infoJSON, err := s.store.HGet(ctx, indexKey, req.ID)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return errSessionUnknown
+	}
+	if err != nil {
+		return errSessionCheckExist
+	}
+
+	var info sessionInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return errSessionDeserialize
+	}
+
+	if _, err := s.store.Del(ctx, info.Key); err != nil {
+		return errSessionRevoke
+	}
+	if err := s.store.HDel(ctx, indexKey, req.ID); err != nil {
+		return errSessionRevoke
+	}
+	return nil
+}
+
+func (s *service) apiLogoutAll(c echo.Context) error {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	if err := s.LogoutAll(c.Request().Context(), user.ID); err != nil {
+		return err
+	}
+	if err := s.sessions.Logout(c); err != nil {
+		return err
+	}
+
+	s.recordEvent(c, &audit.Event{ActorID: user.ID, Action: audit.ActionLogoutAll, TargetType: audit.TargetUser, TargetID: user.ID})
+	return nil
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-echo-template/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// loginCookie logs the user in from the given device and returns its session cookie
+func loginCookie(t *testing.T, svc *service, user *User, userAgent string) *http.Cookie {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(echo.HeaderXRealIP, "203.0.113.7")
+	rec := httptest.NewRecorder()
+	require.NoError(t, svc.Login(echo.New().NewContext(req, rec), user))
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionCookieName {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie set")
+	return nil
+}
+
+// newCookieContext builds an authenticated request, the user is resolved like CheckAuth does
+func newCookieContext(t *testing.T, svc *service, cookie *http.Cookie) (echo.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	_, err := svc.Check(c)
+	require.NoError(t, err)
+	return c, rec
+}
+
+func TestSessions(t *testing.T) {
+	user := &User{ID: 42, Email: "jane@example.com"}
+
+	t.Run("List Marks Current Session", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		laptop := loginCookie(t, svc, user, "laptop")
+		loginCookie(t, svc, user, "phone")
+		loginCookie(t, svc, &User{ID: 7}, "someone else")
+
+		c, _ := newCookieContext(t, svc, laptop)
+		sessions, err := svc.apiListSessions(c)
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+
+		userAgents := map[string]bool{}
+		for _, session := range sessions {
+			userAgents[session.UserAgent] = session.Current
+			require.Equal(t, "203.0.113.7", session.IP)
+			require.False(t, session.CreatedAt.IsZero())
+			require.NotContains(t, session.ID, laptop.Value, "the session secret must not be exposed")
+		}
+		require.Equal(t, map[string]bool{"laptop": true, "phone": false}, userAgents)
+	})
+
+	t.Run("Expired Sessions Are Pruned", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		laptop := loginCookie(t, svc, user, "laptop")
+		phone := loginCookie(t, svc, user, "phone")
+
+		_, err := store.Del(context.Background(), cookieSessionKey(phone))
+		require.NoError(t, err)
+
+		c, _ := newCookieContext(t, svc, laptop)
+		sessions, err := svc.apiListSessions(c)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.Equal(t, "laptop", sessions[0].UserAgent)
+
+		fields, err := store.HGetAll(context.Background(), UserSessionsKeyPrefix+"42")
+		require.NoError(t, err)
+		require.Len(t, fields, 1)
+	})
+
+	t.Run("Revoke Other Session", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		laptop := loginCookie(t, svc, user, "laptop")
+		phone := loginCookie(t, svc, user, "phone")
+
+		c, _ := newCookieContext(t, svc, laptop)
+		require.ErrorIs(t, svc.apiRevokeSession(c, &RevokeSessionRequest{ID: "unknown"}), errSessionUnknown)
+
+		sessions, err := svc.apiListSessions(c)
+		require.NoError(t, err)
+		var phoneID string
+		for _, session := range sessions {
+			if !session.Current {
+				phoneID = session.ID
+			}
+		}
+		require.NoError(t, svc.apiRevokeSession(c, &RevokeSessionRequest{ID: phoneID}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(phone)
+		_, err = svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.ErrorIs(t, err, errSessionNotFound)
+
+		// sessions of other users can't be revoked by their ID
+		other := loginCookie(t, svc, &User{ID: 7}, "someone else")
+		otherCtx, _ := newCookieContext(t, svc, other)
+		otherSessions, err := svc.apiListSessions(otherCtx)
+		require.NoError(t, err)
+		require.ErrorIs(t, svc.apiRevokeSession(c, &RevokeSessionRequest{ID: otherSessions[0].ID}), errSessionUnknown)
+
+		// the current session is left alone
+		newCookieContext(t, svc, laptop)
+	})
+
+	t.Run("Revoke Current Session Clears Cookie", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		laptop := loginCookie(t, svc, user, "laptop")
+
+		c, rec := newCookieContext(t, svc, laptop)
+		sessions, err := svc.apiListSessions(c)
+		require.NoError(t, err)
+		require.NoError(t, svc.apiRevokeSession(c, &RevokeSessionRequest{ID: sessions[0].ID}))
+		require.True(t, strings.Contains(rec.Header().Get("Set-Cookie"), "Max-Age=0"))
+	})
+
+	t.Run("Logout Everywhere", func(t *testing.T) {
+		svc, _, _, store := newPasswordResetTestService(t)
+		laptop := loginCookie(t, svc, user, "laptop")
+		loginCookie(t, svc, user, "phone")
+		other := loginCookie(t, svc, &User{ID: 7}, "someone else")
+
+		c, _ := newCookieContext(t, svc, laptop)
+		require.NoError(t, svc.apiLogoutAll(c))
+
+		require.False(t, store.has(UserSessionsKeyPrefix+"42"))
+		require.Len(t, store.Keys(), 2, "only the other user's session and index remain")
+		newCookieContext(t, svc, other)
+	})
+
+	t.Run("Logout Others Keeps Current Session", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		laptop := loginCookie(t, svc, user, "laptop")
+		phone := loginCookie(t, svc, user, "phone")
+
+		c, _ := newCookieContext(t, svc, laptop)
+		require.NoError(t, svc.LogoutOthers(c, user.ID))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(phone)
+		_, err := svc.Check(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.ErrorIs(t, err, errSessionNotFound)
+
+		c, _ = newCookieContext(t, svc, laptop)
+		sessions, err := svc.apiListSessions(c)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.True(t, sessions[0].Current)
+	})
+}
+
+func TestSessionLimit(t *testing.T) {
+	user := &User{ID: 42, Email: "jane@example.com", Role: "user"}
+	next := func(c echo.Context) error { return nil }
+
+	// checkCookie runs a request with the cookie through CheckAuth
+	checkCookie := func(svc *service, cookie *http.Cookie) error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		return svc.CheckAuth(false)(next)(echo.New().NewContext(req, httptest.NewRecorder()))
+	}
+
+	t.Run("Oldest Session Is Evicted", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		svc.authCfg.SessionLimit = &config.SessionLimitConfig{PerRole: map[string]int{"user": 2}, Policy: config.SessionLimitEvict}
+
+		laptop := loginCookie(t, svc, user, "laptop")
+		phone := loginCookie(t, svc, user, "phone")
+		tablet := loginCookie(t, svc, user, "tablet")
+
+		require.ErrorIs(t, checkCookie(svc, laptop), errSessionEvicted)
+		require.NoError(t, checkCookie(svc, phone))
+		require.NoError(t, checkCookie(svc, tablet))
+
+		// other roles and users aren't limited
+		admin := &User{ID: 7, Role: "admin"}
+		for _, device := range []string{"a", "b", "c"} {
+			loginCookie(t, svc, admin, device)
+		}
+		sessions, err := svc.listSessions(context.Background(), admin.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 3)
+	})
+
+	t.Run("Login Over The Limit Is Rejected", func(t *testing.T) {
+		svc, _, _, _ := newPasswordResetTestService(t)
+		svc.authCfg.SessionLimit = &config.SessionLimitConfig{PerRole: map[string]int{"user": 1}, Policy: config.SessionLimitReject}
+
+		laptop := loginCookie(t, svc, user, "laptop")
+		err := svc.Login(newTestContext(), user)
+		require.ErrorIs(t, err, errSessionLimitReached)
+		require.NoError(t, checkCookie(svc, laptop))
+
+		// a logout frees the slot
+		c, _ := newCookieContext(t, svc, laptop)
+		require.NoError(t, svc.Logout(c))
+		require.NoError(t, svc.Login(newTestContext(), user))
+	})
+
+	t.Run("Evicted Refresh Token Family", func(t *testing.T) {
+		svc, _ := newJWTTestService(t, hs256Config())
+		svc.authCfg.SessionLimit = &config.SessionLimitConfig{PerRole: map[string]int{"user": 1}, Policy: config.SessionLimitEvict}
+
+		first := loginTokens(t, svc, user)
+		second := loginTokens(t, svc, user)
+
+		_, err := refreshTokens(svc, first.RefreshToken)
+		require.ErrorIs(t, err, errSessionEvicted)
+		_, err = refreshTokens(svc, second.RefreshToken)
+		require.NoError(t, err)
+	})
+}
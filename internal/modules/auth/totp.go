@@ -0,0 +1,391 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/utils"
+	"go-echo-template/internal/storage"
+	"go-echo-template/internal/storage/auth/sqlc"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	TOTPEnrollExpire          = 10 * time.Minute
+	LoginTwoFactorExpire      = 5 * time.Minute
+	LoginTwoFactorMaxAttempts = 5
+
+	// TOTP_ENROLL:<user ID> -> secret waiting for the first valid code
+	TOTPEnrollKeyPrefix = "TOTP_ENROLL:"
+	// TOTP_USED:<user ID>:<time step> -> rejects replaying an accepted code
+	TOTPUsedKeyPrefix = "TOTP_USED:"
+	// LOGIN_2FA:<token hash> -> pending login of a user whose password was already checked
+	LoginTwoFactorKeyPrefix = "LOGIN_2FA:"
+	// LOGIN_2FA_ATTEMPTS:<token hash> -> number of codes tried for a pending login
+	LoginTwoFactorAttemptsKeyPrefix = "LOGIN_2FA_ATTEMPTS:"
+	// TWO_FACTOR_ATTEMPTS:<user ID> -> number of codes tried for a user across pending logins
+	// and sessions, so that starting new logins doesn't allow more guesses
+	TwoFactorAttemptsKeyPrefix = "TWO_FACTOR_ATTEMPTS:"
+	TwoFactorAttemptsWindow    = 15 * time.Minute
+
+	// accepted time steps on each side of the current one
+	totpSkew = 1
+
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O, 1/I look-alikes
+)
+
+// pendingLogin is a login waiting for its second factor
+type pendingLogin struct {
+	UserID     int64 `json:"userId"`
+	RememberMe bool  `json:"rememberMe"`
+}
+
+// startTwoFactorLogin parks a login whose password was verified until the second factor is provided
+func (s *service) startTwoFactorLogin(c echo.Context, userID int64) (*LoginResponse, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errSessionGenID
+	}
+
+	pendingJSON, err := json.Marshal(&pendingLogin{UserID: userID, RememberMe: rememberMe(c)})
+	if err != nil {
+		return nil, errTwoFactorStore
+	}
+
+	pendingKey := LoginTwoFactorKeyPrefix + utils.HashToken(token)
+	if err := s.store.Set(c.Request().Context(), pendingKey, string(pendingJSON), LoginTwoFactorExpire); err != nil {
+		return nil, errTwoFactorStore
+	}
+
+	return &LoginResponse{TwoFactorRequired: true, TwoFactorToken: token}, nil
+}
+
+func (s *service) apiLoginTwoFactor(c echo.Context, req *LoginTwoFactorRequest) error {
+	ctx := c.Request().Context()
+
+	tokenHash := utils.HashToken(req.Token)
+	pendingKey := LoginTwoFactorKeyPrefix + tokenHash
+	attemptsKey := LoginTwoFactorAttemptsKeyPrefix + tokenHash
+
+	pendingJSON, err := s.store.Get(ctx, pendingKey)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return errTwoFactorTokenInvalid
+	}
+	if err != nil {
+		return errTwoFactorStore
+	}
+
+	// limit the number of guesses per password check
+	attempts, err := s.store.Incr(ctx, attemptsKey, LoginTwoFactorExpire)
+	if err != nil {
+		return errTwoFactorStore
+	}
+	if attempts > LoginTwoFactorMaxAttempts {
+		s.store.Del(ctx, pendingKey, attemptsKey)
+		return errTwoFactorTokenInvalid
+	}
+
+	var pending pendingLogin
+	if err := json.Unmarshal([]byte(pendingJSON), &pending); err != nil {
+		return errTwoFactorTokenInvalid
+	}
+	userID := pending.UserID
+
+	ok, err := s.verifyUserSecondFactor(c, userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.recordEvent(c, &audit.Event{Action: audit.ActionLoginFailed, TargetType: audit.TargetUser, TargetID: userID})
+		return errTOTPCodeInvalid
+	}
+
+	// consume the pending login, a concurrent request may have won the race
+	deleted, err := s.store.Del(ctx, pendingKey)
+	if err != nil {
+		return errTwoFactorStore
+	}
+	if deleted == 0 {
+		return errTwoFactorTokenInvalid
+	}
+	s.store.Del(ctx, attemptsKey)
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, userID)
+	if err == sql.ErrNoRows {
+		return shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	user := &User{
+		ID:        userRow.ID,
+		Name:      userRow.Name,
+		Email:     userRow.Email,
+		Phone:     userRow.Phone.String,
+		Role:      userRow.Role,
+		CreatedAt: userRow.CreatedAt,
+		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+		SecondFactor:  true,
+	}
+	s.clearLoginFailures(c, userRow.Email)
+
+	c.Set(string(rememberMeContextKey), pending.RememberMe)
+	return s.Login(c, user)
+}
+
+// verifyUserSecondFactor checks a code of a user, limited to LoginTwoFactorMaxAttempts per
+// window so that neither new pending logins nor a session allow guessing codes
+func (s *service) verifyUserSecondFactor(c echo.Context, userID int64, code, recoveryCode string) (bool, error) {
+	ctx := c.Request().Context()
+	attemptsKey := TwoFactorAttemptsKeyPrefix + strconv.FormatInt(userID, 10)
+
+	// counted before the check so that concurrent guesses can't overshoot the limit
+	attempts, err := s.store.Incr(ctx, attemptsKey, TwoFactorAttemptsWindow)
+	if err != nil {
+		return false, errTwoFactorStore
+	}
+	if attempts > LoginTwoFactorMaxAttempts {
+		return false, errTwoFactorAttemptsExceeded
+	}
+
+	ok, err := s.verifySecondFactor(c, userID, code, recoveryCode)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if _, err := s.store.Del(ctx, attemptsKey); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to reset second factor attempts", s.logger.Err(err))
+	}
+	return true, nil
+}
+
+// verifySecondFactor checks either a TOTP code or a recovery code, both are single-use
+func (s *service) verifySecondFactor(c echo.Context, userID int64, code, recoveryCode string) (bool, error) {
+	ctx := c.Request().Context()
+
+	if code != "" {
+		totp, err := s.storage.Auth.GetUserTotp(ctx, userID)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+
+		// a code stays valid for the whole skew window, accept it only once
+		usedKey := TOTPUsedKeyPrefix + strconv.FormatInt(userID, 10) + ":" + strconv.FormatInt(step, 10)
+		fresh, err := s.store.SetNX(ctx, usedKey, "1", (2*totpSkew+1)*utils.TOTPPeriod)
+		if err != nil {
+			return false, errTwoFactorStore
+		}
+		return fresh, nil
+	}
+
+	if recoveryCode != "" {
+		affected, err := s.storage.Auth.UseUserRecoveryCode(ctx, sqlc.UseUserRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: utils.HashToken(normalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			return false, err
+		}
+		return affected == 1, nil
+	}
+
+	return false, nil
+}
+
+func (s *service) apiEnrollTOTP(c echo.Context) (*EnrollTOTPResponse, error) {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return nil, shared.ErrSessionUnauthorized
+	}
+
+	_, err := s.storage.Auth.GetUserTotp(ctx, user.ID)
+	if err == nil {
+		return nil, errTOTPAlreadyEnabled
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// access tokens carry no email, the account name is taken from the database
+	userRow, err := s.storage.Auth.GetUserById(ctx, user.ID)
+	if err == sql.ErrNoRows {
+		return nil, shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errTOTPGenSecret
+	}
+
+	enrollKey := TOTPEnrollKeyPrefix + strconv.FormatInt(user.ID, 10)
+	if err := s.store.Set(ctx, enrollKey, secret, TOTPEnrollExpire); err != nil {
+		return nil, errTwoFactorStore
+	}
+
+	return &EnrollTOTPResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.cfg.AppName, userRow.Email, secret),
+	}, nil
+}
+
+func (s *service) apiConfirmTOTP(c echo.Context, req *ConfirmTOTPRequest) (*ConfirmTOTPResponse, error) {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return nil, shared.ErrSessionUnauthorized
+	}
+
+	enrollKey := TOTPEnrollKeyPrefix + strconv.FormatInt(user.ID, 10)
+	secret, err := s.store.Get(ctx, enrollKey)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return nil, errTOTPEnrollmentNotFound
+	}
+	if err != nil {
+		return nil, errTwoFactorStore
+	}
+
+	if _, ok := utils.ValidateTOTP(secret, req.Code, time.Now(), totpSkew); !ok {
+		return nil, errTOTPCodeInvalid
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errTOTPGenSecret
+	}
+
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		if err := storageTx.Auth.CreateUserTotp(ctx, sqlc.CreateUserTotpParams{
+			UserID: user.ID,
+			Secret: secret,
+		}); err != nil {
+			return err
+		}
+
+		if err := storageTx.Auth.DeleteUserRecoveryCodes(ctx, user.ID); err != nil {
+			return err
+		}
+
+		for _, code := range codes {
+			if err := storageTx.Auth.CreateUserRecoveryCode(ctx, sqlc.CreateUserRecoveryCodeParams{
+				UserID:   user.ID,
+				CodeHash: utils.HashToken(normalizeRecoveryCode(code)),
+			}); err != nil {
+				return err
+			}
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionTOTPEnable,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.store.Del(ctx, enrollKey); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to delete pending totp enrolment", s.logger.Err(err))
+	}
+
+	// the user has just proven possession of the second factor
+	user.SecondFactor = true
+	if err := s.Refresh(c, user); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to update session after totp enrolment", s.logger.Err(err))
+	}
+
+	return &ConfirmTOTPResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *service) apiDisableTOTP(c echo.Context, req *DisableTOTPRequest) error {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	ok, err := s.verifyUserSecondFactor(c, user.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errTOTPCodeInvalid
+	}
+
+	err = s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		if err := storageTx.Auth.DeleteUserTotp(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := storageTx.Auth.DeleteUserRecoveryCodes(ctx, user.ID); err != nil {
+			return err
+		}
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionTOTPDisable,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// the session no longer counts as verified with a second factor
+	user.SecondFactor = false
+	return s.Refresh(c, user)
+}
+
+// generateRecoveryCodes returns codes formatted as XXXXX-XXXXX
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		bytes := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, err
+		}
+
+		// the alphabet has 32 characters so masking keeps the distribution uniform
+		var b strings.Builder
+		for j, v := range bytes {
+			if j == recoveryCodeLength/2 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(recoveryCodeAlphabet[v&31])
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode makes user input match regardless of case, spaces and dashes
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/i18n"
+	"go-echo-template/internal/shared/utils"
+	userSqlc "go-echo-template/internal/storage/user/sqlc"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	EmailVerificationCooldown = time.Minute
+
+	// EMAIL_VERIFICATION_COOLDOWN:<user ID> -> throttles verification mails per user
+	EmailVerificationCooldownKeyPrefix = "EMAIL_VERIFICATION_COOLDOWN:"
+
+	emailVerificationPath = "/verify-email"
+)
+
+// SendEmailVerification mails a signed verification link to the given address
+func (s *service) SendEmailVerification(c echo.Context, userID int64, name, email string) error {
+	ctx := c.Request().Context()
+	ttl := s.authCfg.EmailVerification.TTL
+
+	token := s.signEmailVerificationToken(userID, email, time.Now().Add(ttl))
+	link := s.cfg.BaseURL + emailVerificationPath + "?token=" + token
+	msg := mailEmailVerification.Render(email, i18n.GetLocaleFromContext(c), name, link, int(ttl.Hours()))
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.ErrorWithContext(ctx, "failed to send email verification mail", s.logger.Err(err))
+		return errEmailVerificationMail
+	}
+	return nil
+}
+
+// signEmailVerificationToken builds a "<user ID>.<expiry>.<signature>" token. The email
+// is signed but not embedded, so the token stops working once the address changes.
+func (s *service) signEmailVerificationToken(userID int64, email string, expiresAt time.Time) string {
+	payload := strconv.FormatInt(userID, 10) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + utils.Sign(s.authCfg.EmailVerification.Secret, payload+"."+email)
+}
+
+func (s *service) apiVerifyEmail(c echo.Context, req *VerifyEmailRequest) error {
+	ctx := c.Request().Context()
+
+	parts := strings.Split(req.Token, ".")
+	if len(parts) != 3 {
+		return errEmailVerificationTokenInvalid
+	}
+
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errEmailVerificationTokenInvalid
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return errEmailVerificationTokenInvalid
+	}
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, userID)
+	if err == sql.ErrNoRows {
+		return errEmailVerificationTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	payload := parts[0] + "." + parts[1] + "." + userRow.Email
+	if !utils.VerifySignature(s.authCfg.EmailVerification.Secret, payload, parts[2]) {
+		return errEmailVerificationTokenInvalid
+	}
+
+	affected, err := s.storage.User.MarkUserEmailVerified(ctx, userSqlc.MarkUserEmailVerifiedParams{
+		ID:    userRow.ID,
+		Email: userRow.Email,
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errEmailVerificationTokenInvalid
+	}
+
+	return nil
+}
+
+func (s *service) apiResendEmailVerification(c echo.Context) error {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	// the session may be stale, always check the latest state
+	userRow, err := s.storage.Auth.GetUserById(ctx, user.ID)
+	if err == sql.ErrNoRows {
+		return shared.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if userRow.EmailVerifiedAt.Valid {
+		return errEmailAlreadyVerified
+	}
+
+	cooldownKey := EmailVerificationCooldownKeyPrefix + strconv.FormatInt(userRow.ID, 10)
+	allowed, err := s.store.SetNX(ctx, cooldownKey, "1", EmailVerificationCooldown)
+	if err != nil {
+		return errEmailVerificationStore
+	}
+	if !allowed {
+		return errEmailVerificationThrottled
+	}
+
+	return s.SendEmailVerification(c, userRow.ID, userRow.Name, userRow.Email)
+}
+
+// reloadEmailVerified is used when the session says the email is unverified, the
+// session may predate the verification so the database state is checked and the
+// session is updated when it changed
+func (s *service) reloadEmailVerified(c echo.Context, user *User) (bool, error) {
+	ctx := c.Request().Context()
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, user.ID)
+	if err == sql.ErrNoRows {
+		return false, shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return false, err
+	}
+	if !userRow.EmailVerifiedAt.Valid {
+		return false, nil
+	}
+
+	user.EmailVerified = true
+	if err := s.Refresh(c, user); err != nil {
+		s.logger.WarnWithContext(ctx, "failed to update session after email verification", s.logger.Err(err))
+	}
+	return true, nil
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/storage"
+	"go-echo-template/internal/storage/auth/sqlc"
+	storageSession "go-echo-template/internal/storage/session"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	WebAuthnCeremonyExpire = 5 * time.Minute
+
+	// WEBAUTHN_REGISTER:<challenge> -> session data of a pending passkey registration
+	WebAuthnRegisterKeyPrefix = "WEBAUTHN_REGISTER:"
+	// WEBAUTHN_LOGIN:<challenge> -> session data of a pending passkey login
+	WebAuthnLoginKeyPrefix = "WEBAUTHN_LOGIN:"
+)
+
+// webAuthnUser adapts a user and its stored credentials to webauthn.User
+type webAuthnUser struct {
+	id          int64
+	name        string
+	email       string
+	credentials []sqlc.UserCredential
+}
+
+// WebAuthnID is the user handle, the decimal user ID so it can be mapped back on discoverable logins
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatInt(u.id, 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.credentials))
+	for i, row := range u.credentials {
+		credentials[i] = toWebAuthnCredential(row)
+	}
+	return credentials
+}
+
+// credentialRow finds the stored row of a credential returned by the library
+func (u *webAuthnUser) credentialRow(credentialID []byte) (*sqlc.UserCredential, bool) {
+	for i := range u.credentials {
+		if bytes.Equal(u.credentials[i].CredentialID, credentialID) {
+			return &u.credentials[i], true
+		}
+	}
+	return nil, false
+}
+
+func newWebAuthn(cfg *config.WebAuthnConfig) *webauthn.WebAuthn {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		panic("invalid webauthn configuration: " + err.Error())
+	}
+	return w
+}
+
+func (s *service) loadWebAuthnUser(c echo.Context, userID int64) (*webAuthnUser, error) {
+	ctx := c.Request().Context()
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.storage.Auth.ListUserCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{
+		id:          userRow.ID,
+		name:        userRow.Name,
+		email:       userRow.Email,
+		credentials: credentials,
+	}, nil
+}
+
+// storeWebAuthnSession keeps the ceremony state until the browser answers the challenge
+func (s *service) storeWebAuthnSession(c echo.Context, keyPrefix string, session *webauthn.SessionData) error {
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return errWebAuthnStore
+	}
+
+	key := keyPrefix + session.Challenge
+	if err := s.store.Set(c.Request().Context(), key, string(sessionJSON), WebAuthnCeremonyExpire); err != nil {
+		return errWebAuthnStore
+	}
+	return nil
+}
+
+// consumeWebAuthnSession returns the ceremony state of a challenge, each challenge can be answered once
+func (s *service) consumeWebAuthnSession(c echo.Context, keyPrefix, challenge string) (*webauthn.SessionData, error) {
+	sessionJSON, err := s.store.GetDel(c.Request().Context(), keyPrefix+challenge)
+	if errors.Is(err, storageSession.ErrNotFound) {
+		return nil, errWebAuthnChallengeInvalid
+	}
+	if err != nil {
+		return nil, errWebAuthnStore
+	}
+
+	session := new(webauthn.SessionData)
+	if err := json.Unmarshal([]byte(sessionJSON), session); err != nil {
+		return nil, errWebAuthnChallengeInvalid
+	}
+	return session, nil
+}
+
+func (s *service) apiBeginWebAuthnRegistration(c echo.Context) (*protocol.CredentialCreation, error) {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return nil, shared.ErrSessionUnauthorized
+	}
+
+	wUser, err := s.loadWebAuthnUser(c, user.ID)
+	if err == sql.ErrNoRows {
+		return nil, shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// discoverable credentials let the user log in without typing an email first
+	options, session, err := s.webAuthn.BeginRegistration(
+		wUser,
+		webauthn.WithExclusions(webauthn.Credentials(wUser.WebAuthnCredentials()).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, errWebAuthnBegin
+	}
+
+	if err := s.storeWebAuthnSession(c, WebAuthnRegisterKeyPrefix, session); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (s *service) apiFinishWebAuthnRegistration(c echo.Context, req *FinishWebAuthnRegistrationRequest) error {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return errWebAuthnResponseInvalid
+	}
+
+	session, err := s.consumeWebAuthnSession(c, WebAuthnRegisterKeyPrefix, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return err
+	}
+
+	wUser, err := s.loadWebAuthnUser(c, user.ID)
+	if err == sql.ErrNoRows {
+		return shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	// also rejects challenges issued to another user
+	credential, err := s.webAuthn.CreateCredential(wUser, *session, parsed)
+	if err != nil {
+		return errWebAuthnResponseInvalid
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	return s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		if err := storageTx.Auth.CreateUserCredential(ctx, sqlc.CreateUserCredentialParams{
+			UserID:          user.ID,
+			CredentialID:    credential.ID,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Aaguid:          credential.Authenticator.AAGUID,
+			SignCount:       int64(credential.Authenticator.SignCount),
+			CloneWarning:    credential.Authenticator.CloneWarning,
+			Transports:      strings.Join(transports, ","),
+			Flags:           int16(credential.Flags.ProtocolValue()),
+			Name:            req.Name,
+		}); err != nil {
+			return err
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionPasskeyAdd,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Diff:       audit.Diff(nil, map[string]any{"passkey": req.Name}),
+		})
+	})
+}
+
+func (s *service) apiBeginWebAuthnLogin(c echo.Context) (*protocol.CredentialAssertion, error) {
+	options, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, errWebAuthnBegin
+	}
+
+	if err := s.storeWebAuthnSession(c, WebAuthnLoginKeyPrefix, session); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (s *service) apiFinishWebAuthnLogin(c echo.Context, req *FinishWebAuthnLoginRequest) error {
+	ctx := c.Request().Context()
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return errWebAuthnResponseInvalid
+	}
+
+	session, err := s.consumeWebAuthnSession(c, WebAuthnLoginKeyPrefix, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return err
+	}
+
+	var wUser *webAuthnUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.ParseInt(string(userHandle), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		wUser, err = s.loadWebAuthnUser(c, userID)
+		if err != nil {
+			return nil, err
+		}
+		return wUser, nil
+	}
+
+	_, credential, err := s.webAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		return shared.ErrSessionUnauthorized
+	}
+
+	row, ok := wUser.credentialRow(credential.ID)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	if err := s.storage.Auth.UpdateUserCredentialUsage(ctx, sqlc.UpdateUserCredentialUsageParams{
+		ID:           row.ID,
+		SignCount:    int64(credential.Authenticator.SignCount),
+		CloneWarning: credential.Authenticator.CloneWarning,
+		Flags:        int16(credential.Flags.ProtocolValue()),
+	}); err != nil {
+		return err
+	}
+
+	// a signature counter going backwards means the key may have been copied
+	if credential.Authenticator.CloneWarning {
+		s.logger.WarnWithContext(ctx, "webauthn clone warning, login rejected",
+			s.logger.Any("userID", wUser.id),
+			s.logger.Any("credentialID", row.ID),
+		)
+		return errWebAuthnCloneWarning
+	}
+
+	userRow, err := s.storage.Auth.GetUserById(ctx, wUser.id)
+	if err == sql.ErrNoRows {
+		return shared.ErrSessionUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	user := &User{
+		ID:        userRow.ID,
+		Name:      userRow.Name,
+		Email:     userRow.Email,
+		Phone:     userRow.Phone.String,
+		Role:      userRow.Role,
+		CreatedAt: userRow.CreatedAt,
+		UpdatedAt: userRow.UpdatedAt,
+
+		EmailVerified: userRow.EmailVerifiedAt.Valid,
+		// user verification makes a passkey both possession and knowledge/biometric
+		SecondFactor: credential.Flags.UserVerified,
+	}
+
+	return s.Login(c, user)
+}
+
+func (s *service) apiListWebAuthnCredentials(c echo.Context) ([]WebAuthnCredentialResponse, error) {
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return nil, shared.ErrSessionUnauthorized
+	}
+
+	rows, err := s.storage.Auth.ListUserCredentials(c.Request().Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]WebAuthnCredentialResponse, len(rows))
+	for i, row := range rows {
+		credentials[i] = WebAuthnCredentialResponse{
+			ID:        row.ID,
+			Name:      row.Name,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.LastUsedAt.Valid {
+			credentials[i].LastUsedAt = &row.LastUsedAt.Time
+		}
+	}
+	return credentials, nil
+}
+
+func (s *service) apiDeleteWebAuthnCredential(c echo.Context, req *DeleteWebAuthnCredentialRequest) error {
+	ctx := c.Request().Context()
+	user, ok := GetUserFromContext(c)
+	if !ok {
+		return shared.ErrSessionUnauthorized
+	}
+
+	return s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		affected, err := storageTx.Auth.DeleteUserCredential(ctx, sqlc.DeleteUserCredentialParams{
+			ID:     req.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return errWebAuthnCredentialNotFound
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    user.ID,
+			Action:     audit.ActionPasskeyRemove,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Diff:       audit.Diff(map[string]any{"passkeyId": req.ID}, nil),
+		})
+	})
+}
+
+func toWebAuthnCredential(row sqlc.UserCredential) webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	if row.Transports != "" {
+		for _, transport := range strings.Split(row.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              row.CredentialID,
+		PublicKey:       row.PublicKey,
+		AttestationType: row.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(row.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       row.Aaguid,
+			SignCount:    uint32(row.SignCount),
+			CloneWarning: row.CloneWarning,
+		},
+	}
+}
@@ -1,5 +1,7 @@
 package user
 
+import "go-echo-template/internal/shared/query"
+
 type GetUserResponse struct {
 	ID        int64  `json:"id"`
 	Name      string `json:"name"`
@@ -9,7 +11,15 @@ type GetUserResponse struct {
 	UpdatedAt string `json:"updatedAt"`
 
 	// optional
-	Phone *string `json:"phone"`
+	Phone  *string         `json:"phone"`
+	Avatar *AvatarResponse `json:"avatar"`
+}
+
+// AvatarResponse links the avatar and its square thumbnails, keyed by their size in
+// pixels. Signed URLs expire, clients should not store them
+type AvatarResponse struct {
+	URL        string            `json:"url"`
+	Thumbnails map[string]string `json:"thumbnails"`
 }
 
 type CreateUserRequest struct {
@@ -26,11 +36,52 @@ type CreateUserResponse struct {
 	UserID int64 `json:"userId"`
 }
 
+// UpdateUserRequest changes the profile, the email has its own confirmed flow
 type UpdateUserRequest struct {
-	ID    int64
-	Name  string `json:"name" validate:"required,min=3,max=20,alpha"`
-	Email string `json:"email" validate:"required,email"`
+	ID   int64
+	Name string `json:"name" validate:"required,min=3,max=20,alpha"`
 
 	// optional
 	Phone *string `json:"phone" validate:"omitempty,phone"`
 }
+
+type ChangeEmailRequest struct {
+	ID    int64
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// listUsersQuery is what the admin user list can be sorted and filtered by, missing
+// filters match everyone. Search matches a part of the name or email.
+var listUsersQuery = &query.Spec{
+	Sorts:       []string{"name", "email", "createdAt"},
+	DefaultSort: "-createdAt",
+	Filters: map[string]query.FilterSpec{
+		"search":    {Type: query.String, Ops: []query.Op{query.OpContains}},
+		"role":      {Type: query.String, Ops: []query.Op{query.OpEq}},
+		"deleted":   {Type: query.Bool, Ops: []query.Op{query.OpEq}},
+		"suspended": {Type: query.Bool, Ops: []query.Op{query.OpEq}},
+	},
+}
+
+type ChangePasswordRequest struct {
+	ID              int64
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,password"`
+}
+
+type ChangeUserRoleRequest struct {
+	ID   int64
+	Role string `json:"role" validate:"required,oneof=user admin subadmin"`
+}
+
+// AdminUserResponse is a user as admins see it, account state included
+type AdminUserResponse struct {
+	GetUserResponse
+	EmailVerified bool    `json:"emailVerified"`
+	Deleted       bool    `json:"deleted"`
+	SuspendedAt   *string `json:"suspendedAt"`
+}
@@ -1,13 +1,16 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"go-echo-template/internal/alarm"
 	"go-echo-template/internal/modules/auth"
+	"go-echo-template/internal/object"
 	"go-echo-template/internal/shared"
 	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/query"
 	"go-echo-template/internal/shared/response"
 
 	"github.com/labstack/echo/v4"
@@ -29,20 +32,28 @@ func (h *UserHandler) RegisterRoutes(e *echo.Group) {
 	users := e.Group("/v1/users")
 	// public API
 	users.POST("/", h.CreateUser)
+	users.POST("/email/confirm", h.ConfirmEmailChange)
 
 	// authenticated APIs
-	usersAuth := users.Group("", h.auth.CheckAuth(false, shared.RoleCustomer))
+	usersAuth := users.Group("", h.auth.CheckAuth(false))
 	usersAuth.GET("/:id", h.GetUser)
 	usersAuth.PATCH("/:id", h.UpdateUser)
-	usersAuth.DELETE("/:id", h.DeleteUser)
+	usersAuth.POST("/:id/email", h.RequestEmailChange, h.auth.RefuseImpersonation(), h.auth.RequireRecentAuth(auth.RecentAuthMaxAge))
+	usersAuth.POST("/:id/password", h.ChangePassword, h.auth.RefuseAPIKey(), h.auth.RefuseImpersonation())
+	usersAuth.PUT("/:id/avatar", h.UploadAvatar)
+	usersAuth.DELETE("/:id", h.DeleteUser, h.auth.RefuseImpersonation(), h.auth.RequireRecentAuth(auth.RecentAuthMaxAge))
+
+	// admin APIs
+	admin := e.Group("/v1/admin/users", h.auth.CheckAuth(false))
+	admin.GET("", h.ListUsers, h.auth.RequirePermission(auth.PermUsersReadAny))
+	admin.GET("/:id", h.GetAnyUser, h.auth.RequirePermission(auth.PermUsersReadAny))
+	admin.POST("/:id/suspend", h.SuspendUser, h.auth.RequirePermission(auth.PermUsersSuspendAny))
+	admin.POST("/:id/restore", h.RestoreUser, h.auth.RequirePermission(auth.PermUsersSuspendAny))
+	admin.PUT("/:id/role", h.ChangeUserRole, h.auth.RequirePermission(auth.PermUsersUpdateRoleAny), h.auth.RequireRecentAuth(auth.RecentAuthMaxAge))
 }
 
 func (h *UserHandler) GetUser(c echo.Context) error {
 	ctx := c.Request().Context()
-	userFromCtx, ok := auth.GetUserFromContext(c)
-	if !ok {
-		return shared.ErrUserNotFound
-	}
 
 	// validate input
 	param := c.Param("id")
@@ -52,8 +63,8 @@ func (h *UserHandler) GetUser(c echo.Context) error {
 	}
 
 	// Access Control
-	if userFromCtx.ID != id {
-		return shared.ErrSessionUnauthorized
+	if err := auth.AuthorizeOwner(c, id, auth.PermUsersReadSelf, auth.PermUsersReadAny); err != nil {
+		return err
 	}
 
 	// service call
@@ -66,8 +77,6 @@ func (h *UserHandler) GetUser(c echo.Context) error {
 }
 
 func (h *UserHandler) CreateUser(c echo.Context) error {
-	ctx := c.Request().Context()
-
 	// validate input
 	cur := new(CreateUserRequest)
 	if err := c.Bind(cur); err != nil {
@@ -78,7 +87,7 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 	}
 
 	// service call
-	newUserID, err := h.service.createUser(ctx, cur)
+	newUserID, err := h.service.createUser(c, cur)
 	if err != nil {
 		return err
 	}
@@ -92,11 +101,6 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 }
 
 func (h *UserHandler) UpdateUser(c echo.Context) error {
-	userFromCtx, ok := auth.GetUserFromContext(c)
-	if !ok {
-		return shared.ErrUserNotFound
-	}
-
 	// validate input
 	param := c.Param("id")
 	id, err := strconv.ParseInt(param, 10, 64)
@@ -112,8 +116,8 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 	}
 
 	// Access Control
-	if userFromCtx.ID != id {
-		return shared.ErrSessionUnauthorized
+	if err := auth.AuthorizeOwner(c, id, auth.PermUsersUpdateSelf, auth.PermUsersUpdateAny); err != nil {
+		return err
 	}
 
 	// service call
@@ -128,11 +132,6 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 }
 
 func (h *UserHandler) DeleteUser(c echo.Context) error {
-	userFromCtx, ok := auth.GetUserFromContext(c)
-	if !ok {
-		return shared.ErrUserNotFound
-	}
-
 	// validate input
 	param := c.Param("id")
 	id, err := strconv.ParseInt(param, 10, 64)
@@ -141,8 +140,8 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 	}
 
 	// Access Control
-	if userFromCtx.ID != id {
-		return shared.ErrSessionUnauthorized
+	if err := auth.AuthorizeOwner(c, id, auth.PermUsersDeleteSelf, auth.PermUsersDeleteAny); err != nil {
+		return err
 	}
 
 	// service call
@@ -154,3 +153,225 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 	// build response
 	return response.Success(c, http.StatusOK).Send()
 }
+
+func (h *UserHandler) RequestEmailChange(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+	cer := new(ChangeEmailRequest)
+	if err := c.Bind(cer); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(cer); err != nil {
+		return err
+	}
+
+	// Access Control
+	if err := auth.AuthorizeOwner(c, id, auth.PermUsersUpdateSelf, auth.PermUsersUpdateAny); err != nil {
+		return err
+	}
+
+	// service call
+	cer.ID = id // Ensure id from URL is used.
+	if err := h.service.requestEmailChange(c, cer); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusAccepted).WithMessage(succEmailChangeRequested).Send()
+}
+
+func (h *UserHandler) ConfirmEmailChange(c echo.Context) error {
+	// validate input
+	cecr := new(ConfirmEmailChangeRequest)
+	if err := c.Bind(cecr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(cecr); err != nil {
+		return err
+	}
+
+	// service call
+	if err := h.service.confirmEmailChange(c, cecr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succEmailChanged).Send()
+}
+
+func (h *UserHandler) ChangePassword(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+	cpr := new(ChangePasswordRequest)
+	if err := c.Bind(cpr); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(cpr); err != nil {
+		return err
+	}
+
+	// Access Control, only the user knows the current password
+	if err := auth.AuthorizeOwner(c, id, auth.PermUsersUpdateSelf, auth.PermUsersUpdateAny); err != nil {
+		return err
+	}
+	if current, _ := auth.GetUserFromContext(c); current.ID != id {
+		return shared.ErrForbidden
+	}
+
+	// service call
+	cpr.ID = id // Ensure id from URL is used.
+	if err := h.service.changePassword(c, cpr); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succPasswordChanged).Send()
+}
+
+// avatarFormOverhead is what the multipart form may add to the size of the image
+const avatarFormOverhead = 64 << 10
+
+func (h *UserHandler) UploadAvatar(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+
+	// Access Control, before the upload is read
+	if err := auth.AuthorizeOwner(c, id, auth.PermUsersUpdateSelf, auth.PermUsersUpdateAny); err != nil {
+		return err
+	}
+
+	// the image itself is checked by the service, the body limit stops huge uploads early
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, avatarMaxSize+avatarFormOverhead)
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return errAvatarTooLarge.WithArgs(avatarMaxSize>>20, object.MaxImagePixels/1_000_000)
+		}
+		return errAvatarMissing
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// service call
+	avatar, err := h.service.uploadAvatar(c, id, file)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succAvatarUpdated).WithData(avatar).Send()
+}
+
+func (h *UserHandler) ListUsers(c echo.Context) error {
+	// validate input
+	lq := query.New(listUsersQuery)
+	if err := lq.Bind(c); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(lq); err != nil {
+		return err
+	}
+
+	// service call
+	res, err := h.service.listUsers(c.Request().Context(), lq)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(res.Items).WithPagination(lq, res.Total, "").Send()
+}
+
+func (h *UserHandler) GetAnyUser(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+
+	// service call
+	resData, err := h.service.getAnyUser(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithData(resData).Send()
+}
+
+func (h *UserHandler) SuspendUser(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+
+	// service call
+	if err := h.service.suspendUser(c, id); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succUserSuspended).Send()
+}
+
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+
+	// service call
+	if err := h.service.restoreUser(c, id); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succUserRestored).Send()
+}
+
+func (h *UserHandler) ChangeUserRole(c echo.Context) error {
+	// validate input
+	param := c.Param("id")
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return errInvalidID.WithArgs(param)
+	}
+	cur := new(ChangeUserRoleRequest)
+	if err := c.Bind(cur); err != nil {
+		return shared.ErrInvalidRequestPayload
+	}
+	if err := c.Validate(cur); err != nil {
+		return err
+	}
+
+	// service call
+	cur.ID = id // Ensure id from URL is used.
+	if err := h.service.changeUserRole(c, cur); err != nil {
+		return err
+	}
+
+	// build response
+	return response.Success(c, http.StatusOK).WithMessage(succUserRoleChanged).Send()
+}
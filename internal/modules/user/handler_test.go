@@ -0,0 +1,89 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/modules/auth"
+	"go-echo-template/internal/shared"
+	"go-echo-template/internal/shared/response"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+var errAPIKeyRefused = errors.New("api key refused")
+
+// stubAuth lets every request in as the given user, RefuseAPIKey answers with errAPIKeyRefused
+type stubAuth struct {
+	auth.AuthService
+	user *auth.User
+}
+
+func (s *stubAuth) CheckAuth(bool, ...auth.CheckAuthOption) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(string(auth.UserContextKey), s.user)
+			return next(c)
+		}
+	}
+}
+
+func (s *stubAuth) RefuseAPIKey() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user, ok := auth.GetUserFromContext(c); ok && user.APIKeyID != 0 {
+				return errAPIKeyRefused
+			}
+			return next(c)
+		}
+	}
+}
+
+func (s *stubAuth) RefuseImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+}
+
+func (s *stubAuth) RequireRecentAuth(maxAge time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+}
+
+func (s *stubAuth) RequirePermission(...auth.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+}
+
+func TestChangePassword(t *testing.T) {
+	// a personal access token that may only read its user
+	readOnlyToken := &auth.User{ID: 42, Role: shared.RoleCustomer, APIKeyID: 7, Scopes: []auth.Permission{auth.PermUsersReadSelf}}
+	body := `{"currentPassword":"Passw0rd!","newPassword":"N3w-Passw0rd!"}`
+
+	t.Run("Route Refuses API Keys", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = response.NewValidator()
+		NewUserHandler(nil, nil, nil, &stubAuth{user: readOnlyToken}).RegisterRoutes(e.Group("/api"))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/42/password", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		e.Router().Find(http.MethodPost, "/api/v1/users/42/password", c)
+		require.ErrorIs(t, c.Handler()(c), errAPIKeyRefused)
+	})
+
+	t.Run("Handler Checks The Scopes Of API Keys", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = response.NewValidator()
+		h := NewUserHandler(nil, nil, nil, &stubAuth{user: readOnlyToken})
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id")
+		c.SetParamValues("42")
+		c.Set(string(auth.UserContextKey), readOnlyToken)
+		require.ErrorIs(t, h.ChangePassword(c), shared.ErrForbidden)
+	})
+}
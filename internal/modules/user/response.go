@@ -16,6 +16,55 @@ var (
 			i18n.TR_TR: "Kullanıcı başarıyla oluşturuldu",
 		},
 	}
+	succUserSuspended = &response.SuccessMessage{
+		Code: "SUCC:USER_SUSPENDED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "User suspended successfully",
+			i18n.TR_TR: "Kullanıcı başarıyla askıya alındı",
+		},
+	}
+	succUserRestored = &response.SuccessMessage{
+		Code: "SUCC:USER_RESTORED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "User restored successfully",
+			i18n.TR_TR: "Kullanıcı başarıyla geri yüklendi",
+		},
+	}
+	succPasswordChanged = &response.SuccessMessage{
+		Code: "SUCC:USER_PASSWORD_CHANGED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Password changed successfully, other sessions were logged out",
+			i18n.TR_TR: "Şifre başarıyla değiştirildi, diğer oturumlar kapatıldı",
+		},
+	}
+	succEmailChangeRequested = &response.SuccessMessage{
+		Code: "SUCC:USER_EMAIL_CHANGE_REQUESTED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "A confirmation link has been sent to the new email address",
+			i18n.TR_TR: "Yeni e-posta adresine bir onay bağlantısı gönderildi",
+		},
+	}
+	succEmailChanged = &response.SuccessMessage{
+		Code: "SUCC:USER_EMAIL_CHANGED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Email address changed successfully",
+			i18n.TR_TR: "E-posta adresi başarıyla değiştirildi",
+		},
+	}
+	succAvatarUpdated = &response.SuccessMessage{
+		Code: "SUCC:USER_AVATAR_UPDATED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Avatar updated successfully",
+			i18n.TR_TR: "Profil fotoğrafı başarıyla güncellendi",
+		},
+	}
+	succUserRoleChanged = &response.SuccessMessage{
+		Code: "SUCC:USER_ROLE_CHANGED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "User role changed successfully",
+			i18n.TR_TR: "Kullanıcı rolü başarıyla değiştirildi",
+		},
+	}
 )
 
 // Errors
@@ -44,4 +93,84 @@ var (
 			i18n.TR_TR: "Bu e-posta adresine sahip bir kullanıcı zaten kayıtlı",
 		},
 	}
+	errUserAlreadySuspended = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:USER_ALREADY_SUSPENDED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "User is already suspended",
+			i18n.TR_TR: "Kullanıcı zaten askıya alınmış",
+		},
+	}
+	errUserNotRestorable = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:USER_NOT_RESTORABLE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "User is neither suspended nor deleted",
+			i18n.TR_TR: "Kullanıcı askıya alınmamış veya silinmemiş",
+		},
+	}
+	errManageSelf = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:USER_MANAGE_SELF",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "You can't suspend yourself or change your own role",
+			i18n.TR_TR: "Kendinizi askıya alamaz veya kendi rolünüzü değiştiremezsiniz",
+		},
+	}
+	errCurrentPasswordInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:USER_CURRENT_PASSWORD_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Current password is incorrect",
+			i18n.TR_TR: "Mevcut şifre hatalı",
+		},
+	}
+	errEmailUnchanged = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:USER_EMAIL_UNCHANGED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The new email address is the same as the current one",
+			i18n.TR_TR: "Yeni e-posta adresi mevcut adresle aynı",
+		},
+	}
+	errEmailChangeOutdated = &response.CustomErr{
+		Status: http.StatusConflict,
+		Code:   "ERR:USER_EMAIL_CHANGE_OUTDATED",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The email address was changed since this link was sent",
+			i18n.TR_TR: "Bu bağlantı gönderildikten sonra e-posta adresi değiştirildi",
+		},
+	}
+	errAvatarMissing = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:USER_AVATAR_MISSING",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "Upload the image as the avatar field of a multipart form",
+			i18n.TR_TR: "Görseli multipart formun avatar alanında yükleyin",
+		},
+	}
+	errAvatarTooLarge = &response.CustomErr{
+		Status: http.StatusRequestEntityTooLarge,
+		Code:   "ERR:USER_AVATAR_TOO_LARGE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The avatar must be at most %v MB and %v megapixels",
+			i18n.TR_TR: "Profil fotoğrafı en fazla %v MB ve %v megapiksel olmalıdır",
+		},
+	}
+	errAvatarType = &response.CustomErr{
+		Status: http.StatusUnsupportedMediaType,
+		Code:   "ERR:USER_AVATAR_TYPE",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The avatar must be a JPEG, PNG, GIF or WebP image",
+			i18n.TR_TR: "Profil fotoğrafı JPEG, PNG, GIF veya WebP formatında olmalıdır",
+		},
+	}
+	errAvatarInvalid = &response.CustomErr{
+		Status: http.StatusBadRequest,
+		Code:   "ERR:USER_AVATAR_INVALID",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "The avatar image is damaged and can't be read",
+			i18n.TR_TR: "Profil fotoğrafı bozuk ve okunamıyor",
+		},
+	}
 )
@@ -1,14 +1,27 @@
 package user
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"strconv"
+	"strings"
 
+	"go-echo-template/internal/audit"
+	"go-echo-template/internal/config"
 	"go-echo-template/internal/modules/auth"
+	"go-echo-template/internal/object"
 	"go-echo-template/internal/shared"
 	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/shared/query"
 	"go-echo-template/internal/shared/utils"
 	"go-echo-template/internal/storage"
+	storageUser "go-echo-template/internal/storage/user"
 	"go-echo-template/internal/storage/user/sqlc"
 
 	"github.com/labstack/echo/v4"
@@ -16,19 +29,41 @@ import (
 
 type userService interface {
 	getUser(ctx context.Context, id int64) (*GetUserResponse, error)
-	createUser(ctx context.Context, cur *CreateUserRequest) (int64, error)
+	createUser(c echo.Context, cur *CreateUserRequest) (int64, error)
 	updateUser(c echo.Context, uur *UpdateUserRequest) error
 	deleteUser(c echo.Context, id int64) error
+	requestEmailChange(c echo.Context, req *ChangeEmailRequest) error
+	confirmEmailChange(c echo.Context, req *ConfirmEmailChangeRequest) error
+	changePassword(c echo.Context, req *ChangePasswordRequest) error
+	uploadAvatar(c echo.Context, id int64, file io.Reader) (*AvatarResponse, error)
+
+	// admin
+	listUsers(ctx context.Context, q *query.Query) (*query.Result[AdminUserResponse], error)
+	getAnyUser(ctx context.Context, id int64) (*AdminUserResponse, error)
+	suspendUser(c echo.Context, id int64) error
+	restoreUser(c echo.Context, id int64) error
+	changeUserRole(c echo.Context, req *ChangeUserRoleRequest) error
 }
 
+// Avatars are re-encoded, the stored image and its thumbnails are much smaller than uploads
+const (
+	avatarMaxSize = 5 << 20
+	avatarSize    = 512
+)
+
+// avatarThumbnailSizes are the square thumbnails stored next to every avatar
+var avatarThumbnailSizes = []int{256, 64}
+
 type service struct {
 	logger  log.CustomLogger
 	storage *storage.Storage
 	auth    auth.AuthService
+	authCfg *config.AuthConfig
+	objects object.BlobStore
 }
 
-func NewUserService(logger log.CustomLogger, storage *storage.Storage, authService auth.AuthService) userService {
-	return &service{storage: storage, logger: logger, auth: authService}
+func NewUserService(logger log.CustomLogger, storage *storage.Storage, authService auth.AuthService, authCfg *config.AuthConfig, objects object.BlobStore) userService {
+	return &service{storage: storage, logger: logger, auth: authService, authCfg: authCfg, objects: objects}
 }
 
 func (s *service) getUser(ctx context.Context, id int64) (*GetUserResponse, error) {
@@ -39,24 +74,13 @@ func (s *service) getUser(ctx context.Context, id int64) (*GetUserResponse, erro
 	}
 
 	// build response
-	getUserResp := &GetUserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Phone:     nil,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt.Format(shared.DefaultDateFormat),
-		UpdatedAt: user.UpdatedAt.Format(shared.DefaultDateFormat),
-	}
-	if user.Phone.Valid {
-		getUserResp.Phone = &user.Phone.String
-	}
-
-	return getUserResp, nil
+	return s.newGetUserResponse(ctx, user), nil
 }
 
-func (s *service) createUser(ctx context.Context, cur *CreateUserRequest) (int64, error) {
-	password, err := utils.HashPassword(cur.Password)
+func (s *service) createUser(c echo.Context, cur *CreateUserRequest) (int64, error) {
+	ctx := c.Request().Context()
+
+	password, err := utils.HashPassword(cur.Password, s.authCfg.PasswordHash)
 	if err != nil {
 		return 0, err
 	}
@@ -74,7 +98,17 @@ func (s *service) createUser(ctx context.Context, cur *CreateUserRequest) (int64
 	}
 
 	// repo call
-	return s.storage.User.CreateUser(ctx, params)
+	userID, err := s.storage.User.CreateUser(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	// the account is usable right away, a failed mail can be resent later on
+	if err := s.auth.SendEmailVerification(c, userID, params.Name, params.Email); err != nil {
+		s.logger.ErrorWithContext(ctx, "send email verification after signup is failed", s.logger.Err(err))
+	}
+
+	return userID, nil
 }
 
 func (s *service) updateUser(c echo.Context, uur *UpdateUserRequest) error {
@@ -84,7 +118,6 @@ func (s *service) updateUser(c echo.Context, uur *UpdateUserRequest) error {
 	params := sqlc.UpdateUserParams{
 		ID:    uur.ID,
 		Name:  uur.Name,
-		Email: uur.Email,
 		Phone: sql.NullString{},
 	}
 	if uur.Phone != nil {
@@ -95,7 +128,12 @@ func (s *service) updateUser(c echo.Context, uur *UpdateUserRequest) error {
 	// transaction example in service layer using storage
 	var newUser *sqlc.User
 	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
-		err := storageTx.User.UpdateUser(ctx, params)
+		oldUser, err := storageTx.User.GetUserById(ctx, params.ID)
+		if err != nil {
+			return err
+		}
+
+		err = storageTx.User.UpdateUser(ctx, params)
 		if err != nil {
 			return err
 		}
@@ -105,13 +143,33 @@ func (s *service) updateUser(c echo.Context, uur *UpdateUserRequest) error {
 			return err
 		}
 
+		// the audit event commits or rolls back together with the update
+		if err := audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    actorID(c),
+			Action:     audit.ActionUserUpdate,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Diff:       audit.Diff(auditFields(oldUser), auditFields(user)),
+		}); err != nil {
+			return err
+		}
+
 		newUser = user
 		return nil
 	}); err != nil {
 		return err
 	}
 
-	// refresh token data
+	s.refreshSessionUser(c, newUser)
+	return nil
+}
+
+// refreshSessionUser updates the token data, only when users edit themselves and not
+// when an admin edits them
+func (s *service) refreshSessionUser(c echo.Context, newUser *sqlc.User) {
+	if current, ok := auth.GetUserFromContext(c); !ok || current.ID != newUser.ID {
+		return
+	}
 	sessionUser := &auth.User{
 		ID:        newUser.ID,
 		Name:      newUser.Name,
@@ -120,20 +178,486 @@ func (s *service) updateUser(c echo.Context, uur *UpdateUserRequest) error {
 		Role:      newUser.Role,
 		CreatedAt: newUser.CreatedAt,
 		UpdatedAt: newUser.UpdatedAt,
+
+		EmailVerified: newUser.EmailVerifiedAt.Valid,
 	}
 
 	if err := s.auth.Refresh(c, sessionUser); err != nil {
-		s.logger.Error("delete user session after removal is failed", s.logger.Err(err))
+		s.logger.ErrorWithContext(c.Request().Context(), "refresh user session after update is failed", s.logger.Err(err))
+	}
+}
+
+// requestEmailChange starts an email change, it applies once the new address is confirmed
+func (s *service) requestEmailChange(c echo.Context, req *ChangeEmailRequest) error {
+	ctx := c.Request().Context()
+
+	// repo call
+	user, err := s.storage.User.GetUserById(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(user.Email, req.Email) {
+		return errEmailUnchanged
+	}
+
+	return s.auth.RequestEmailChange(c, user.Name, &auth.EmailChange{
+		UserID:   user.ID,
+		OldEmail: user.Email,
+		NewEmail: req.Email,
+	})
+}
+
+// confirmEmailChange applies the email change a confirmation link was sent for
+func (s *service) confirmEmailChange(c echo.Context, req *ConfirmEmailChangeRequest) error {
+	ctx := c.Request().Context()
+
+	change, err := s.auth.ConsumeEmailChange(c, req.Token)
+	if err != nil {
+		return err
 	}
 
+	// repo call
+	var newUser *sqlc.User
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		affected, err := storageTx.User.ChangeUserEmail(ctx, sqlc.ChangeUserEmailParams{
+			ID:       change.UserID,
+			OldEmail: change.OldEmail,
+			NewEmail: change.NewEmail,
+		})
+		if errors.Is(err, storageUser.ErrEmailTaken) {
+			return errUserEmailAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			// the user is gone or the email changed since the link was sent
+			return errEmailChangeOutdated
+		}
+
+		user, err := storageTx.User.GetUserById(ctx, change.UserID)
+		if err != nil {
+			return err
+		}
+
+		if err := audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    change.UserID,
+			Action:     audit.ActionUserEmailChange,
+			TargetType: audit.TargetUser,
+			TargetID:   change.UserID,
+			Diff:       audit.Diff(map[string]any{"email": change.OldEmail}, map[string]any{"email": change.NewEmail}),
+		}); err != nil {
+			return err
+		}
+
+		newUser = user
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.refreshSessionUser(c, newUser)
 	return nil
 }
 
 func (s *service) deleteUser(c echo.Context, id int64) error {
-	if err := s.auth.Logout(c); err != nil {
-		s.logger.Error("delete user session after removal is failed", s.logger.Err(err))
+	ctx := c.Request().Context()
+
+	// repo call
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		if err := storageTx.User.DeleteUser(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    actorID(c),
+			Action:     audit.ActionUserDelete,
+			TargetType: audit.TargetUser,
+			TargetID:   id,
+		})
+	}); err != nil {
+		return err
+	}
+
+	// sessions on other devices must not outlive the account
+	if err := s.auth.LogoutAll(c.Request().Context(), id); err != nil {
+		s.logger.Error("delete user sessions after removal is failed", s.logger.Err(err))
+	}
+
+	// an admin deleting someone else stays logged in
+	if current, ok := auth.GetUserFromContext(c); ok && current.ID == id {
+		if err := s.auth.Logout(c); err != nil {
+			s.logger.Error("delete user session after removal is failed", s.logger.Err(err))
+		}
+	}
+	return nil
+}
+
+// changePassword replaces the password after checking the current one, every other
+// session of the user is ended while the one of the request is kept
+func (s *service) changePassword(c echo.Context, req *ChangePasswordRequest) error {
+	ctx := c.Request().Context()
+
+	user, err := s.storage.User.GetUserById(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		return errCurrentPasswordInvalid
+	}
+	if err := s.auth.UpdatePassword(c, req.ID, req.NewPassword, audit.ActionUserPasswordChange); err != nil {
+		return err
+	}
+
+	if err := s.auth.LogoutOthers(c, req.ID); err != nil {
+		s.logger.ErrorWithContext(ctx, "delete other user sessions after password change is failed", s.logger.Err(err))
+	}
+	return nil
+}
+
+// uploadAvatar stores the avatar and its thumbnails under a new key and replaces the
+// previous avatar, whose files are deleted afterwards
+func (s *service) uploadAvatar(c echo.Context, id int64, file io.Reader) (*AvatarResponse, error) {
+	ctx := c.Request().Context()
+
+	img, err := object.ReadImage(file, avatarMaxSize)
+	switch {
+	case errors.Is(err, object.ErrImageTooLarge):
+		return nil, errAvatarTooLarge.WithArgs(avatarMaxSize>>20, object.MaxImagePixels/1_000_000)
+	case errors.Is(err, object.ErrImageType):
+		return nil, errAvatarType
+	case errors.Is(err, object.ErrImageInvalid):
+		return nil, errAvatarInvalid
+	case err != nil:
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(8)
+	if err != nil {
+		return nil, err
+	}
+	contentType := object.ContentType(img)
+	key := fmt.Sprintf("avatars/%d/%s%s", id, token, object.Extension(contentType))
+
+	variants := map[string]image.Image{key: object.Fit(img, avatarSize)}
+	for _, size := range avatarThumbnailSizes {
+		variants[avatarThumbnailKey(key, size)] = object.Thumbnail(img, size)
+	}
+	for variantKey, variant := range variants {
+		data, err := object.Encode(variant, contentType)
+		if err == nil {
+			err = s.objects.Put(ctx, variantKey, bytes.NewReader(data), int64(len(data)), contentType)
+		}
+		if err != nil {
+			s.deleteAvatar(ctx, key)
+			return nil, err
+		}
+	}
+
+	// repo call
+	var oldKey sql.NullString
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		user, err := storageTx.User.GetUserById(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		affected, err := storageTx.User.UpdateUserAvatar(ctx, sqlc.UpdateUserAvatarParams{
+			AvatarKey: sql.NullString{String: key, Valid: true},
+			ID:        id,
+		})
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return shared.ErrUserNotFound
+		}
+		oldKey = user.AvatarKey
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    actorID(c),
+			Action:     audit.ActionUserAvatarChange,
+			TargetType: audit.TargetUser,
+			TargetID:   id,
+		})
+	}); err != nil {
+		s.deleteAvatar(ctx, key)
+		return nil, err
+	}
+
+	// nothing references the previous files anymore
+	if oldKey.Valid {
+		s.deleteAvatar(ctx, oldKey.String)
 	}
 
+	// build response
+	return s.newAvatarResponse(ctx, sql.NullString{String: key, Valid: true}), nil
+}
+
+// deleteAvatar removes an avatar and its thumbnails, a failure only leaves unused files behind
+func (s *service) deleteAvatar(ctx context.Context, key string) {
+	// the cleanup runs even when the request was cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	keys := []string{key}
+	for _, size := range avatarThumbnailSizes {
+		keys = append(keys, avatarThumbnailKey(key, size))
+	}
+	for _, k := range keys {
+		if err := s.objects.Delete(ctx, k); err != nil {
+			s.logger.WarnWithContext(ctx, "delete avatar file is failed", s.logger.Err(err), s.logger.String("key", k))
+		}
+	}
+}
+
+// avatarThumbnailKey returns the key of a thumbnail, "avatars/1/ab.jpg" has "avatars/1/ab_64.jpg"
+func avatarThumbnailKey(key string, size int) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + strconv.Itoa(size) + ext
+}
+
+// actorID returns the ID of the session user, 0 when there is none
+func actorID(c echo.Context) int64 {
+	if current, ok := auth.GetUserFromContext(c); ok {
+		return current.ID
+	}
+	return 0
+}
+
+// auditFields returns the fields of a user whose changes are audited
+func auditFields(user *sqlc.User) map[string]any {
+	return map[string]any{
+		"name":  user.Name,
+		"email": user.Email,
+		"phone": user.Phone.String,
+		"role":  user.Role,
+	}
+}
+
+// --- ADMIN ---
+
+func (s *service) listUsers(ctx context.Context, q *query.Query) (*query.Result[AdminUserResponse], error) {
 	// repo call
-	return s.storage.User.DeleteUser(c.Request().Context(), id)
+	countParams := sqlc.CountUsersParams{
+		Search:    q.NullString("search", query.OpContains),
+		Role:      q.NullString("role", query.OpEq),
+		IsDeleted: q.NullBool("deleted", query.OpEq),
+		Suspended: q.NullBool("suspended", query.OpEq),
+	}
+	countParams.Search.String = escapeLike(countParams.Search.String)
+	users, err := s.storage.User.ListUsers(ctx, sqlc.ListUsersParams{
+		Search:     countParams.Search,
+		Role:       countParams.Role,
+		IsDeleted:  countParams.IsDeleted,
+		Suspended:  countParams.Suspended,
+		SortBy:     q.SortBy(),
+		SortDesc:   q.SortDesc(),
+		PageLimit:  int32(q.Limit()),
+		PageOffset: int32(q.Offset()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.storage.User.CountUsers(ctx, countParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// build response
+	res := &query.Result[AdminUserResponse]{
+		Items: make([]AdminUserResponse, len(users)),
+		Total: total,
+	}
+	for i := range users {
+		res.Items[i] = *s.newAdminUserResponse(ctx, &users[i])
+	}
+	return res, nil
+}
+
+func (s *service) getAnyUser(ctx context.Context, id int64) (*AdminUserResponse, error) {
+	// repo call
+	user, err := s.storage.User.GetAnyUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// build response
+	return s.newAdminUserResponse(ctx, user), nil
+}
+
+// suspendUser blocks the logins of a user and ends their sessions, the account is kept as is
+func (s *service) suspendUser(c echo.Context, id int64) error {
+	ctx := c.Request().Context()
+	if actorID(c) == id {
+		return errManageSelf
+	}
+
+	// repo call
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		affected, err := storageTx.User.SuspendUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			// tell a missing user apart from a suspended one
+			user, err := storageTx.User.GetAnyUserById(ctx, id)
+			if err != nil {
+				return err
+			}
+			if user.IsDeleted {
+				return shared.ErrUserNotFound
+			}
+			return errUserAlreadySuspended
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    actorID(c),
+			Action:     audit.ActionUserSuspend,
+			TargetType: audit.TargetUser,
+			TargetID:   id,
+		})
+	}); err != nil {
+		return err
+	}
+
+	if err := s.auth.LogoutAll(ctx, id); err != nil {
+		s.logger.ErrorWithContext(ctx, "delete user sessions after suspension is failed", s.logger.Err(err))
+	}
+	return nil
+}
+
+// restoreUser lifts a suspension or undoes a deletion
+func (s *service) restoreUser(c echo.Context, id int64) error {
+	ctx := c.Request().Context()
+
+	// repo call
+	return s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		affected, err := storageTx.User.RestoreUser(ctx, id)
+		if errors.Is(err, storageUser.ErrEmailTaken) {
+			return errUserEmailAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			// tell a missing user apart from an active one
+			if _, err := storageTx.User.GetAnyUserById(ctx, id); err != nil {
+				return err
+			}
+			return errUserNotRestorable
+		}
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    actorID(c),
+			Action:     audit.ActionUserRestore,
+			TargetType: audit.TargetUser,
+			TargetID:   id,
+		})
+	})
+}
+
+// changeUserRole sets the role of a user, their sessions are ended so that the new
+// permissions apply from the next login
+func (s *service) changeUserRole(c echo.Context, req *ChangeUserRoleRequest) error {
+	ctx := c.Request().Context()
+	if actorID(c) == req.ID {
+		return errManageSelf
+	}
+
+	// repo call
+	changed := false
+	if err := s.storage.WithTx(ctx, func(storageTx *storage.Storage) error {
+		user, err := storageTx.User.GetUserById(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		if user.Role == req.Role {
+			return nil
+		}
+
+		affected, err := storageTx.User.UpdateUserRole(ctx, sqlc.UpdateUserRoleParams{Role: req.Role, ID: req.ID})
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return shared.ErrUserNotFound
+		}
+		changed = true
+
+		return audit.Write(c, storageTx.Audit, &audit.Event{
+			ActorID:    actorID(c),
+			Action:     audit.ActionUserRoleChange,
+			TargetType: audit.TargetUser,
+			TargetID:   req.ID,
+			Diff:       audit.Diff(map[string]any{"role": user.Role}, map[string]any{"role": req.Role}),
+		})
+	}); err != nil {
+		return err
+	}
+
+	if changed {
+		if err := s.auth.LogoutAll(ctx, req.ID); err != nil {
+			s.logger.ErrorWithContext(ctx, "delete user sessions after role change is failed", s.logger.Err(err))
+		}
+	}
+	return nil
+}
+
+func (s *service) newGetUserResponse(ctx context.Context, user *sqlc.User) *GetUserResponse {
+	getUserResp := &GetUserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Phone:     nil,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt.Format(shared.DefaultDateFormat),
+		UpdatedAt: user.UpdatedAt.Format(shared.DefaultDateFormat),
+	}
+	if user.Phone.Valid {
+		getUserResp.Phone = &user.Phone.String
+	}
+	getUserResp.Avatar = s.newAvatarResponse(ctx, user.AvatarKey)
+	return getUserResp
+}
+
+// newAvatarResponse returns nil without an avatar, an avatar whose URLs can't be built is
+// left out instead of failing the response
+func (s *service) newAvatarResponse(ctx context.Context, key sql.NullString) *AvatarResponse {
+	if !key.Valid {
+		return nil
+	}
+
+	url, err := s.objects.URL(ctx, key.String)
+	if err != nil {
+		s.logger.WarnWithContext(ctx, "build avatar url is failed", s.logger.Err(err), s.logger.String("key", key.String))
+		return nil
+	}
+	avatar := &AvatarResponse{URL: url, Thumbnails: make(map[string]string, len(avatarThumbnailSizes))}
+	for _, size := range avatarThumbnailSizes {
+		thumbnailURL, err := s.objects.URL(ctx, avatarThumbnailKey(key.String, size))
+		if err != nil {
+			s.logger.WarnWithContext(ctx, "build avatar url is failed", s.logger.Err(err), s.logger.String("key", key.String))
+			return nil
+		}
+		avatar.Thumbnails[strconv.Itoa(size)] = thumbnailURL
+	}
+	return avatar
+}
+
+func (s *service) newAdminUserResponse(ctx context.Context, user *sqlc.User) *AdminUserResponse {
+	resp := &AdminUserResponse{
+		GetUserResponse: *s.newGetUserResponse(ctx, user),
+		EmailVerified:   user.EmailVerifiedAt.Valid,
+		Deleted:         user.IsDeleted,
+	}
+	if user.SuspendedAt.Valid {
+		suspendedAt := user.SuspendedAt.Time.Format(shared.DefaultDateFormat)
+		resp.SuspendedAt = &suspendedAt
+	}
+	return resp
+}
+
+// escapeLike makes LIKE treat the wildcards in a search term literally
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
 }
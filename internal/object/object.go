@@ -0,0 +1,43 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go-echo-template/internal/config"
+)
+
+// ErrNotFound is returned for keys that don't exist
+var ErrNotFound = errors.New("object store: not found")
+
+// BlobStore keeps uploaded files under slash separated keys like "avatars/42/a1b2.jpg".
+// Objects are written once, a changed file gets a new key so URLs can be cached for good
+type BlobStore interface {
+	// Put stores size bytes of r under key, an existing object is replaced
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns the content of an object, the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes an object, deleting a missing one is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients download an object from, a signed URL for private objects
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// NewBlobStore returns the store selected by OBJECT_STORE
+func NewBlobStore(ctx context.Context, cfg *config.ObjectConfig) BlobStore {
+	switch cfg.Store {
+	case "s3":
+		store, err := NewS3Store(ctx, cfg.S3, cfg.URLExpiry)
+		if err != nil {
+			panic(err)
+		}
+		return store
+	default:
+		store, err := NewLocalStore(cfg.Local.Dir, cfg.Local.URL)
+		if err != nil {
+			panic(err)
+		}
+		return store
+	}
+}
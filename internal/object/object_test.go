@@ -0,0 +1,153 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir(), "http://localhost:8080/api/media")
+	require.NoError(t, err)
+
+	t.Run("Put, Get And Delete", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "avatars/1/a.jpg", strings.NewReader("data"), 4, "image/jpeg"))
+
+		r, err := store.Get(ctx, "avatars/1/a.jpg")
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, r.Close())
+		require.NoError(t, err)
+		require.Equal(t, "data", string(content))
+
+		url, err := store.URL(ctx, "avatars/1/a.jpg")
+		require.NoError(t, err)
+		require.Equal(t, "http://localhost:8080/api/media/avatars/1/a.jpg", url)
+
+		require.NoError(t, store.Delete(ctx, "avatars/1/a.jpg"))
+		_, err = store.Get(ctx, "avatars/1/a.jpg")
+		require.ErrorIs(t, err, ErrNotFound)
+		require.NoError(t, store.Delete(ctx, "avatars/1/a.jpg"), "deleting twice is fine")
+	})
+
+	t.Run("Short Writes Are Not Stored", func(t *testing.T) {
+		require.Error(t, store.Put(ctx, "short", strings.NewReader("da"), 4, "text/plain"))
+		_, err := store.Get(ctx, "short")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Keys Stay In The Directory", func(t *testing.T) {
+		for _, key := range []string{"../escape", "/etc/passwd", "a/../../b", ""} {
+			require.Error(t, store.Put(ctx, key, strings.NewReader(""), 0, "text/plain"), key)
+			_, err := store.URL(ctx, key)
+			require.Error(t, err, key)
+		}
+	})
+}
+
+// testJPEG encodes a w x h image, red on the left half and blue on the right one, with an
+// EXIF orientation when it isn't 0
+func testJPEG(t *testing.T, w, h, orientation int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			if x < w/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	data := buf.Bytes()
+	if orientation == 0 {
+		return data
+	}
+
+	// a big endian TIFF with one IFD entry, the orientation as a SHORT
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	exif = append(exif, 0, byte(orientation), 0, 0, 0, 0, 0, 0)
+	app1 := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	return append(append([]byte{0xFF, 0xD8}, app1...), data[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > 0xC000 && b < 0x4000
+}
+
+func TestImage(t *testing.T) {
+	t.Run("Types Are Sniffed From The Content", func(t *testing.T) {
+		_, err := ReadImage(strings.NewReader("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), 1<<20)
+		require.ErrorIs(t, err, ErrImageType)
+
+		_, err = ReadImage(bytes.NewReader(testJPEG(t, 16, 8, 0)[:100]), 1<<20)
+		require.ErrorIs(t, err, ErrImageInvalid, "a truncated image is rejected")
+	})
+
+	t.Run("Size Limits", func(t *testing.T) {
+		data := testJPEG(t, 16, 8, 0)
+		_, err := ReadImage(bytes.NewReader(data), int64(len(data)-1))
+		require.ErrorIs(t, err, ErrImageTooLarge)
+
+		_, err = ReadImage(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+
+		// a tiny PNG claiming a huge canvas is rejected before it is decoded
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 6000, 6000))))
+		_, err = ReadImage(&buf, 1<<20)
+		require.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("EXIF Orientation Is Applied And Stripped", func(t *testing.T) {
+		data := testJPEG(t, 16, 8, 6)
+		require.Equal(t, 6, jpegOrientation(data))
+
+		img, err := ReadImage(bytes.NewReader(data), 1<<20)
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 8, 16), img.Bounds())
+		// the left half is on top after the clockwise turn
+		require.True(t, isRed(img.At(4, 2)))
+		require.False(t, isRed(img.At(4, 13)))
+
+		require.Equal(t, "image/jpeg", ContentType(img))
+		encoded, err := Encode(img, ContentType(img))
+		require.NoError(t, err)
+		require.NotContains(t, string(encoded), "Exif")
+	})
+
+	t.Run("Fit And Thumbnail", func(t *testing.T) {
+		img, err := ReadImage(bytes.NewReader(testJPEG(t, 400, 200, 0)), 1<<20)
+		require.NoError(t, err)
+
+		require.Equal(t, image.Rect(0, 0, 100, 50), Fit(img, 100).Bounds())
+		require.Equal(t, img, Fit(img, 1000), "smaller images are not scaled up")
+		require.Equal(t, image.Rect(0, 0, 64, 64), Thumbnail(img, 64).Bounds())
+	})
+
+	t.Run("Transparency Is Kept As PNG", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		contentType := ContentType(img)
+		require.Equal(t, "image/png", contentType)
+		require.Equal(t, ".png", Extension(contentType))
+
+		encoded, err := Encode(Thumbnail(img, 2), contentType)
+		require.NoError(t, err)
+		decoded, err := png.Decode(bytes.NewReader(encoded))
+		require.NoError(t, err)
+		require.False(t, decoded.(interface{ Opaque() bool }).Opaque())
+	})
+}
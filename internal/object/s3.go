@@ -0,0 +1,94 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"go-echo-template/internal/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Store keeps objects in a bucket of AWS S3 or an S3 compatible server like MinIO
+type s3Store struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+	urlExpiry time.Duration
+}
+
+// NewS3Store connects to the bucket and checks that it exists. The endpoint is a URL,
+// its scheme decides whether TLS is used
+func NewS3Store(ctx context.Context, cfg *config.S3Config, urlExpiry time.Duration) (BlobStore, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", cfg.Endpoint)
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: endpoint.Scheme == "https",
+		// a known region saves the bucket location lookup, signing works offline then
+		Region: cfg.Region,
+		// virtual host style for AWS, path style for MinIO and other servers
+		BucketLookup: minio.BucketLookupAuto,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("check S3 bucket %q: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("S3 bucket %q does not exist", cfg.Bucket)
+	}
+
+	return &s3Store{client: client, bucket: cfg.Bucket, publicURL: cfg.PublicURL, urlExpiry: urlExpiry}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		// keys are never reused for other content
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the request is only sent on first use, stat it to report a missing object here
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Store) URL(ctx context.Context, key string) (string, error) {
+	if s.publicURL != "" {
+		return s.publicURL + "/" + key, nil
+	}
+
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.urlExpiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -34,4 +34,13 @@ var (
 			i18n.TR_TR: "Yetkisiz erişim",
 		},
 	}
+
+	ErrForbidden = &response.CustomErr{
+		Status: http.StatusForbidden,
+		Code:   "ERR:SESSION_FORBIDDEN",
+		Messages: map[i18n.Locale]string{
+			i18n.EN_US: "You don't have permission to perform this action",
+			i18n.TR_TR: "Bu işlemi gerçekleştirme yetkiniz yok",
+		},
+	}
 )
@@ -75,6 +75,62 @@ var Translations = map[string]Translation{
 			TR_TR: "Şifre",
 		},
 	},
+	"FIELD:CURRENTPASSWORD": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Current password",
+			TR_TR: "Mevcut şifre",
+		},
+	},
+	"FIELD:NEWPASSWORD": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "New password",
+			TR_TR: "Yeni şifre",
+		},
+	},
+	"FIELD:TOKEN": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Token",
+			TR_TR: "Anahtar",
+		},
+	},
+	"FIELD:CODE": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Code",
+			TR_TR: "Kod",
+		},
+	},
+	"FIELD:RECOVERYCODE": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Recovery code",
+			TR_TR: "Kurtarma kodu",
+		},
+	},
+	"FIELD:PROVIDER": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Provider",
+			TR_TR: "Sağlayıcı",
+		},
+	},
+	"FIELD:STATE": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "State",
+			TR_TR: "Durum",
+		},
+	},
+	"FIELD:CREDENTIAL": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Credential",
+			TR_TR: "Kimlik bilgisi",
+		},
+	},
 	"FIELD:AGE": {
 		IsInternal: true,
 		Messages: map[Locale]string{
@@ -89,6 +145,55 @@ var Translations = map[string]Translation{
 			TR_TR: "Rol",
 		},
 	},
+	"FIELD:PAGE": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Page",
+			TR_TR: "Sayfa",
+		},
+	},
+	"FIELD:LIMIT": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Limit",
+			TR_TR: "Limit",
+		},
+	},
+	"FIELD:CURSOR": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Cursor",
+			TR_TR: "İmleç",
+		},
+	},
+	"FIELD:SORT": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Sort",
+			TR_TR: "Sıralama",
+		},
+	},
+	"FIELD:SEARCH": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Search",
+			TR_TR: "Arama",
+		},
+	},
+	"FIELD:DELETED": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Deleted",
+			TR_TR: "Silinmiş",
+		},
+	},
+	"FIELD:SUSPENDED": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "Suspended",
+			TR_TR: "Askıya alınmış",
+		},
+	},
 
 	// ========== VALIDATION MESSAGES ==========
 	"VAL:VALIDATION_ERR": {
@@ -227,6 +332,13 @@ var Translations = map[string]Translation{
 			TR_TR: "%v yalnızca harf ve rakam içermelidir",
 		},
 	},
+	"VAL:NUMERIC": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "%v must contain digits only",
+			TR_TR: "%v yalnızca rakam içermelidir",
+		},
+	},
 	"VAL:CONTAINS": {
 		IsInternal: true,
 		Messages: map[Locale]string{
@@ -241,6 +353,41 @@ var Translations = map[string]Translation{
 			TR_TR: "%v şu değerlerden biri olmalıdır: [%v]",
 		},
 	},
+	"VAL:BOOLEAN": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "%v must be true or false",
+			TR_TR: "%v true veya false olmalıdır",
+		},
+	},
+	"VAL:DATETIME": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "%v must be an RFC 3339 date",
+			TR_TR: "%v RFC 3339 formatında bir tarih olmalıdır",
+		},
+	},
+	"VAL:CURSOR": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "%v is invalid or belongs to another query",
+			TR_TR: "%v geçersiz veya başka bir sorguya ait",
+		},
+	},
+	"VAL:EXCLUDED": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "%v is not supported here",
+			TR_TR: "%v burada desteklenmiyor",
+		},
+	},
+	"VAL:EXCLUDED_WITH": {
+		IsInternal: true,
+		Messages: map[Locale]string{
+			EN_US: "%v can not be used together with %v",
+			TR_TR: "%v, %v ile birlikte kullanılamaz",
+		},
+	},
 
 	// ========== GENERIC ERROR MESSAGES ==========
 	"ERR:HTTP_500": {
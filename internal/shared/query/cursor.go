@@ -0,0 +1,46 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// cursor is the position after the last item of a page. It is opaque to clients but not
+// signed, a forged one only moves the position within the same filters.
+type cursor struct {
+	// Sort ties the cursor to the sort it was created for
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// NextCursor encodes the position after an item, given by the value of the sort field
+// and its id as the tiebreaker. The value can be empty when the list is sorted by id.
+func (q *Query) NextCursor(value string, id int64) string {
+	raw, _ := json.Marshal(cursor{Sort: q.effectiveSort(), Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// After returns the position the request continues from, ok is false without a cursor
+func (q *Query) After() (value string, id int64, ok bool) {
+	cur, err := q.decodeCursor()
+	if err != nil {
+		return "", 0, false
+	}
+	return cur.Value, cur.ID, true
+}
+
+func (q *Query) decodeCursor() (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(q.cursor)
+	if err != nil {
+		return nil, err
+	}
+	cur := new(cursor)
+	if err := json.Unmarshal(raw, cur); err != nil {
+		return nil, err
+	}
+	if cur.Sort != q.effectiveSort() {
+		return nil, errCursorSort
+	}
+	return cur, nil
+}
@@ -0,0 +1,237 @@
+// Package query parses the pagination, sorting and filtering params of list endpoints.
+//
+// A list endpoint declares what it supports with a Spec:
+//
+//	GET /v1/admin/users?page=2&limit=50&sort=-createdAt&role=admin&createdAt[gte]=2026-01-01T00:00:00Z
+//
+// Filters are written as field[op]=value, a bare field=value uses the first op of the
+// filter. Params of undeclared filters are ignored, everything else is checked by the
+// CustomValidator and reported as localized validation errors.
+package query
+
+import (
+	"database/sql"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Type is the type of a filter value
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Bool
+	Time
+)
+
+// Op is a filter operator
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpNe       Op = "ne"
+	OpGt       Op = "gt"
+	OpGte      Op = "gte"
+	OpLt       Op = "lt"
+	OpLte      Op = "lte"
+	OpContains Op = "contains"
+)
+
+// FilterSpec declares a filterable field, the first op is the default one
+type FilterSpec struct {
+	Type Type
+	Ops  []Op
+}
+
+// Spec declares the params a list endpoint supports
+type Spec struct {
+	// DefaultLimit and MaxLimit fall back to the package defaults when zero
+	DefaultLimit int
+	MaxLimit     int
+	// Sorts are the sortable fields, a "-" prefix in the sort param sorts descending
+	Sorts       []string
+	DefaultSort string
+	Filters     map[string]FilterSpec
+	// Cursor allows keyset pagination with the cursor param, next to page numbers
+	Cursor bool
+}
+
+// Filter is a filter given in the request
+type Filter struct {
+	Field string
+	Op    Op
+	Value string
+}
+
+// Result is one page of a list, with what response.WithPagination needs
+type Result[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+}
+
+// Query holds the list params of a request, build it with New and fill it with Bind
+type Query struct {
+	spec *Spec
+
+	page    int
+	limit   int
+	cursor  string
+	sort    string
+	filters []Filter
+}
+
+var filterKeyPattern = regexp.MustCompile(`^(\w+)(?:\[(\w+)\])?$`)
+
+func New(spec *Spec) *Query {
+	return &Query{spec: spec}
+}
+
+// Bind reads the params from the query string, they are checked by c.Validate
+func (q *Query) Bind(c echo.Context) error {
+	if err := echo.QueryParamsBinder(c).
+		Int("page", &q.page).
+		Int("limit", &q.limit).
+		String("cursor", &q.cursor).
+		String("sort", &q.sort).
+		BindError(); err != nil {
+		return err
+	}
+
+	// keys are read in order, so the first of repeated filters is always the same one
+	params := c.QueryParams()
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		match := filterKeyPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		filter, ok := q.spec.Filters[match[1]]
+		if !ok {
+			continue
+		}
+		op := Op(match[2])
+		if op == "" {
+			op = filter.Ops[0]
+		}
+		if _, ok := q.Filter(match[1], op); ok {
+			continue
+		}
+		q.filters = append(q.filters, Filter{Field: match[1], Op: op, Value: params.Get(key)})
+	}
+	return nil
+}
+
+// Page is the requested page number, starting from 1
+func (q *Query) Page() int {
+	return max(q.page, 1)
+}
+
+func (q *Query) Limit() int {
+	if q.limit > 0 {
+		return q.limit
+	}
+	if q.spec.DefaultLimit > 0 {
+		return q.spec.DefaultLimit
+	}
+	return DefaultLimit
+}
+
+// Offset is the offset of the requested page, it is 0 when a cursor is used
+func (q *Query) Offset() int {
+	if q.UsesCursor() {
+		return 0
+	}
+	return (q.Page() - 1) * q.Limit()
+}
+
+// UsesCursor reports whether the request pages with a cursor instead of page numbers
+func (q *Query) UsesCursor() bool {
+	return q.cursor != ""
+}
+
+// SortBy is the field to sort by, one of Spec.Sorts
+func (q *Query) SortBy() string {
+	field, _ := splitSort(q.effectiveSort())
+	return field
+}
+
+func (q *Query) SortDesc() bool {
+	_, desc := splitSort(q.effectiveSort())
+	return desc
+}
+
+func (q *Query) effectiveSort() string {
+	if q.sort != "" {
+		return q.sort
+	}
+	return q.spec.DefaultSort
+}
+
+func (q *Query) maxLimit() int {
+	if q.spec.MaxLimit > 0 {
+		return q.spec.MaxLimit
+	}
+	return MaxLimit
+}
+
+// Filter returns the value of a filter, ok is false when it was not given
+func (q *Query) Filter(field string, op Op) (string, bool) {
+	for _, f := range q.filters {
+		if f.Field == field && f.Op == op {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// NullString returns a filter as a query param, a missing filter is NULL
+func (q *Query) NullString(field string, op Op) sql.NullString {
+	value, ok := q.Filter(field, op)
+	return sql.NullString{String: value, Valid: ok}
+}
+
+func (q *Query) NullInt64(field string, op Op) sql.NullInt64 {
+	value, ok := q.Filter(field, op)
+	if !ok {
+		return sql.NullInt64{}
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	return sql.NullInt64{Int64: i, Valid: err == nil}
+}
+
+func (q *Query) NullBool(field string, op Op) sql.NullBool {
+	value, ok := q.Filter(field, op)
+	if !ok {
+		return sql.NullBool{}
+	}
+	b, err := strconv.ParseBool(value)
+	return sql.NullBool{Bool: b, Valid: err == nil}
+}
+
+func (q *Query) NullTime(field string, op Op) sql.NullTime {
+	value, ok := q.Filter(field, op)
+	if !ok {
+		return sql.NullTime{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return sql.NullTime{Time: t, Valid: err == nil}
+}
+
+// splitSort splits "-name" into the field and the direction
+func splitSort(sort string) (string, bool) {
+	if len(sort) > 0 && sort[0] == '-' {
+		return sort[1:], true
+	}
+	return sort, false
+}
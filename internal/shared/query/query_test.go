@@ -0,0 +1,114 @@
+package query_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-echo-template/internal/shared/query"
+	"go-echo-template/internal/shared/response"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+var testSpec = &query.Spec{
+	Sorts:       []string{"name", "createdAt"},
+	DefaultSort: "-createdAt",
+	Filters: map[string]query.FilterSpec{
+		"name":      {Type: query.String, Ops: []query.Op{query.OpContains, query.OpEq}},
+		"age":       {Type: query.Int, Ops: []query.Op{query.OpGte, query.OpLt}},
+		"active":    {Type: query.Bool, Ops: []query.Op{query.OpEq}},
+		"createdAt": {Type: query.Time, Ops: []query.Op{query.OpGte}},
+	},
+	Cursor: true,
+}
+
+// parse binds and validates a query string like a handler does
+func parse(t *testing.T, spec *query.Spec, target string) (*query.Query, error) {
+	t.Helper()
+
+	e := echo.New()
+	e.Validator = response.NewValidator()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), httptest.NewRecorder())
+
+	q := query.New(spec)
+	require.NoError(t, q.Bind(c))
+	return q, c.Validate(q)
+}
+
+// failedFields returns the fields and tags of the validation errors
+func failedFields(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	var verrs validator.ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+	fields := make(map[string]string, len(verrs))
+	for _, fe := range verrs {
+		fields[fe.Field()] = fe.Tag()
+	}
+	return fields
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		q, err := parse(t, testSpec, "/")
+		require.NoError(t, err)
+		require.Equal(t, 1, q.Page())
+		require.Equal(t, query.DefaultLimit, q.Limit())
+		require.Equal(t, 0, q.Offset())
+		require.Equal(t, "createdAt", q.SortBy())
+		require.True(t, q.SortDesc())
+	})
+
+	t.Run("Pages, Sorts And Filters", func(t *testing.T) {
+		q, err := parse(t, testSpec, "/?page=3&limit=10&sort=name&name=jo&age[gte]=18&active=true&createdAt[gte]=2026-01-01T00:00:00Z&unknown=1")
+		require.NoError(t, err)
+		require.Equal(t, 20, q.Offset())
+		require.Equal(t, "name", q.SortBy())
+		require.False(t, q.SortDesc())
+
+		require.Equal(t, "jo", q.NullString("name", query.OpContains).String, "a bare filter uses the first op")
+		require.False(t, q.NullString("name", query.OpEq).Valid)
+		require.Equal(t, int64(18), q.NullInt64("age", query.OpGte).Int64)
+		require.False(t, q.NullInt64("age", query.OpLt).Valid)
+		require.True(t, q.NullBool("active", query.OpEq).Bool)
+		require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), q.NullTime("createdAt", query.OpGte).Time)
+	})
+
+	t.Run("Invalid Params Are Reported Per Field", func(t *testing.T) {
+		_, err := parse(t, testSpec, "/?limit=500&sort=-password&age[eq]=1&active=maybe&createdAt[gte]=yesterday")
+		require.Equal(t, map[string]string{
+			"limit":     "max",
+			"sort":      "oneof",
+			"age":       "oneof",
+			"active":    "boolean",
+			"createdAt": "datetime",
+		}, failedFields(t, err))
+
+		_, err = parse(t, &query.Spec{}, "/?sort=name&cursor=abc")
+		require.Equal(t, map[string]string{"sort": "excluded", "cursor": "excluded"}, failedFields(t, err))
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		q, err := parse(t, testSpec, "/?sort=name")
+		require.NoError(t, err)
+		next := q.NextCursor("Jane", 42)
+
+		q, err = parse(t, testSpec, "/?sort=name&cursor="+next)
+		require.NoError(t, err)
+		require.True(t, q.UsesCursor())
+		value, id, ok := q.After()
+		require.True(t, ok)
+		require.Equal(t, "Jane", value)
+		require.Equal(t, int64(42), id)
+
+		_, err = parse(t, testSpec, "/?sort=-name&cursor="+next)
+		require.Equal(t, map[string]string{"cursor": "cursor"}, failedFields(t, err), "cursors are tied to their sort")
+
+		_, err = parse(t, testSpec, "/?sort=name&page=2&cursor="+next)
+		require.Equal(t, map[string]string{"page": "excluded_with"}, failedFields(t, err))
+	})
+}
@@ -0,0 +1,107 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// maxFilterLength bounds string filter values
+const maxFilterLength = 255
+
+var errCursorSort = errors.New("cursor was created for another sort")
+
+// Validate is the struct level validation of Query, registered by response.NewValidator.
+// Errors are reported under the param names, so they read like any other field error.
+func Validate(sl validator.StructLevel) {
+	q := sl.Current().Interface().(Query)
+
+	if q.page < 0 {
+		sl.ReportError(q.page, "page", "Page", "min", "1")
+	}
+	if q.limit < 0 {
+		sl.ReportError(q.limit, "limit", "Limit", "min", "1")
+	}
+	if q.limit > q.maxLimit() {
+		sl.ReportError(q.limit, "limit", "Limit", "max", strconv.Itoa(q.maxLimit()))
+	}
+
+	if q.sort != "" {
+		if len(q.spec.Sorts) == 0 {
+			sl.ReportError(q.sort, "sort", "Sort", "excluded", "")
+		} else if field, _ := splitSort(q.sort); !slices.Contains(q.spec.Sorts, field) {
+			sl.ReportError(q.sort, "sort", "Sort", "oneof", sortOptions(q.spec.Sorts))
+		}
+	}
+
+	if q.cursor != "" {
+		switch {
+		case !q.spec.Cursor:
+			sl.ReportError(q.cursor, "cursor", "Cursor", "excluded", "")
+		case q.page != 0:
+			sl.ReportError(q.page, "page", "Page", "excluded_with", "cursor")
+		default:
+			if _, err := q.decodeCursor(); err != nil {
+				sl.ReportError(q.cursor, "cursor", "Cursor", "cursor", "")
+			}
+		}
+	}
+
+	for _, f := range q.filters {
+		filter := q.spec.Filters[f.Field]
+		if !slices.Contains(filter.Ops, f.Op) {
+			sl.ReportError(string(f.Op), f.Field, f.Field, "oneof", opOptions(filter.Ops))
+			continue
+		}
+		if tag, param := checkValue(filter.Type, f.Value); tag != "" {
+			sl.ReportError(f.Value, f.Field, f.Field, tag, param)
+		}
+	}
+}
+
+// checkValue returns the failed validation tag of a filter value, if any
+func checkValue(typ Type, value string) (string, string) {
+	var err error
+	switch typ {
+	case String:
+		if len(value) > maxFilterLength {
+			return "max", strconv.Itoa(maxFilterLength)
+		}
+	case Int:
+		if _, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return "numeric", ""
+		}
+	case Bool:
+		if _, err = strconv.ParseBool(value); err != nil {
+			return "boolean", ""
+		}
+	case Time:
+		if _, err = time.Parse(time.RFC3339, value); err != nil {
+			return "datetime", ""
+		}
+	default:
+		panic(fmt.Sprintf("query: unknown filter type %d", typ))
+	}
+	return "", ""
+}
+
+func sortOptions(sorts []string) string {
+	options := make([]string, 0, len(sorts)*2)
+	for _, sort := range sorts {
+		options = append(options, sort, "-"+sort)
+	}
+	return strings.Join(options, " ")
+}
+
+func opOptions(ops []Op) string {
+	options := make([]string, len(ops))
+	for i, op := range ops {
+		options[i] = string(op)
+	}
+	return strings.Join(options, " ")
+}
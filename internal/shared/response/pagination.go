@@ -0,0 +1,75 @@
+package response
+
+import (
+	"net/url"
+	"strconv"
+
+	"go-echo-template/internal/shared/query"
+)
+
+type paginationMeta struct {
+	// Page is left out when the request pages with a cursor
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"totalPages"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type paginationLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// WithPagination adds the meta and links of a list response. Total is the count of all
+// matching items, nextCursor is empty on the last page or when cursors are not supported.
+func (s *successResponse) WithPagination(q *query.Query, total int64, nextCursor string) *successResponse {
+	limit := q.Limit()
+	totalPages := max(int((total+int64(limit)-1)/int64(limit)), 1)
+	meta := &paginationMeta{Limit: limit, Total: total, TotalPages: totalPages, NextCursor: nextCursor}
+
+	// links are relative, so they do not depend on the Host header
+	u := s.c.Request().URL
+	links := &paginationLinks{
+		Self:  u.RequestURI(),
+		First: pageLink(u, "", ""),
+	}
+	if q.UsesCursor() {
+		if nextCursor != "" {
+			links.Next = pageLink(u, "", nextCursor)
+		}
+	} else {
+		page := q.Page()
+		meta.Page = page
+		if page > 1 {
+			links.Prev = pageLink(u, strconv.Itoa(min(page-1, totalPages)), "")
+		}
+		if page < totalPages {
+			links.Next = pageLink(u, strconv.Itoa(page+1), "")
+		}
+		links.Last = pageLink(u, strconv.Itoa(totalPages), "")
+	}
+
+	s.Meta = meta
+	s.Links = links
+	return s
+}
+
+// pageLink is the request URL with the page and cursor params replaced, empty ones are removed
+func pageLink(u *url.URL, page, cursor string) string {
+	values := u.Query()
+	values.Del("page")
+	values.Del("cursor")
+	if page != "" {
+		values.Set("page", page)
+	}
+	if cursor != "" {
+		values.Set("cursor", cursor)
+	}
+
+	link := url.URL{Path: u.Path, RawQuery: values.Encode()}
+	return link.String()
+}
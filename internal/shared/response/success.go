@@ -41,6 +41,8 @@ type successResponse struct {
 	Status  int    `json:"status"`
 	Message string `json:"message,omitempty"`
 	Data    any    `json:"data,omitempty"`
+	Meta    any    `json:"meta,omitempty"`
+	Links   any    `json:"links,omitempty"`
 }
 
 func Success(c echo.Context, status int) *successResponse {
@@ -3,6 +3,8 @@ package response
 import (
 	"reflect"
 
+	"go-echo-template/internal/shared/query"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -72,6 +74,9 @@ func NewValidator() *CustomValidator {
 		return hasUpper && hasLower && hasDigit && hasSpecial
 	})
 
+	// List queries check their params against the whitelists of each endpoint
+	v.RegisterStructValidation(query.Validate, query.Query{})
+
 	return &CustomValidator{
 		validator: v,
 	}
@@ -83,7 +88,7 @@ func (cv *CustomValidator) Validate(i any) error {
 
 func TagHandler(fe validator.FieldError, fieldName string) (string, []any) {
 	switch fe.Tag() {
-	case "required":
+	case "required", "required_without", "required_without_all":
 		return "VAL:REQUIRED", []any{fieldName}
 	case "email":
 		return "VAL:EMAIL", []any{fieldName}
@@ -95,10 +100,22 @@ func TagHandler(fe validator.FieldError, fieldName string) (string, []any) {
 		return "VAL:ALPHA", []any{fieldName}
 	case "alphanum":
 		return "VAL:ALPHANUM", []any{fieldName}
+	case "numeric":
+		return "VAL:NUMERIC", []any{fieldName}
 	case "contains":
 		return "VAL:CONTAINS", []any{fieldName, fe.Param()}
 	case "oneof":
 		return "VAL:ONEOF", []any{fieldName, fe.Param()}
+	case "boolean":
+		return "VAL:BOOLEAN", []any{fieldName}
+	case "datetime":
+		return "VAL:DATETIME", []any{fieldName}
+	case "cursor":
+		return "VAL:CURSOR", []any{fieldName}
+	case "excluded":
+		return "VAL:EXCLUDED", []any{fieldName}
+	case "excluded_with":
+		return "VAL:EXCLUDED_WITH", []any{fieldName, fe.Param()}
 	case "gte":
 		switch fe.Kind() {
 		case reflect.String:
@@ -3,6 +3,7 @@ package utils
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -74,3 +75,19 @@ func MustGetDurationEnv(key string) time.Duration {
 
 	return duration
 }
+
+// GetStrSliceEnv reads a comma separated list, surrounding spaces and empty items are dropped
+func GetStrSliceEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -183,3 +183,20 @@ func TestMustGetDurationEnv(t *testing.T) {
 		}, "expected panic for numeric string duration value")
 	})
 }
+
+// GetStrSliceEnv
+func TestGetStrSliceEnv(t *testing.T) {
+	t.Run("Existing Environment Variable", func(t *testing.T) {
+		os.Setenv("TEST_STR_SLICE_ENV", " https://a.example.com, https://b.example.com ,,")
+		defer os.Unsetenv("TEST_STR_SLICE_ENV")
+
+		result := GetStrSliceEnv("TEST_STR_SLICE_ENV", nil)
+		require.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, result)
+	})
+
+	t.Run("Default Value for Non-existent Environment Variable", func(t *testing.T) {
+		testDefaultValue := []string{"Some Default Test Value"}
+		result := GetStrSliceEnv("NON_EXISTENT_ENV", testDefaultValue)
+		require.Equal(t, testDefaultValue, result)
+	})
+}
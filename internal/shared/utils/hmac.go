@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Sign returns the base64url encoded HMAC-SHA256 of message keyed with secret.
+func Sign(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature is a valid Sign output for message,
+// the comparison runs in constant time.
+func VerifySignature(secret, message, signature string) bool {
+	expected := Sign(secret, message)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifySignature(t *testing.T) {
+	t.Run("Valid Signature", func(t *testing.T) {
+		signature := Sign("secret", "42.1700000000")
+		require.NotEmpty(t, signature)
+		require.True(t, VerifySignature("secret", "42.1700000000", signature))
+	})
+
+	t.Run("Tampered Message", func(t *testing.T) {
+		signature := Sign("secret", "42.1700000000")
+		require.False(t, VerifySignature("secret", "43.1700000000", signature))
+	})
+
+	t.Run("Wrong Secret", func(t *testing.T) {
+		signature := Sign("secret", "42.1700000000")
+		require.False(t, VerifySignature("another-secret", "42.1700000000", signature))
+	})
+
+	t.Run("URL Safe Output", func(t *testing.T) {
+		signature := Sign("secret", "some message")
+		require.NotContains(t, signature, "+")
+		require.NotContains(t, signature, "/")
+		require.NotContains(t, signature, "=")
+	})
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms, every stored hash names its algorithm so that
+// hashes of different algorithms and parameters can live side by side
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+var errInvalidArgon2idHash = errors.New("invalid argon2id hash")
+
+const (
+	argon2idPrefix    = "$argon2id$"
+	argon2idSaltSize  = 16
+	argon2idKeyLength = 32
+)
+
+// PasswordHashParams selects the algorithm and cost new password hashes are created with
+type PasswordHashParams struct {
+	Algorithm  string
+	BcryptCost int
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// HashPassword takes a plaintext password and returns its hash. Argon2id hashes use the
+// PHC string format "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>",
+// bcrypt hashes their own "$2a$<cost>$..." format.
+func HashPassword(password string, params *PasswordHashParams) (string, error) {
+	switch params.Algorithm {
+	case PasswordHashBcrypt:
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), params.BcryptCost)
+		return string(bytes), err
+	case PasswordHashArgon2id:
+		salt := make([]byte, argon2idSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, params.Argon2Iterations, params.Argon2Memory, params.Argon2Parallelism, argon2idKeyLength)
+		return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2idPrefix,
+			argon2.Version,
+			params.Argon2Memory,
+			params.Argon2Iterations,
+			params.Argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm: %s", params.Algorithm)
+	}
+}
+
+// CheckPasswordHash compares a plaintext password with its hashed version, the
+// algorithm and its parameters are taken from the hash.
+func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		stored, err := parseArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		key := argon2.IDKey([]byte(password), stored.salt, stored.iterations, stored.memory, stored.parallelism, uint32(len(stored.key)))
+		return subtle.ConstantTimeCompare(key, stored.key) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// PasswordNeedsRehash reports whether a hash was created with another algorithm or
+// other parameters than params, a successful login should then replace it
+func PasswordNeedsRehash(hash string, params *PasswordHashParams) bool {
+	switch params.Algorithm {
+	case PasswordHashBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != params.BcryptCost
+	case PasswordHashArgon2id:
+		stored, err := parseArgon2idHash(hash)
+		return err != nil ||
+			stored.version != argon2.Version ||
+			stored.memory != params.Argon2Memory ||
+			stored.iterations != params.Argon2Iterations ||
+			stored.parallelism != params.Argon2Parallelism
+	default:
+		return false
+	}
+}
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func parseArgon2idHash(hash string) (*argon2idHash, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != PasswordHashArgon2id {
+		return nil, errInvalidArgon2idHash
+	}
+
+	parsed := new(argon2idHash)
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &parsed.version); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.iterations, &parsed.parallelism); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, err
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, err
+	}
+	// argon2 panics on a zero time or parallelism
+	if parsed.iterations == 0 || parsed.parallelism == 0 || len(parsed.key) == 0 {
+		return nil, errInvalidArgon2idHash
+	}
+	return parsed, nil
+}
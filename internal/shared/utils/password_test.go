@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters keep the tests fast
+var (
+	testBcryptParams   = &PasswordHashParams{Algorithm: PasswordHashBcrypt, BcryptCost: bcrypt.MinCost}
+	testArgon2idParams = &PasswordHashParams{Algorithm: PasswordHashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1}
+)
+
+func TestHashPasswordAndCheckPasswordHash(t *testing.T) {
+	for _, params := range []*PasswordHashParams{testBcryptParams, testArgon2idParams} {
+		t.Run(params.Algorithm, func(t *testing.T) {
+			t.Run("Hash and Check Success", func(t *testing.T) {
+				password := "mySuperSecret123!"
+				hash, err := HashPassword(password, params)
+				require.NoError(t, err, "Hashing should not return error")
+				require.NotEmpty(t, hash, "Hash should not be empty")
+				require.True(t, CheckPasswordHash(password, hash), "Password should match hash")
+			})
+
+			t.Run("Hash and Check Failure (wrong password)", func(t *testing.T) {
+				password := "correctPassword"
+				wrongPassword := "wrongPassword"
+				hash, err := HashPassword(password, params)
+				require.NoError(t, err, "Hashing should not return error")
+				require.False(t, CheckPasswordHash(wrongPassword, hash), "Wrong password should not match hash")
+			})
+
+			t.Run("HashPassword Different Hashes for Same Password", func(t *testing.T) {
+				password := "repeatablePassword"
+				hash1, err1 := HashPassword(password, params)
+				hash2, err2 := HashPassword(password, params)
+				require.NoError(t, err1)
+				require.NoError(t, err2)
+				require.NotEqual(t, hash1, hash2, "Hashes should be different due to random salt")
+				// But both hashes should validate
+				require.True(t, CheckPasswordHash(password, hash1))
+				require.True(t, CheckPasswordHash(password, hash2))
+			})
+		})
+	}
+
+	t.Run("Argon2id Hashes Use The PHC Format", func(t *testing.T) {
+		hash, err := HashPassword("password", testArgon2idParams)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+	})
+
+	t.Run("CheckPasswordHash with Invalid Hash", func(t *testing.T) {
+		for _, invalidHash := range []string{
+			"$2a$10$invalidnotavalidhashvalueeeeeeeeeeeeeeeeeeeeeeeeeeeee",
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+			"$argon2id$v=19$m=64,t=1,p=1$!!!$!!!",
+			"",
+		} {
+			require.False(t, CheckPasswordHash("irrelevant", invalidHash), "Should return false for invalid hash format")
+		}
+	})
+
+	t.Run("Unknown Algorithm Is Rejected", func(t *testing.T) {
+		_, err := HashPassword("password", &PasswordHashParams{Algorithm: "md5"})
+		require.Error(t, err)
+	})
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	bcryptHash, err := HashPassword("password", testBcryptParams)
+	require.NoError(t, err)
+	argon2idHash, err := HashPassword("password", testArgon2idParams)
+	require.NoError(t, err)
+
+	require.False(t, PasswordNeedsRehash(bcryptHash, testBcryptParams))
+	require.False(t, PasswordNeedsRehash(argon2idHash, testArgon2idParams))
+
+	require.True(t, PasswordNeedsRehash(bcryptHash, testArgon2idParams), "bcrypt hashes are upgraded")
+	require.True(t, PasswordNeedsRehash(argon2idHash, testBcryptParams))
+
+	stronger := *testArgon2idParams
+	stronger.Argon2Iterations = 2
+	require.True(t, PasswordNeedsRehash(argon2idHash, &stronger), "changed parameters are applied on the next login")
+
+	costlier := *testBcryptParams
+	costlier.BcryptCost++
+	require.True(t, PasswordNeedsRehash(bcryptHash, &costlier))
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+	t.Run("Token Length", func(t *testing.T) {
+		token, err := GenerateToken(32)
+		require.NoError(t, err)
+		require.Len(t, token, 64, "hex encoding doubles the byte length")
+	})
+
+	t.Run("Tokens Are Unique", func(t *testing.T) {
+		token1, err1 := GenerateToken(32)
+		token2, err2 := GenerateToken(32)
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.NotEqual(t, token1, token2)
+	})
+}
+
+func TestHashToken(t *testing.T) {
+	t.Run("Deterministic", func(t *testing.T) {
+		require.Equal(t, HashToken("some-token"), HashToken("some-token"))
+	})
+
+	t.Run("Known Digest", func(t *testing.T) {
+		// sha256("abc")
+		require.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", HashToken("abc"))
+	})
+
+	t.Run("Different Tokens Different Hashes", func(t *testing.T) {
+		require.NotEqual(t, HashToken("token-a"), HashToken("token-b"))
+	})
+}
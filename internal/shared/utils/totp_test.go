@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 4226 / RFC 6238 test secret
+const rfcSecret = "12345678901234567890"
+
+func TestHOTP(t *testing.T) {
+	t.Run("RFC 4226 Test Vectors", func(t *testing.T) {
+		expected := []string{
+			"755224", "287082", "359152", "969429", "338314",
+			"254676", "287922", "162583", "399871", "520489",
+		}
+		for counter, code := range expected {
+			require.Equal(t, code, hotp([]byte(rfcSecret), uint64(counter), 6))
+		}
+	})
+
+	t.Run("RFC 6238 SHA1 Test Vectors", func(t *testing.T) {
+		vectors := map[int64]string{
+			59:          "94287082",
+			1111111109:  "07081804",
+			1111111111:  "14050471",
+			1234567890:  "89005924",
+			2000000000:  "69279037",
+			20000000000: "65353130",
+		}
+		for unix, code := range vectors {
+			require.Equal(t, code, hotp([]byte(rfcSecret), TOTPStep(time.Unix(unix, 0)), 8))
+		}
+	})
+}
+
+func TestGenerateAndValidateTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(rfcSecret))
+	now := time.Unix(1111111109, 0)
+
+	t.Run("Current Step", func(t *testing.T) {
+		code, err := GenerateTOTP(secret, now)
+		require.NoError(t, err)
+		require.Equal(t, "081804", code)
+
+		step, ok := ValidateTOTP(secret, code, now, 1)
+		require.True(t, ok)
+		require.Equal(t, int64(TOTPStep(now)), step)
+	})
+
+	t.Run("Clock Skew Within Window", func(t *testing.T) {
+		code, err := GenerateTOTP(secret, now.Add(-TOTPPeriod))
+		require.NoError(t, err)
+
+		_, ok := ValidateTOTP(secret, code, now, 1)
+		require.True(t, ok)
+
+		_, ok = ValidateTOTP(secret, code, now, 0)
+		require.False(t, ok, "previous step must fail without skew")
+	})
+
+	t.Run("Wrong Code", func(t *testing.T) {
+		_, ok := ValidateTOTP(secret, "000000", now, 1)
+		require.False(t, ok)
+
+		_, ok = ValidateTOTP(secret, "12345", now, 1)
+		require.False(t, ok, "code length must match")
+	})
+
+	t.Run("Invalid Secret", func(t *testing.T) {
+		_, err := GenerateTOTP("not base32!", now)
+		require.Error(t, err)
+	})
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32, "160 bits encode to 32 base32 characters")
+
+	code, err := GenerateTOTP(secret, time.Now())
+	require.NoError(t, err)
+	require.Len(t, code, TOTPDigits)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("My App", "jane@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/My App:jane@example.com", parsed.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	require.Equal(t, "My App", parsed.Query().Get("issuer"))
+}
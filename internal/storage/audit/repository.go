@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/storage/audit/sqlc"
+)
+
+// AuditRepository only appends and reads, audit events are never changed once written
+type AuditRepository interface {
+	CreateAuditEvent(ctx context.Context, params sqlc.CreateAuditEventParams) error
+	ListAuditEvents(ctx context.Context, params sqlc.ListAuditEventsParams) ([]sqlc.AuditEvent, error)
+	CountAuditEvents(ctx context.Context, params sqlc.CountAuditEventsParams) (int64, error)
+
+	// transaction
+	WithTx(tx *sql.Tx) AuditRepository
+}
+
+type repository struct {
+	logger log.CustomLogger
+
+	db      sqlc.DBTX
+	queries *sqlc.Queries
+}
+
+func NewAuditRepository(logger log.CustomLogger, db *sql.DB) AuditRepository {
+	return &repository{logger: logger, db: db, queries: sqlc.New(db)}
+}
+
+func (r *repository) WithTx(tx *sql.Tx) AuditRepository {
+	return &repository{
+		logger:  r.logger,
+		queries: sqlc.New(tx),
+		db:      tx,
+	}
+}
+
+func (r *repository) CreateAuditEvent(ctx context.Context, params sqlc.CreateAuditEventParams) error {
+	return r.queries.CreateAuditEvent(ctx, params)
+}
+
+func (r *repository) ListAuditEvents(ctx context.Context, params sqlc.ListAuditEventsParams) ([]sqlc.AuditEvent, error) {
+	return r.queries.ListAuditEvents(ctx, params)
+}
+
+func (r *repository) CountAuditEvents(ctx context.Context, params sqlc.CountAuditEventsParams) (int64, error) {
+	return r.queries.CountAuditEvents(ctx, params)
+}
@@ -0,0 +1,30 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (actor_id, action, target_type, target_id, ip, user_agent, request_id, diff)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListAuditEvents :many
+-- Every filter is optional, a NULL filter matches all events. before_id continues a
+-- cursor paged list after the last event of the previous page.
+SELECT id, actor_id, action, target_type, target_id, ip, user_agent, request_id, diff, created_at
+FROM audit_events
+WHERE
+    (sqlc.narg(actor_id)::BIGINT IS NULL OR actor_id = sqlc.narg(actor_id)) AND
+    (sqlc.narg(action)::TEXT IS NULL OR action = sqlc.narg(action)) AND
+    (sqlc.narg(target_type)::TEXT IS NULL OR target_type = sqlc.narg(target_type)) AND
+    (sqlc.narg(target_id)::BIGINT IS NULL OR target_id = sqlc.narg(target_id)) AND
+    (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from)) AND
+    (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to)) AND
+    (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountAuditEvents :one
+SELECT COUNT(*)
+FROM audit_events
+WHERE
+    (sqlc.narg(actor_id)::BIGINT IS NULL OR actor_id = sqlc.narg(actor_id)) AND
+    (sqlc.narg(action)::TEXT IS NULL OR action = sqlc.narg(action)) AND
+    (sqlc.narg(target_type)::TEXT IS NULL OR target_type = sqlc.narg(target_type)) AND
+    (sqlc.narg(target_id)::BIGINT IS NULL OR target_id = sqlc.narg(target_id)) AND
+    (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from)) AND
+    (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to));
@@ -12,6 +12,32 @@ type AuthRepository interface {
 	GetUserByEmail(ctx context.Context, email string) (*sqlc.GetUserByEmailRow, error)
 	GetUserById(ctx context.Context, userID int64) (*sqlc.GetUserByIdRow, error)
 
+	// two-factor authentication
+	GetUserTotp(ctx context.Context, userID int64) (*sqlc.UserTotp, error)
+	CreateUserTotp(ctx context.Context, params sqlc.CreateUserTotpParams) error
+	DeleteUserTotp(ctx context.Context, userID int64) error
+	CreateUserRecoveryCode(ctx context.Context, params sqlc.CreateUserRecoveryCodeParams) error
+	DeleteUserRecoveryCodes(ctx context.Context, userID int64) error
+	UseUserRecoveryCode(ctx context.Context, params sqlc.UseUserRecoveryCodeParams) (int64, error)
+
+	// webauthn credentials
+	CreateUserCredential(ctx context.Context, params sqlc.CreateUserCredentialParams) error
+	ListUserCredentials(ctx context.Context, userID int64) ([]sqlc.UserCredential, error)
+	GetUserCredentialByCredentialId(ctx context.Context, credentialID []byte) (*sqlc.UserCredential, error)
+	UpdateUserCredentialUsage(ctx context.Context, params sqlc.UpdateUserCredentialUsageParams) error
+	DeleteUserCredential(ctx context.Context, params sqlc.DeleteUserCredentialParams) (int64, error)
+
+	// external identities
+	GetUserIdentity(ctx context.Context, params sqlc.GetUserIdentityParams) (*sqlc.UserIdentity, error)
+	CreateUserIdentity(ctx context.Context, params sqlc.CreateUserIdentityParams) error
+
+	// personal access tokens
+	CreateApiKey(ctx context.Context, params sqlc.CreateApiKeyParams) (*sqlc.ApiKey, error)
+	ListApiKeys(ctx context.Context, userID int64) ([]sqlc.ApiKey, error)
+	GetApiKeyBySecretHash(ctx context.Context, secretHash string) (*sqlc.ApiKey, error)
+	UpdateApiKeyLastUsed(ctx context.Context, id int64) error
+	DeleteApiKey(ctx context.Context, params sqlc.DeleteApiKeyParams) (int64, error)
+
 	WithTx(tx *sql.Tx) AuthRepository
 }
 
@@ -51,3 +77,100 @@ func (r *repository) GetUserById(ctx context.Context, userID int64) (*sqlc.GetUs
 
 	return &userRow, nil
 }
+
+func (r *repository) GetUserTotp(ctx context.Context, userID int64) (*sqlc.UserTotp, error) {
+	totp, err := r.queries.GetUserTotp(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+func (r *repository) CreateUserTotp(ctx context.Context, params sqlc.CreateUserTotpParams) error {
+	return r.queries.CreateUserTotp(ctx, params)
+}
+
+func (r *repository) DeleteUserTotp(ctx context.Context, userID int64) error {
+	return r.queries.DeleteUserTotp(ctx, userID)
+}
+
+func (r *repository) CreateUserRecoveryCode(ctx context.Context, params sqlc.CreateUserRecoveryCodeParams) error {
+	return r.queries.CreateUserRecoveryCode(ctx, params)
+}
+
+func (r *repository) DeleteUserRecoveryCodes(ctx context.Context, userID int64) error {
+	return r.queries.DeleteUserRecoveryCodes(ctx, userID)
+}
+
+func (r *repository) UseUserRecoveryCode(ctx context.Context, params sqlc.UseUserRecoveryCodeParams) (int64, error) {
+	return r.queries.UseUserRecoveryCode(ctx, params)
+}
+
+func (r *repository) CreateUserCredential(ctx context.Context, params sqlc.CreateUserCredentialParams) error {
+	return r.queries.CreateUserCredential(ctx, params)
+}
+
+func (r *repository) ListUserCredentials(ctx context.Context, userID int64) ([]sqlc.UserCredential, error) {
+	return r.queries.ListUserCredentials(ctx, userID)
+}
+
+func (r *repository) GetUserCredentialByCredentialId(ctx context.Context, credentialID []byte) (*sqlc.UserCredential, error) {
+	credential, err := r.queries.GetUserCredentialByCredentialId(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+func (r *repository) UpdateUserCredentialUsage(ctx context.Context, params sqlc.UpdateUserCredentialUsageParams) error {
+	return r.queries.UpdateUserCredentialUsage(ctx, params)
+}
+
+func (r *repository) DeleteUserCredential(ctx context.Context, params sqlc.DeleteUserCredentialParams) (int64, error) {
+	return r.queries.DeleteUserCredential(ctx, params)
+}
+
+func (r *repository) GetUserIdentity(ctx context.Context, params sqlc.GetUserIdentityParams) (*sqlc.UserIdentity, error) {
+	identity, err := r.queries.GetUserIdentity(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *repository) CreateUserIdentity(ctx context.Context, params sqlc.CreateUserIdentityParams) error {
+	return r.queries.CreateUserIdentity(ctx, params)
+}
+
+func (r *repository) CreateApiKey(ctx context.Context, params sqlc.CreateApiKeyParams) (*sqlc.ApiKey, error) {
+	apiKey, err := r.queries.CreateApiKey(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (r *repository) ListApiKeys(ctx context.Context, userID int64) ([]sqlc.ApiKey, error) {
+	return r.queries.ListApiKeys(ctx, userID)
+}
+
+func (r *repository) GetApiKeyBySecretHash(ctx context.Context, secretHash string) (*sqlc.ApiKey, error) {
+	apiKey, err := r.queries.GetApiKeyBySecretHash(ctx, secretHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (r *repository) UpdateApiKeyLastUsed(ctx context.Context, id int64) error {
+	return r.queries.UpdateApiKeyLastUsed(ctx, id)
+}
+
+func (r *repository) DeleteApiKey(ctx context.Context, params sqlc.DeleteApiKeyParams) (int64, error) {
+	return r.queries.DeleteApiKey(ctx, params)
+}
@@ -1,4 +1,5 @@
 -- name: GetUserByEmail :one
+-- Suspended users are left out here and in GetUserById, so they can neither log in nor use API keys
 SELECT 
     id, 
     name, 
@@ -7,11 +8,13 @@ SELECT
     role, 
     password,
     created_at, 
-    updated_at
+    updated_at,
+    email_verified_at
 FROM users 
 WHERE 
     email = $1 AND
-    is_deleted = FALSE
+    is_deleted = FALSE AND
+    suspended_at IS NULL
 LIMIT 1;
 
 -- name: GetUserById :one
@@ -23,9 +26,99 @@ SELECT
     role, 
     password,
     created_at, 
-    updated_at
+    updated_at,
+    email_verified_at
 FROM users 
 WHERE 
     id = $1 AND
-    is_deleted = FALSE
-LIMIT 1;
\ No newline at end of file
+    is_deleted = FALSE AND
+    suspended_at IS NULL
+LIMIT 1;
+
+-- name: GetUserTotp :one
+SELECT user_id, secret, confirmed_at
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateUserTotp :exec
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, confirmed_at = NOW();
+
+-- name: DeleteUserTotp :exec
+DELETE FROM user_totp WHERE user_id = $1;
+
+-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1;
+
+-- name: UseUserRecoveryCode :execrows
+UPDATE user_recovery_codes SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CreateUserCredential :exec
+INSERT INTO user_credentials (
+    user_id,
+    credential_id,
+    public_key,
+    attestation_type,
+    aaguid,
+    sign_count,
+    clone_warning,
+    transports,
+    flags,
+    name
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: ListUserCredentials :many
+SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, clone_warning, transports, flags, name, created_at, last_used_at
+FROM user_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetUserCredentialByCredentialId :one
+SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, clone_warning, transports, flags, name, created_at, last_used_at
+FROM user_credentials
+WHERE credential_id = $1;
+
+-- name: UpdateUserCredentialUsage :exec
+UPDATE user_credentials
+SET sign_count = $2, clone_warning = $3, flags = $4, last_used_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteUserCredential :execrows
+DELETE FROM user_credentials WHERE id = $1 AND user_id = $2;
+
+-- name: GetUserIdentity :one
+SELECT id, user_id, provider, subject, email, created_at
+FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4);
+
+-- name: CreateApiKey :one
+INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at;
+
+-- name: ListApiKeys :many
+SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetApiKeyBySecretHash :one
+SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE secret_hash = $1;
+
+-- name: UpdateApiKeyLastUsed :exec
+UPDATE api_keys SET last_used_at = NOW() WHERE id = $1;
+
+-- name: DeleteApiKey :execrows
+DELETE FROM api_keys WHERE id = $1 AND user_id = $2;
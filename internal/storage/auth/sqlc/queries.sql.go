@@ -11,6 +11,217 @@ import (
 	"time"
 )
 
+const createApiKey = `-- name: CreateApiKey :one
+INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+`
+
+type CreateApiKeyParams struct {
+	UserID     int64
+	Name       string
+	Prefix     string
+	SecretHash string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+}
+
+func (q *Queries) CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createApiKey,
+		arg.UserID,
+		arg.Name,
+		arg.Prefix,
+		arg.SecretHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.SecretHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createUserCredential = `-- name: CreateUserCredential :exec
+INSERT INTO user_credentials (
+    user_id,
+    credential_id,
+    public_key,
+    attestation_type,
+    aaguid,
+    sign_count,
+    clone_warning,
+    transports,
+    flags,
+    name
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`
+
+type CreateUserCredentialParams struct {
+	UserID          int64
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Aaguid          []byte
+	SignCount       int64
+	CloneWarning    bool
+	Transports      string
+	Flags           int16
+	Name            string
+}
+
+func (q *Queries) CreateUserCredential(ctx context.Context, arg CreateUserCredentialParams) error {
+	_, err := q.db.ExecContext(ctx, createUserCredential,
+		arg.UserID,
+		arg.CredentialID,
+		arg.PublicKey,
+		arg.AttestationType,
+		arg.Aaguid,
+		arg.SignCount,
+		arg.CloneWarning,
+		arg.Transports,
+		arg.Flags,
+		arg.Name,
+	)
+	return err
+}
+
+const createUserIdentity = `-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateUserIdentityParams struct {
+	UserID   int64
+	Provider string
+	Subject  string
+	Email    sql.NullString
+}
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) error {
+	_, err := q.db.ExecContext(ctx, createUserIdentity,
+		arg.UserID,
+		arg.Provider,
+		arg.Subject,
+		arg.Email,
+	)
+	return err
+}
+
+const createUserRecoveryCode = `-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES ($1, $2)
+`
+
+type CreateUserRecoveryCodeParams struct {
+	UserID   int64
+	CodeHash string
+}
+
+func (q *Queries) CreateUserRecoveryCode(ctx context.Context, arg CreateUserRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createUserRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const createUserTotp = `-- name: CreateUserTotp :exec
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, confirmed_at = NOW()
+`
+
+type CreateUserTotpParams struct {
+	UserID int64
+	Secret string
+}
+
+func (q *Queries) CreateUserTotp(ctx context.Context, arg CreateUserTotpParams) error {
+	_, err := q.db.ExecContext(ctx, createUserTotp, arg.UserID, arg.Secret)
+	return err
+}
+
+const deleteApiKey = `-- name: DeleteApiKey :execrows
+DELETE FROM api_keys WHERE id = $1 AND user_id = $2
+`
+
+type DeleteApiKeyParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) DeleteApiKey(ctx context.Context, arg DeleteApiKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteApiKey, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUserCredential = `-- name: DeleteUserCredential :execrows
+DELETE FROM user_credentials WHERE id = $1 AND user_id = $2
+`
+
+type DeleteUserCredentialParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) DeleteUserCredential(ctx context.Context, arg DeleteUserCredentialParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserCredential, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUserRecoveryCodes = `-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserRecoveryCodes(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteUserRecoveryCodes, userID)
+	return err
+}
+
+const deleteUserTotp = `-- name: DeleteUserTotp :exec
+DELETE FROM user_totp WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserTotp(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTotp, userID)
+	return err
+}
+
+const getApiKeyBySecretHash = `-- name: GetApiKeyBySecretHash :one
+SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE secret_hash = $1
+`
+
+func (q *Queries) GetApiKeyBySecretHash(ctx context.Context, secretHash string) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getApiKeyBySecretHash, secretHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.SecretHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
 SELECT 
     id, 
@@ -20,25 +231,29 @@ SELECT
     role, 
     password,
     created_at, 
-    updated_at
+    updated_at,
+    email_verified_at
 FROM users 
 WHERE 
     email = $1 AND
-    is_deleted = FALSE
+    is_deleted = FALSE AND
+    suspended_at IS NULL
 LIMIT 1
 `
 
 type GetUserByEmailRow struct {
-	ID        int64
-	Name      string
-	Email     string
-	Phone     sql.NullString
-	Role      string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              int64
+	Name            string
+	Email           string
+	Phone           sql.NullString
+	Role            string
+	Password        string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
 }
 
+// Suspended users are left out here and in GetUserById, so they can neither log in nor use API keys
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
 	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
 	var i GetUserByEmailRow
@@ -51,6 +266,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -64,23 +280,26 @@ SELECT
     role, 
     password,
     created_at, 
-    updated_at
+    updated_at,
+    email_verified_at
 FROM users 
 WHERE 
     id = $1 AND
-    is_deleted = FALSE
+    is_deleted = FALSE AND
+    suspended_at IS NULL
 LIMIT 1
 `
 
 type GetUserByIdRow struct {
-	ID        int64
-	Name      string
-	Email     string
-	Phone     sql.NullString
-	Role      string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              int64
+	Name            string
+	Email           string
+	Phone           sql.NullString
+	Role            string
+	Password        string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EmailVerifiedAt sql.NullTime
 }
 
 func (q *Queries) GetUserById(ctx context.Context, id int64) (GetUserByIdRow, error) {
@@ -95,6 +314,206 @@ func (q *Queries) GetUserById(ctx context.Context, id int64) (GetUserByIdRow, er
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserCredentialByCredentialId = `-- name: GetUserCredentialByCredentialId :one
+SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, clone_warning, transports, flags, name, created_at, last_used_at
+FROM user_credentials
+WHERE credential_id = $1
+`
+
+func (q *Queries) GetUserCredentialByCredentialId(ctx context.Context, credentialID []byte) (UserCredential, error) {
+	row := q.db.QueryRowContext(ctx, getUserCredentialByCredentialId, credentialID)
+	var i UserCredential
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.AttestationType,
+		&i.Aaguid,
+		&i.SignCount,
+		&i.CloneWarning,
+		&i.Transports,
+		&i.Flags,
+		&i.Name,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const getUserIdentity = `-- name: GetUserIdentity :one
+SELECT id, user_id, provider, subject, email, created_at
+FROM user_identities
+WHERE provider = $1 AND subject = $2
+`
+
+type GetUserIdentityParams struct {
+	Provider string
+	Subject  string
+}
+
+func (q *Queries) GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error) {
+	row := q.db.QueryRowContext(ctx, getUserIdentity, arg.Provider, arg.Subject)
+	var i UserIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
 	)
 	return i, err
 }
+
+const getUserTotp = `-- name: GetUserTotp :one
+SELECT user_id, secret, confirmed_at
+FROM user_totp
+WHERE user_id = $1
+`
+
+func (q *Queries) GetUserTotp(ctx context.Context, userID int64) (UserTotp, error) {
+	row := q.db.QueryRowContext(ctx, getUserTotp, userID)
+	var i UserTotp
+	err := row.Scan(&i.UserID, &i.Secret, &i.ConfirmedAt)
+	return i, err
+}
+
+const listApiKeys = `-- name: ListApiKeys :many
+SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListApiKeys(ctx context.Context, userID int64) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listApiKeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.Prefix,
+			&i.SecretHash,
+			&i.Scopes,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserCredentials = `-- name: ListUserCredentials :many
+SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, clone_warning, transports, flags, name, created_at, last_used_at
+FROM user_credentials
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListUserCredentials(ctx context.Context, userID int64) ([]UserCredential, error) {
+	rows, err := q.db.QueryContext(ctx, listUserCredentials, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserCredential
+	for rows.Next() {
+		var i UserCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CredentialID,
+			&i.PublicKey,
+			&i.AttestationType,
+			&i.Aaguid,
+			&i.SignCount,
+			&i.CloneWarning,
+			&i.Transports,
+			&i.Flags,
+			&i.Name,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateApiKeyLastUsed = `-- name: UpdateApiKeyLastUsed :exec
+UPDATE api_keys SET last_used_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) UpdateApiKeyLastUsed(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, updateApiKeyLastUsed, id)
+	return err
+}
+
+const updateUserCredentialUsage = `-- name: UpdateUserCredentialUsage :exec
+UPDATE user_credentials
+SET sign_count = $2, clone_warning = $3, flags = $4, last_used_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserCredentialUsageParams struct {
+	ID           int64
+	SignCount    int64
+	CloneWarning bool
+	Flags        int16
+}
+
+func (q *Queries) UpdateUserCredentialUsage(ctx context.Context, arg UpdateUserCredentialUsageParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserCredentialUsage,
+		arg.ID,
+		arg.SignCount,
+		arg.CloneWarning,
+		arg.Flags,
+	)
+	return err
+}
+
+const useUserRecoveryCode = `-- name: UseUserRecoveryCode :execrows
+UPDATE user_recovery_codes SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+type UseUserRecoveryCodeParams struct {
+	UserID   int64
+	CodeHash string
+}
+
+func (q *Queries) UseUserRecoveryCode(ctx context.Context, arg UseUserRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useUserRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,328 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often writes sweep out expired keys
+const memorySweepInterval = time.Minute
+
+// memoryEntry is a plain value, or a set or hash when fields is not nil
+type memoryEntry struct {
+	value     string
+	fields    map[string]string
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the session state in the process, for tests and single instance
+// deployments without redis. Everything is lost on restart
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type MemoryStoreOption func(*MemoryStore)
+
+// WithClock replaces time.Now, tests use it to let keys expire without waiting
+func WithClock(now func() time.Time) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.now = now
+	}
+}
+
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{entries: map[string]*memoryEntry{}, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.lastSweep = s.now()
+	return s
+}
+
+// Keys returns the live keys in order
+func (s *MemoryStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		if s.live(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// live returns the entry of the key unless it's missing or expired, expired entries are dropped
+func (s *MemoryStore) live(key string) *memoryEntry {
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expiresAt.IsZero() && !s.now().Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// put stores an entry, expired keys are swept out along the way so that keys nobody reads again don't pile up
+func (s *MemoryStore) put(key string, entry *memoryEntry) {
+	s.entries[key] = entry
+
+	now := s.now()
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key := range s.entries {
+		s.live(key)
+	}
+}
+
+func (s *MemoryStore) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(ttl)
+}
+
+// extend makes the entry live at least ttl
+func (s *MemoryStore) extend(entry *memoryEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	if expiresAt := s.expiry(ttl); entry.expiresAt.IsZero() || expiresAt.After(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil {
+		return "", ErrNotFound
+	}
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl == KeepTTL {
+		if entry := s.live(key); entry != nil {
+			expiresAt = entry.expiresAt
+		}
+	} else {
+		expiresAt = s.expiry(ttl)
+	}
+	s.put(key, &memoryEntry{value: value, expiresAt: expiresAt})
+	return nil
+}
+
+func (s *MemoryStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.live(key) != nil {
+		return false, nil
+	}
+	s.put(key, &memoryEntry{value: value, expiresAt: s.expiry(ttl)})
+	return true, nil
+}
+
+func (s *MemoryStore) GetDel(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil {
+		return "", ErrNotFound
+	}
+	delete(s.entries, key)
+	return entry.value, nil
+}
+
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil || entry.fields != nil || entry.value != old {
+		return false, nil
+	}
+	s.put(key, &memoryEntry{value: value, expiresAt: s.expiry(ttl)})
+	return true, nil
+}
+
+func (s *MemoryStore) Del(ctx context.Context, keys ...string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, key := range keys {
+		if s.live(key) != nil {
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.live(key) != nil, nil
+}
+
+func (s *MemoryStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil || entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(s.now()), nil
+}
+
+func (s *MemoryStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.live(key); entry != nil {
+		entry.expiresAt = s.expiry(ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Extend(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.live(key); entry != nil {
+		s.extend(entry, ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil {
+		s.put(key, &memoryEntry{value: "1", expiresAt: s.expiry(ttl)})
+		return 1, nil
+	}
+
+	count, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	entry.value = strconv.FormatInt(count, 10)
+	return count, nil
+}
+
+func (s *MemoryStore) SAdd(ctx context.Context, key, member string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil {
+		entry = &memoryEntry{fields: map[string]string{}, expiresAt: s.expiry(ttl)}
+		s.put(key, entry)
+	}
+	entry.fields[member] = ""
+	return int64(len(entry.fields)), nil
+}
+
+func (s *MemoryStore) HSet(ctx context.Context, key, field, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hash(key, ttl)[field] = value
+	return nil
+}
+
+func (s *MemoryStore) HSetNX(ctx context.Context, key, field, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields := s.hash(key, ttl)
+	if _, ok := fields[field]; ok {
+		return false, nil
+	}
+	fields[field] = value
+	return true, nil
+}
+
+// hash returns the fields of the hash, created when missing, after extending it to live at least ttl
+func (s *MemoryStore) hash(key string, ttl time.Duration) map[string]string {
+	entry := s.live(key)
+	if entry == nil || entry.fields == nil {
+		entry = &memoryEntry{fields: map[string]string{}}
+		s.put(key, entry)
+	}
+	s.extend(entry, ttl)
+	return entry.fields
+}
+
+func (s *MemoryStore) HGet(ctx context.Context, key, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil {
+		return "", ErrNotFound
+	}
+	value, ok := entry.fields[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *MemoryStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields := map[string]string{}
+	if entry := s.live(key); entry != nil {
+		for field, value := range entry.fields {
+			fields[field] = value
+		}
+	}
+	return fields, nil
+}
+
+func (s *MemoryStore) HDel(ctx context.Context, key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(key)
+	if entry == nil || entry.fields == nil {
+		return nil
+	}
+	for _, field := range fields {
+		delete(entry.fields, field)
+	}
+	// like redis, a hash without fields doesn't exist
+	if len(entry.fields) == 0 {
+		delete(s.entries, key)
+	}
+	return nil
+}
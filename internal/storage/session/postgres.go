@@ -0,0 +1,237 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-echo-template/internal/shared/log"
+	"go-echo-template/internal/storage/session/sqlc"
+)
+
+// postgresStore keeps every key in the sessions table, plain values under the empty field and
+// the members of sets and hashes in a row each. Expired rows are ignored by every query and
+// deleted in the background
+type postgresStore struct {
+	logger log.CustomLogger
+
+	db      *sql.DB
+	queries *sqlc.Queries
+}
+
+func NewPostgresStore(ctx context.Context, logger log.CustomLogger, db *sql.DB, cleanupInterval time.Duration) SessionStore {
+	s := &postgresStore{logger: logger, db: db, queries: sqlc.New(db)}
+	go s.cleanup(ctx, cleanupInterval)
+	return s
+}
+
+// cleanup deletes expired rows every interval until ctx is done
+func (s *postgresStore) cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.queries.DeleteExpiredSessions(ctx); err != nil {
+				s.logger.WarnWithContext(ctx, "failed to delete expired sessions", s.logger.Err(err))
+			}
+		}
+	}
+}
+
+// withTx runs the queries of fn in one transaction
+func (s *postgresStore) withTx(ctx context.Context, fn func(q *sqlc.Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(s.queries.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) Get(ctx context.Context, key string) (string, error) {
+	return noRows(s.queries.GetSessionValue(ctx, key))
+}
+
+func (s *postgresStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl == KeepTTL {
+		return s.queries.SetSessionValueKeepTTL(ctx, sqlc.SetSessionValueKeepTTLParams{Key: key, Value: value})
+	}
+	return s.queries.SetSessionValue(ctx, sqlc.SetSessionValueParams{Key: key, Value: value, TtlMs: ttl.Milliseconds()})
+}
+
+func (s *postgresStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	affected, err := s.queries.SetSessionValueNX(ctx, sqlc.SetSessionValueNXParams{
+		Key:   key,
+		Value: value,
+		TtlMs: ttl.Milliseconds(),
+	})
+	return affected > 0, err
+}
+
+func (s *postgresStore) GetDel(ctx context.Context, key string) (string, error) {
+	row, err := s.queries.GetDelSessionValue(ctx, key)
+	if err != nil {
+		return noRows("", err)
+	}
+	if !row.Live {
+		return "", ErrNotFound
+	}
+	return row.Value, nil
+}
+
+func (s *postgresStore) CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	affected, err := s.queries.CompareAndSwapSessionValue(ctx, sqlc.CompareAndSwapSessionValueParams{
+		NewValue: value,
+		TtlMs:    ttl.Milliseconds(),
+		Key:      key,
+		OldValue: old,
+	})
+	return affected > 0, err
+}
+
+func (s *postgresStore) Del(ctx context.Context, keys ...string) (int64, error) {
+	var deleted int64
+	err := s.withTx(ctx, func(q *sqlc.Queries) error {
+		for _, key := range keys {
+			live, err := q.DeleteSessionKey(ctx, key)
+			if err != nil {
+				return err
+			}
+			for _, ok := range live {
+				if ok {
+					deleted++
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (s *postgresStore) Exists(ctx context.Context, key string) (bool, error) {
+	return s.queries.SessionKeyExists(ctx, key)
+}
+
+func (s *postgresStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttlMs, err := s.queries.GetSessionTTL(ctx, key)
+	return time.Duration(ttlMs) * time.Millisecond, err
+}
+
+func (s *postgresStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return s.queries.ExpireSessionKey(ctx, sqlc.ExpireSessionKeyParams{TtlMs: ttl.Milliseconds(), Key: key})
+}
+
+func (s *postgresStore) Extend(ctx context.Context, key string, ttl time.Duration) error {
+	return s.queries.ExtendSessionKey(ctx, sqlc.ExtendSessionKeyParams{TtlMs: ttl.Milliseconds(), Key: key})
+}
+
+func (s *postgresStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return s.queries.IncrSessionCounter(ctx, sqlc.IncrSessionCounterParams{Key: key, TtlMs: ttl.Milliseconds()})
+}
+
+func (s *postgresStore) SAdd(ctx context.Context, key, member string, ttl time.Duration) (int64, error) {
+	var count int64
+	err := s.withTx(ctx, func(q *sqlc.Queries) error {
+		if err := q.AddSessionSetMember(ctx, sqlc.AddSessionSetMemberParams{
+			Key:    key,
+			Member: member,
+			TtlMs:  ttl.Milliseconds(),
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		count, err = q.CountSessionFields(ctx, key)
+		return err
+	})
+	return count, err
+}
+
+func (s *postgresStore) HSet(ctx context.Context, key, field, value string, ttl time.Duration) error {
+	return s.withTx(ctx, func(q *sqlc.Queries) error {
+		if err := q.SetSessionField(ctx, sqlc.SetSessionFieldParams{
+			Key:   key,
+			Field: field,
+			Value: value,
+			TtlMs: ttl.Milliseconds(),
+		}); err != nil {
+			return err
+		}
+		return s.extendHash(ctx, q, key, ttl)
+	})
+}
+
+func (s *postgresStore) HSetNX(ctx context.Context, key, field, value string, ttl time.Duration) (bool, error) {
+	var affected int64
+	err := s.withTx(ctx, func(q *sqlc.Queries) error {
+		var err error
+		affected, err = q.SetSessionFieldNX(ctx, sqlc.SetSessionFieldNXParams{
+			Key:   key,
+			Field: field,
+			Value: value,
+			TtlMs: ttl.Milliseconds(),
+		})
+		if err != nil {
+			return err
+		}
+		return s.extendHash(ctx, q, key, ttl)
+	})
+	return affected > 0, err
+}
+
+// extendHash moves the expiry of every field along, the fields of a hash share its lifetime
+func (s *postgresStore) extendHash(ctx context.Context, q *sqlc.Queries, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return q.ExtendSessionKey(ctx, sqlc.ExtendSessionKeyParams{TtlMs: ttl.Milliseconds(), Key: key})
+}
+
+func (s *postgresStore) HGet(ctx context.Context, key, field string) (string, error) {
+	return noRows(s.queries.GetSessionField(ctx, sqlc.GetSessionFieldParams{Key: key, Field: field}))
+}
+
+func (s *postgresStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	rows, err := s.queries.ListSessionFields(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(rows))
+	for _, row := range rows {
+		fields[row.Field] = row.Value
+	}
+	return fields, nil
+}
+
+func (s *postgresStore) HDel(ctx context.Context, key string, fields ...string) error {
+	return s.withTx(ctx, func(q *sqlc.Queries) error {
+		for _, field := range fields {
+			if err := q.DeleteSessionField(ctx, sqlc.DeleteSessionFieldParams{Key: key, Field: field}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// noRows translates sql.ErrNoRows into ErrNotFound
+func noRows(value string, err error) (string, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisStore struct {
+	rc *redis.Client
+}
+
+func NewRedisStore(rc *redis.Client) SessionStore {
+	return &redisStore{rc: rc}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (string, error) {
+	return notFound(s.rc.Get(ctx, key).Result())
+}
+
+func (s *redisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl == KeepTTL {
+		ttl = redis.KeepTTL
+	}
+	return s.rc.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *redisStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return s.rc.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (s *redisStore) GetDel(ctx context.Context, key string) (string, error) {
+	return notFound(s.rc.GetDel(ctx, key).Result())
+}
+
+func (s *redisStore) CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	err := s.rc.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if current != old {
+			return redis.TxFailedErr
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, value, ttl)
+			return nil
+		})
+		return err
+	}, key)
+	if err == redis.Nil || err == redis.TxFailedErr {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *redisStore) Del(ctx context.Context, keys ...string) (int64, error) {
+	return s.rc.Del(ctx, keys...).Result()
+}
+
+func (s *redisStore) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := s.rc.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (s *redisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.rc.PTTL(ctx, key).Result()
+	if err != nil || ttl < 0 {
+		// -1 for keys without expiry, -2 for missing keys
+		return 0, err
+	}
+	return ttl, nil
+}
+
+func (s *redisStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return s.rc.Expire(ctx, key, ttl).Err()
+}
+
+func (s *redisStore) Extend(ctx context.Context, key string, ttl time.Duration) error {
+	pipe := s.rc.TxPipeline()
+	pipe.ExpireNX(ctx, key, ttl)
+	pipe.ExpireGT(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := s.rc.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	if ttl > 0 {
+		pipe.ExpireNX(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+func (s *redisStore) SAdd(ctx context.Context, key, member string, ttl time.Duration) (int64, error) {
+	pipe := s.rc.TxPipeline()
+	pipe.SAdd(ctx, key, member)
+	if ttl > 0 {
+		pipe.ExpireNX(ctx, key, ttl)
+	}
+	card := pipe.SCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return card.Val(), nil
+}
+
+func (s *redisStore) HSet(ctx context.Context, key, field, value string, ttl time.Duration) error {
+	pipe := s.rc.TxPipeline()
+	pipe.HSet(ctx, key, field, value)
+	if ttl > 0 {
+		pipe.ExpireNX(ctx, key, ttl)
+		pipe.ExpireGT(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisStore) HSetNX(ctx context.Context, key, field, value string, ttl time.Duration) (bool, error) {
+	pipe := s.rc.TxPipeline()
+	set := pipe.HSetNX(ctx, key, field, value)
+	if ttl > 0 {
+		pipe.ExpireNX(ctx, key, ttl)
+		pipe.ExpireGT(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return set.Val(), nil
+}
+
+func (s *redisStore) HGet(ctx context.Context, key, field string) (string, error) {
+	return notFound(s.rc.HGet(ctx, key, field).Result())
+}
+
+func (s *redisStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return s.rc.HGetAll(ctx, key).Result()
+}
+
+func (s *redisStore) HDel(ctx context.Context, key string, fields ...string) error {
+	return s.rc.HDel(ctx, key, fields...).Err()
+}
+
+// notFound translates redis.Nil into ErrNotFound
+func notFound(value string, err error) (string, error) {
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
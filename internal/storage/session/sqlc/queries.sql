@@ -0,0 +1,154 @@
+-- name: GetSessionValue :one
+SELECT value
+FROM sessions
+WHERE key = $1 AND field = '' AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: SetSessionValue :exec
+INSERT INTO sessions (key, field, value, expires_at)
+VALUES (
+    sqlc.arg(key), '', sqlc.arg(value),
+    CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+)
+ON CONFLICT (key, field) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at;
+
+-- name: SetSessionValueKeepTTL :exec
+INSERT INTO sessions (key, field, value)
+VALUES ($1, '', $2)
+ON CONFLICT (key, field) DO UPDATE
+SET value = EXCLUDED.value,
+    expires_at = CASE WHEN sessions.expires_at > NOW() THEN sessions.expires_at END;
+
+-- name: SetSessionValueNX :execrows
+-- only replaces expired rows, no row is affected while the key is live
+INSERT INTO sessions (key, field, value, expires_at)
+VALUES (
+    sqlc.arg(key), '', sqlc.arg(value),
+    CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+)
+ON CONFLICT (key, field) DO UPDATE
+SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at
+WHERE sessions.expires_at <= NOW();
+
+-- name: GetDelSessionValue :one
+DELETE FROM sessions
+WHERE key = $1 AND field = ''
+RETURNING value, (expires_at IS NULL OR expires_at > NOW())::boolean AS live;
+
+-- name: CompareAndSwapSessionValue :execrows
+UPDATE sessions
+SET value = sqlc.arg(new_value),
+    expires_at = CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+WHERE key = sqlc.arg(key) AND field = '' AND value = sqlc.arg(old_value)
+    AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: DeleteSessionKey :many
+DELETE FROM sessions
+WHERE key = $1
+RETURNING (expires_at IS NULL OR expires_at > NOW())::boolean AS live;
+
+-- name: SessionKeyExists :one
+SELECT EXISTS (
+    SELECT 1 FROM sessions
+    WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW())
+);
+
+-- name: GetSessionTTL :one
+-- remaining lifetime in milliseconds, 0 for missing keys and keys without expiry
+SELECT COALESCE(MAX(EXTRACT(EPOCH FROM expires_at - NOW()) * 1000), 0)::bigint AS ttl_ms
+FROM sessions
+WHERE key = $1 AND expires_at > NOW();
+
+-- name: ExpireSessionKey :exec
+UPDATE sessions
+SET expires_at = NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond'
+WHERE key = sqlc.arg(key) AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ExtendSessionKey :exec
+-- a longer lifetime is kept, keys without expiry get one
+UPDATE sessions
+SET expires_at = GREATEST(expires_at, NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond')
+WHERE key = sqlc.arg(key) AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: IncrSessionCounter :one
+-- an expired counter starts over with the new lifetime
+INSERT INTO sessions (key, field, value, expires_at)
+VALUES (
+    sqlc.arg(key), '', '1',
+    CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+)
+ON CONFLICT (key, field) DO UPDATE
+SET value = CASE
+        WHEN sessions.expires_at IS NULL OR sessions.expires_at > NOW() THEN (sessions.value::bigint + 1)::text
+        ELSE '1'
+    END,
+    expires_at = CASE
+        WHEN sessions.expires_at IS NULL OR sessions.expires_at > NOW() THEN sessions.expires_at
+        ELSE EXCLUDED.expires_at
+    END
+RETURNING value::bigint AS value;
+
+-- name: AddSessionSetMember :exec
+-- members share the lifetime of the set, which starts with its first member
+INSERT INTO sessions (key, field, value, expires_at)
+VALUES (
+    sqlc.arg(key), sqlc.arg(member), '',
+    COALESCE(
+        (SELECT MAX(s.expires_at) FROM sessions s WHERE s.key = sqlc.arg(key) AND s.expires_at > NOW()),
+        CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+    )
+)
+ON CONFLICT (key, field) DO UPDATE
+SET expires_at = EXCLUDED.expires_at
+WHERE sessions.expires_at <= NOW();
+
+-- name: CountSessionFields :one
+SELECT COUNT(*)
+FROM sessions
+WHERE key = $1 AND field <> '' AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: SetSessionField :exec
+-- new fields join the lifetime of the hash, the caller extends the other fields
+INSERT INTO sessions (key, field, value, expires_at)
+VALUES (
+    sqlc.arg(key), sqlc.arg(field), sqlc.arg(value),
+    GREATEST(
+        (SELECT MAX(s.expires_at) FROM sessions s WHERE s.key = sqlc.arg(key) AND s.expires_at > NOW()),
+        CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+    )
+)
+ON CONFLICT (key, field) DO UPDATE
+SET value = EXCLUDED.value,
+    expires_at = CASE
+        WHEN sessions.expires_at <= NOW() THEN EXCLUDED.expires_at
+        ELSE GREATEST(sessions.expires_at, EXCLUDED.expires_at)
+    END;
+
+-- name: SetSessionFieldNX :execrows
+INSERT INTO sessions (key, field, value, expires_at)
+VALUES (
+    sqlc.arg(key), sqlc.arg(field), sqlc.arg(value),
+    GREATEST(
+        (SELECT MAX(s.expires_at) FROM sessions s WHERE s.key = sqlc.arg(key) AND s.expires_at > NOW()),
+        CASE WHEN sqlc.arg(ttl_ms)::bigint > 0 THEN NOW() + sqlc.arg(ttl_ms)::bigint * INTERVAL '1 millisecond' END
+    )
+)
+ON CONFLICT (key, field) DO UPDATE
+SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at
+WHERE sessions.expires_at <= NOW();
+
+-- name: GetSessionField :one
+SELECT value
+FROM sessions
+WHERE key = $1 AND field = $2 AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ListSessionFields :many
+SELECT field, value
+FROM sessions
+WHERE key = $1 AND field <> '' AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY field;
+
+-- name: DeleteSessionField :exec
+DELETE FROM sessions WHERE key = $1 AND field = $2;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at <= NOW();
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-echo-template/internal/config"
+	"go-echo-template/internal/shared/log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound is returned for keys and fields that don't exist or have expired
+var ErrNotFound = errors.New("session store: not found")
+
+// KeepTTL makes Set keep the expiry of the existing key
+const KeepTTL time.Duration = -1
+
+// SessionStore keeps sessions and the other short-lived auth state. It covers the subset of
+// redis the auth module uses: plain values, counters, sets and hashes with an expiry per key.
+// A ttl of 0 means the key doesn't expire, unless documented otherwise
+type SessionStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	// Set replaces the value and expiry of the key, KeepTTL keeps the current expiry
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX sets the key only when it doesn't exist and reports whether it did
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	GetDel(ctx context.Context, key string) (string, error)
+	// CompareAndSwap replaces the value only while it's still old and reports whether it did
+	CompareAndSwap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error)
+	// Del removes the keys and returns how many of them existed
+	Del(ctx context.Context, keys ...string) (int64, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// TTL returns the remaining lifetime, 0 for missing keys and keys without expiry
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Expire sets the lifetime of an existing key
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Extend makes an existing key live at least ttl, a longer lifetime is kept
+	Extend(ctx context.Context, key string, ttl time.Duration) error
+
+	// Incr increments the counter and returns its new value, ttl applies to a new counter
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// SAdd adds a member to the set and returns its size, ttl applies to a new set
+	SAdd(ctx context.Context, key, member string, ttl time.Duration) (int64, error)
+
+	// HSet sets a field of the hash and extends the hash to live at least ttl, 0 leaves the expiry alone
+	HSet(ctx context.Context, key, field, value string, ttl time.Duration) error
+	// HSetNX is HSet for fields that don't exist yet, the expiry is extended either way
+	HSetNX(ctx context.Context, key, field, value string, ttl time.Duration) (bool, error)
+	HGet(ctx context.Context, key, field string) (string, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HDel(ctx context.Context, key string, fields ...string) error
+}
+
+// NewSessionStore returns the store selected by SESSION_STORE, the postgres store
+// deletes expired rows until ctx is done
+func NewSessionStore(
+	ctx context.Context,
+	cfg *config.SessionConfig,
+	logger log.CustomLogger,
+	db *sql.DB,
+	rc *redis.Client,
+) SessionStore {
+	switch cfg.Store {
+	case "postgres":
+		return NewPostgresStore(ctx, logger, db, cfg.CleanupInterval)
+	case "memory":
+		return NewMemoryStore()
+	default:
+		if rc == nil {
+			panic("SESSION_STORE is redis but REDIS_HOST is not set")
+		}
+		return NewRedisStore(rc)
+	}
+}
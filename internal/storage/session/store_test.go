@@ -0,0 +1,191 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// testStores returns every store that runs without a database, each with a function moving its clock
+func testStores(t *testing.T) map[string]func() (SessionStore, func(time.Duration)) {
+	return map[string]func() (SessionStore, func(time.Duration)){
+		"memory": func() (SessionStore, func(time.Duration)) {
+			now := time.Now()
+			store := NewMemoryStore(WithClock(func() time.Time { return now }))
+			return store, func(d time.Duration) { now = now.Add(d) }
+		},
+		"redis": func() (SessionStore, func(time.Duration)) {
+			mr := miniredis.RunT(t)
+			rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { rc.Close() })
+			return NewRedisStore(rc), mr.FastForward
+		},
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Values Expire", func(t *testing.T) {
+				store, forward := newStore()
+
+				require.NoError(t, store.Set(ctx, "a", "1", time.Minute))
+				value, err := store.Get(ctx, "a")
+				require.NoError(t, err)
+				require.Equal(t, "1", value)
+
+				ttl, err := store.TTL(ctx, "a")
+				require.NoError(t, err)
+				require.Equal(t, time.Minute, ttl)
+
+				require.NoError(t, store.Set(ctx, "a", "2", KeepTTL))
+				forward(time.Minute)
+				_, err = store.Get(ctx, "a")
+				require.ErrorIs(t, err, ErrNotFound)
+
+				ttl, err = store.TTL(ctx, "a")
+				require.NoError(t, err)
+				require.Zero(t, ttl)
+			})
+
+			t.Run("SetNX And GetDel", func(t *testing.T) {
+				store, forward := newStore()
+
+				set, err := store.SetNX(ctx, "a", "1", time.Minute)
+				require.NoError(t, err)
+				require.True(t, set)
+				set, err = store.SetNX(ctx, "a", "2", time.Minute)
+				require.NoError(t, err)
+				require.False(t, set)
+
+				forward(time.Minute)
+				set, err = store.SetNX(ctx, "a", "3", time.Minute)
+				require.NoError(t, err)
+				require.True(t, set, "expired keys don't count")
+
+				value, err := store.GetDel(ctx, "a")
+				require.NoError(t, err)
+				require.Equal(t, "3", value)
+				_, err = store.GetDel(ctx, "a")
+				require.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("CompareAndSwap", func(t *testing.T) {
+				store, _ := newStore()
+
+				swapped, err := store.CompareAndSwap(ctx, "a", "1", "2", time.Minute)
+				require.NoError(t, err)
+				require.False(t, swapped, "missing keys are never swapped")
+
+				require.NoError(t, store.Set(ctx, "a", "1", time.Minute))
+				swapped, err = store.CompareAndSwap(ctx, "a", "0", "2", time.Minute)
+				require.NoError(t, err)
+				require.False(t, swapped)
+				swapped, err = store.CompareAndSwap(ctx, "a", "1", "2", time.Minute)
+				require.NoError(t, err)
+				require.True(t, swapped)
+
+				value, err := store.Get(ctx, "a")
+				require.NoError(t, err)
+				require.Equal(t, "2", value)
+			})
+
+			t.Run("Del Counts Existing Keys", func(t *testing.T) {
+				store, _ := newStore()
+
+				require.NoError(t, store.Set(ctx, "a", "1", 0))
+				require.NoError(t, store.HSet(ctx, "h", "f", "v", 0))
+				deleted, err := store.Del(ctx, "a", "h", "missing")
+				require.NoError(t, err)
+				require.Equal(t, int64(2), deleted)
+
+				exists, err := store.Exists(ctx, "h")
+				require.NoError(t, err)
+				require.False(t, exists)
+			})
+
+			t.Run("Expire And Extend", func(t *testing.T) {
+				store, forward := newStore()
+
+				require.NoError(t, store.Set(ctx, "a", "1", time.Hour))
+				require.NoError(t, store.Extend(ctx, "a", time.Minute))
+				ttl, err := store.TTL(ctx, "a")
+				require.NoError(t, err)
+				require.Equal(t, time.Hour, ttl, "a longer lifetime is kept")
+
+				require.NoError(t, store.Expire(ctx, "a", time.Minute))
+				require.NoError(t, store.Extend(ctx, "a", 2*time.Minute))
+				ttl, err = store.TTL(ctx, "a")
+				require.NoError(t, err)
+				require.Equal(t, 2*time.Minute, ttl)
+
+				forward(2 * time.Minute)
+				exists, err := store.Exists(ctx, "a")
+				require.NoError(t, err)
+				require.False(t, exists)
+			})
+
+			t.Run("Counters And Sets Expire From Creation", func(t *testing.T) {
+				store, forward := newStore()
+
+				for i := int64(1); i <= 3; i++ {
+					count, err := store.Incr(ctx, "c", time.Minute)
+					require.NoError(t, err)
+					require.Equal(t, i, count)
+
+					size, err := store.SAdd(ctx, "s", "member", time.Minute)
+					require.NoError(t, err)
+					require.Equal(t, int64(1), size)
+					forward(10 * time.Second)
+				}
+				size, err := store.SAdd(ctx, "s", "other", time.Minute)
+				require.NoError(t, err)
+				require.Equal(t, int64(2), size)
+
+				forward(30 * time.Second)
+				count, err := store.Incr(ctx, "c", time.Minute)
+				require.NoError(t, err)
+				require.Equal(t, int64(1), count, "the window started with the first increment")
+				size, err = store.SAdd(ctx, "s", "member", time.Minute)
+				require.NoError(t, err)
+				require.Equal(t, int64(1), size)
+			})
+
+			t.Run("Hashes", func(t *testing.T) {
+				store, forward := newStore()
+
+				require.NoError(t, store.HSet(ctx, "h", "a", "1", time.Hour))
+				require.NoError(t, store.HSet(ctx, "h", "b", "2", time.Minute))
+				set, err := store.HSetNX(ctx, "h", "a", "3", time.Minute)
+				require.NoError(t, err)
+				require.False(t, set)
+
+				value, err := store.HGet(ctx, "h", "a")
+				require.NoError(t, err)
+				require.Equal(t, "1", value)
+				_, err = store.HGet(ctx, "h", "missing")
+				require.ErrorIs(t, err, ErrNotFound)
+
+				ttl, err := store.TTL(ctx, "h")
+				require.NoError(t, err)
+				require.Equal(t, time.Hour, ttl, "a shorter field never cuts the lifetime of the hash")
+
+				require.NoError(t, store.HDel(ctx, "h", "a"))
+				fields, err := store.HGetAll(ctx, "h")
+				require.NoError(t, err)
+				require.Equal(t, map[string]string{"b": "2"}, fields)
+
+				forward(time.Hour)
+				fields, err = store.HGetAll(ctx, "h")
+				require.NoError(t, err)
+				require.Empty(t, fields)
+			})
+		})
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"go-echo-template/internal/storage/audit"
 	"go-echo-template/internal/storage/auth"
 	"go-echo-template/internal/storage/user"
 )
@@ -11,13 +12,16 @@ type Storage struct {
 	db   *sql.DB
 	User user.UserRepository
 	Auth auth.AuthRepository
+	// Audit is part of transactions so that events commit together with the change they record
+	Audit audit.AuditRepository
 }
 
-func NewStorage(db *sql.DB, user user.UserRepository, auth auth.AuthRepository) *Storage {
+func NewStorage(db *sql.DB, user user.UserRepository, auth auth.AuthRepository, audit audit.AuditRepository) *Storage {
 	return &Storage{
-		db:   db,
-		User: user,
-		Auth: auth,
+		db:    db,
+		User:  user,
+		Auth:  auth,
+		Audit: audit,
 	}
 }
 
@@ -35,9 +39,10 @@ func (s *Storage) WithTx(ctx context.Context, fn func(*Storage) error) error {
 	}()
 
 	txStorage := &Storage{
-		db:   s.db,
-		User: s.User.WithTx(tx),
-		Auth: s.Auth.WithTx(tx),
+		db:    s.db,
+		User:  s.User.WithTx(tx),
+		Auth:  s.Auth.WithTx(tx),
+		Audit: s.Audit.WithTx(tx),
 	}
 
 	if err := fn(txStorage); err != nil {
@@ -63,3 +63,22 @@ func (c *cache) Delete(ctx context.Context, userID int64) error {
 	cacheKey := keys.GetUserKey(userID)
 	return c.rc.Del(ctx, cacheKey.Name).Err()
 }
+
+// noopCache is used when redis is not configured, every read is a miss
+type noopCache struct{}
+
+func NewNoopUserCache() UserCache {
+	return noopCache{}
+}
+
+func (noopCache) Get(ctx context.Context, userID int64) (*sqlc.User, error) {
+	return nil, nil
+}
+
+func (noopCache) Set(ctx context.Context, user *sqlc.User) error {
+	return nil
+}
+
+func (noopCache) Delete(ctx context.Context, userID int64) error {
+	return nil
+}
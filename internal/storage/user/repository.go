@@ -3,20 +3,48 @@ package user
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"go-echo-template/internal/shared"
 	"go-echo-template/internal/shared/log"
 	"go-echo-template/internal/storage/user/sqlc"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrEmailTaken is returned when a write would give two active users the same email
+var ErrEmailTaken = errors.New("email is taken by another active user")
+
+const (
+	uniqueViolationCode   = "23505"
+	emailUniqueConstraint = "users_email_unique_active"
+)
+
 type UserRepository interface {
 	GetUserById(ctx context.Context, userID int64) (*sqlc.User, error)
 	CreateUser(ctx context.Context, params sqlc.CreateUserParams) (int64, error)
 	UpdateUser(ctx context.Context, params sqlc.UpdateUserParams) error
+	ChangeUserEmail(ctx context.Context, params sqlc.ChangeUserEmailParams) (int64, error)
+	UpdateUserPassword(ctx context.Context, params sqlc.UpdateUserPasswordParams) error
+	UpdateUserAvatar(ctx context.Context, params sqlc.UpdateUserAvatarParams) (int64, error)
+	RehashUserPassword(ctx context.Context, params sqlc.RehashUserPasswordParams) (int64, error)
+	MarkUserEmailVerified(ctx context.Context, params sqlc.MarkUserEmailVerifiedParams) (int64, error)
 	DeleteUser(ctx context.Context, userID int64) error
 
+	// admin
+	GetAnyUserById(ctx context.Context, userID int64) (*sqlc.User, error)
+	ListUsers(ctx context.Context, params sqlc.ListUsersParams) ([]sqlc.User, error)
+	CountUsers(ctx context.Context, params sqlc.CountUsersParams) (int64, error)
+	SuspendUser(ctx context.Context, userID int64) (int64, error)
+	RestoreUser(ctx context.Context, userID int64) (int64, error)
+	UpdateUserRole(ctx context.Context, params sqlc.UpdateUserRoleParams) (int64, error)
+
+	// password history
+	ListPasswordHistory(ctx context.Context, params sqlc.ListPasswordHistoryParams) ([]string, error)
+	CreatePasswordHistory(ctx context.Context, params sqlc.CreatePasswordHistoryParams) error
+	PrunePasswordHistory(ctx context.Context, params sqlc.PrunePasswordHistoryParams) error
+
 	// transaction
 	WithTx(tx *sql.Tx) UserRepository
 }
@@ -101,6 +129,103 @@ func (r *repository) UpdateUser(ctx context.Context, params sqlc.UpdateUserParam
 	return nil
 }
 
+func (r *repository) ChangeUserEmail(ctx context.Context, params sqlc.ChangeUserEmailParams) (int64, error) {
+	affected, err := r.queries.ChangeUserEmail(ctx, params)
+	if isEmailTaken(err) {
+		return 0, ErrEmailTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, params.ID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during email change",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(params.ID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
+func (r *repository) UpdateUserPassword(ctx context.Context, params sqlc.UpdateUserPasswordParams) error {
+	if err := r.queries.UpdateUserPassword(ctx, params); err != nil {
+		return err
+	}
+
+	if err := r.cache.Delete(ctx, params.ID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during password update",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(params.ID)),
+		)
+		// Do not return error, continue
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateUserAvatar(ctx context.Context, params sqlc.UpdateUserAvatarParams) (int64, error) {
+	affected, err := r.queries.UpdateUserAvatar(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, params.ID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during avatar update",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(params.ID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
+func (r *repository) RehashUserPassword(ctx context.Context, params sqlc.RehashUserPasswordParams) (int64, error) {
+	affected, err := r.queries.RehashUserPassword(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, params.ID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during password rehash",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(params.ID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
+func (r *repository) MarkUserEmailVerified(ctx context.Context, params sqlc.MarkUserEmailVerifiedParams) (int64, error) {
+	affected, err := r.queries.MarkUserEmailVerified(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, params.ID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during email verification",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(params.ID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
 func (r *repository) DeleteUser(ctx context.Context, userID int64) error {
 	err := r.queries.DeleteUser(ctx, userID)
 	if err != nil {
@@ -119,3 +244,102 @@ func (r *repository) DeleteUser(ctx context.Context, userID int64) error {
 
 	return nil
 }
+
+// GetAnyUserById skips the cache, which only holds users that aren't deleted
+func (r *repository) GetAnyUserById(ctx context.Context, userID int64) (*sqlc.User, error) {
+	userRow, err := r.queries.GetAnyUserById(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, shared.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &userRow, nil
+}
+
+func (r *repository) ListUsers(ctx context.Context, params sqlc.ListUsersParams) ([]sqlc.User, error) {
+	return r.queries.ListUsers(ctx, params)
+}
+
+func (r *repository) CountUsers(ctx context.Context, params sqlc.CountUsersParams) (int64, error) {
+	return r.queries.CountUsers(ctx, params)
+}
+
+func (r *repository) SuspendUser(ctx context.Context, userID int64) (int64, error) {
+	affected, err := r.queries.SuspendUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, userID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during suspend",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(userID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
+func (r *repository) RestoreUser(ctx context.Context, userID int64) (int64, error) {
+	affected, err := r.queries.RestoreUser(ctx, userID)
+	if isEmailTaken(err) {
+		// the address was registered again after the deletion
+		return 0, ErrEmailTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, userID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during restore",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(userID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
+func (r *repository) UpdateUserRole(ctx context.Context, params sqlc.UpdateUserRoleParams) (int64, error) {
+	affected, err := r.queries.UpdateUserRole(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.cache.Delete(ctx, params.ID); err != nil {
+		r.logger.WarnWithContext(
+			ctx,
+			"failed to delete user from cache during role update",
+			r.logger.Err(err),
+			r.logger.Int("userID", int(params.ID)),
+		)
+		// Do not return error, continue
+	}
+
+	return affected, nil
+}
+
+// isEmailTaken reports whether err violates the unique email index of active users
+func isEmailTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == emailUniqueConstraint
+}
+
+func (r *repository) ListPasswordHistory(ctx context.Context, params sqlc.ListPasswordHistoryParams) ([]string, error) {
+	return r.queries.ListPasswordHistory(ctx, params)
+}
+
+func (r *repository) CreatePasswordHistory(ctx context.Context, params sqlc.CreatePasswordHistoryParams) error {
+	return r.queries.CreatePasswordHistory(ctx, params)
+}
+
+func (r *repository) PrunePasswordHistory(ctx context.Context, params sqlc.PrunePasswordHistoryParams) error {
+	return r.queries.PrunePasswordHistory(ctx, params)
+}
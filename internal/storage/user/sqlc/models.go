@@ -6,17 +6,99 @@ package sqlc
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
-type User struct {
+type ApiKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string
+	SecretHash string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type AuditEvent struct {
+	ID         int64
+	ActorID    sql.NullInt64
+	Action     string
+	TargetType string
+	TargetID   sql.NullInt64
+	Ip         string
+	UserAgent  string
+	RequestID  string
+	Diff       json.RawMessage
+	CreatedAt  time.Time
+}
+
+type PasswordHistory struct {
 	ID        int64
-	Name      string
-	Email     string
-	Phone     sql.NullString
-	Role      string
+	UserID    int64
 	Password  string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	IsDeleted bool
+}
+
+type Session struct {
+	Key       string
+	Field     string
+	Value     string
+	ExpiresAt sql.NullTime
+}
+
+type User struct {
+	ID              int64
+	Name            string
+	Email           string
+	Phone           sql.NullString
+	Role            string
+	Password        string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	IsDeleted       bool
+	EmailVerifiedAt sql.NullTime
+	SuspendedAt     sql.NullTime
+	AvatarKey       sql.NullString
+}
+
+type UserCredential struct {
+	ID              int64
+	UserID          int64
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Aaguid          []byte
+	SignCount       int64
+	CloneWarning    bool
+	Transports      string
+	Flags           int16
+	Name            string
+	CreatedAt       time.Time
+	LastUsedAt      sql.NullTime
+}
+
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     sql.NullString
+	CreatedAt time.Time
+}
+
+type UserRecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type UserTotp struct {
+	UserID      int64
+	Secret      string
+	ConfirmedAt time.Time
 }
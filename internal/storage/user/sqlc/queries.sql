@@ -8,19 +8,120 @@ SELECT
     password, 
     created_at, 
     updated_at, 
-    is_deleted
+    is_deleted,
+    email_verified_at,
+    suspended_at,
+    avatar_key
 FROM users 
 WHERE 
     id = $1 AND
     is_deleted = FALSE;
 
+-- name: GetAnyUserById :one
+-- Deleted users are included, for the admin API
+SELECT id, name, email, phone, role, password, created_at, updated_at, is_deleted, email_verified_at, suspended_at, avatar_key
+FROM users
+WHERE id = $1;
+
 -- name: CreateUser :one
 INSERT INTO users (name, email, phone, role, password)
 VALUES ($1, $2, $3, $4, $5)
 RETURNING id;
 
 -- name: UpdateUser :exec
-UPDATE users SET name = $1, email = $2, phone = $3, updated_at = NOW() WHERE id = $4 AND is_deleted = FALSE;
+-- The email is changed with ChangeUserEmail once the new address is confirmed
+UPDATE users SET
+    name = $1,
+    phone = $2,
+    updated_at = NOW()
+WHERE id = $3 AND is_deleted = FALSE;
+
+-- name: ChangeUserEmail :execrows
+-- The confirmation link proved the new address, so it is verified too. The old email is
+-- part of the condition so an outdated link can't undo a later change
+UPDATE users SET
+    email = sqlc.arg(new_email),
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id) AND email = sqlc.arg(old_email) AND is_deleted = FALSE;
 
 -- name: DeleteUser :exec
-UPDATE users SET is_deleted = TRUE, updated_at = NOW() WHERE id = $1 AND is_deleted = FALSE;
\ No newline at end of file
+UPDATE users SET is_deleted = TRUE, updated_at = NOW() WHERE id = $1 AND is_deleted = FALSE;
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2 AND is_deleted = FALSE;
+
+-- name: MarkUserEmailVerified :execrows
+-- The email is part of the condition so a link issued for an old address can't verify a new one
+UPDATE users SET
+    email_verified_at = COALESCE(email_verified_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1 AND email = $2 AND is_deleted = FALSE;
+
+-- name: RehashUserPassword :execrows
+-- The hash is only replaced while it's still the one the password was checked against,
+-- so a concurrent password change is never overwritten
+UPDATE users SET password = sqlc.arg(new_password)
+WHERE id = sqlc.arg(id) AND password = sqlc.arg(old_password) AND is_deleted = FALSE;
+
+-- name: ListUsers :many
+-- Every filter is optional, a NULL filter matches all users. The search matches a
+-- substring of the name or email, LIKE wildcards have to be escaped by the caller
+SELECT id, name, email, phone, role, password, created_at, updated_at, is_deleted, email_verified_at, suspended_at, avatar_key
+FROM users
+WHERE
+    (sqlc.narg(search)::TEXT IS NULL OR name ILIKE '%' || sqlc.narg(search) || '%' OR email ILIKE '%' || sqlc.narg(search) || '%') AND
+    (sqlc.narg(role)::TEXT IS NULL OR role = sqlc.narg(role)) AND
+    (sqlc.narg(is_deleted)::BOOLEAN IS NULL OR is_deleted = sqlc.narg(is_deleted)) AND
+    (sqlc.narg(suspended)::BOOLEAN IS NULL OR (suspended_at IS NOT NULL) = sqlc.narg(suspended))
+ORDER BY
+    CASE WHEN sqlc.arg(sort_by)::TEXT = 'name' AND NOT sqlc.arg(sort_desc)::BOOLEAN THEN name END ASC,
+    CASE WHEN sqlc.arg(sort_by)::TEXT = 'name' AND sqlc.arg(sort_desc)::BOOLEAN THEN name END DESC,
+    CASE WHEN sqlc.arg(sort_by)::TEXT = 'email' AND NOT sqlc.arg(sort_desc)::BOOLEAN THEN email END ASC,
+    CASE WHEN sqlc.arg(sort_by)::TEXT = 'email' AND sqlc.arg(sort_desc)::BOOLEAN THEN email END DESC,
+    CASE WHEN sqlc.arg(sort_by)::TEXT = 'createdAt' AND NOT sqlc.arg(sort_desc)::BOOLEAN THEN created_at END ASC,
+    CASE WHEN sqlc.arg(sort_by)::TEXT = 'createdAt' AND sqlc.arg(sort_desc)::BOOLEAN THEN created_at END DESC,
+    CASE WHEN NOT sqlc.arg(sort_desc)::BOOLEAN THEN id END ASC,
+    id DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+WHERE
+    (sqlc.narg(search)::TEXT IS NULL OR name ILIKE '%' || sqlc.narg(search) || '%' OR email ILIKE '%' || sqlc.narg(search) || '%') AND
+    (sqlc.narg(role)::TEXT IS NULL OR role = sqlc.narg(role)) AND
+    (sqlc.narg(is_deleted)::BOOLEAN IS NULL OR is_deleted = sqlc.narg(is_deleted)) AND
+    (sqlc.narg(suspended)::BOOLEAN IS NULL OR (suspended_at IS NOT NULL) = sqlc.narg(suspended));
+
+-- name: SuspendUser :execrows
+UPDATE users SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND is_deleted = FALSE AND suspended_at IS NULL;
+
+-- name: RestoreUser :execrows
+-- Lifts a suspension and undoes a deletion alike
+UPDATE users SET suspended_at = NULL, is_deleted = FALSE, updated_at = NOW()
+WHERE id = $1 AND (is_deleted = TRUE OR suspended_at IS NOT NULL);
+
+-- name: UpdateUserRole :execrows
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2 AND is_deleted = FALSE;
+
+-- name: UpdateUserAvatar :execrows
+-- A NULL key removes the avatar
+UPDATE users SET avatar_key = $1, updated_at = NOW() WHERE id = $2 AND is_deleted = FALSE;
+
+-- name: ListPasswordHistory :many
+SELECT password FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2;
+
+-- name: CreatePasswordHistory :exec
+INSERT INTO password_history (user_id, password) VALUES ($1, $2);
+
+-- name: PrunePasswordHistory :exec
+-- Keeps the newest entries of a user, older ones are never checked again
+DELETE FROM password_history
+WHERE user_id = sqlc.arg(user_id) AND id NOT IN (
+    SELECT id FROM password_history
+    WHERE user_id = sqlc.arg(user_id)
+    ORDER BY id DESC
+    LIMIT sqlc.arg(keep)
+);
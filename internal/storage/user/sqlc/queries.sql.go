@@ -10,6 +10,73 @@ import (
 	"database/sql"
 )
 
+const changeUserEmail = `-- name: ChangeUserEmail :execrows
+UPDATE users SET
+    email = $1,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $2 AND email = $3 AND is_deleted = FALSE
+`
+
+type ChangeUserEmailParams struct {
+	NewEmail string
+	ID       int64
+	OldEmail string
+}
+
+// The confirmation link proved the new address, so it is verified too. The old email is
+// part of the condition so an outdated link can't undo a later change
+func (q *Queries) ChangeUserEmail(ctx context.Context, arg ChangeUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, changeUserEmail, arg.NewEmail, arg.ID, arg.OldEmail)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+WHERE
+    ($1::TEXT IS NULL OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%') AND
+    ($2::TEXT IS NULL OR role = $2) AND
+    ($3::BOOLEAN IS NULL OR is_deleted = $3) AND
+    ($4::BOOLEAN IS NULL OR (suspended_at IS NOT NULL) = $4)
+`
+
+type CountUsersParams struct {
+	Search    sql.NullString
+	Role      sql.NullString
+	IsDeleted sql.NullBool
+	Suspended sql.NullBool
+}
+
+func (q *Queries) CountUsers(ctx context.Context, arg CountUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers,
+		arg.Search,
+		arg.Role,
+		arg.IsDeleted,
+		arg.Suspended,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createPasswordHistory = `-- name: CreatePasswordHistory :exec
+INSERT INTO password_history (user_id, password) VALUES ($1, $2)
+`
+
+type CreatePasswordHistoryParams struct {
+	UserID   int64
+	Password string
+}
+
+func (q *Queries) CreatePasswordHistory(ctx context.Context, arg CreatePasswordHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, createPasswordHistory, arg.UserID, arg.Password)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (name, email, phone, role, password)
 VALUES ($1, $2, $3, $4, $5)
@@ -46,6 +113,54 @@ func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
 	return err
 }
 
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :execrows
+UPDATE users SET
+    email_verified_at = COALESCE(email_verified_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1 AND email = $2 AND is_deleted = FALSE
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID    int64
+	Email string
+}
+
+// The email is part of the condition so a link issued for an old address can't verify a new one
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markUserEmailVerified, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAnyUserById = `-- name: GetAnyUserById :one
+SELECT id, name, email, phone, role, password, created_at, updated_at, is_deleted, email_verified_at, suspended_at, avatar_key
+FROM users
+WHERE id = $1
+`
+
+// Deleted users are included, for the admin API
+func (q *Queries) GetAnyUserById(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getAnyUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Phone,
+		&i.Role,
+		&i.Password,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsDeleted,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.AvatarKey,
+	)
+	return i, err
+}
+
 const getUserById = `-- name: GetUserById :one
 SELECT 
     id, 
@@ -56,7 +171,10 @@ SELECT
     password, 
     created_at, 
     updated_at, 
-    is_deleted
+    is_deleted,
+    email_verified_at,
+    suspended_at,
+    avatar_key
 FROM users 
 WHERE 
     id = $1 AND
@@ -76,27 +194,257 @@ func (q *Queries) GetUserById(ctx context.Context, id int64) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsDeleted,
+		&i.EmailVerifiedAt,
+		&i.SuspendedAt,
+		&i.AvatarKey,
 	)
 	return i, err
 }
 
+const listPasswordHistory = `-- name: ListPasswordHistory :many
+SELECT password FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2
+`
+
+type ListPasswordHistoryParams struct {
+	UserID int64
+	Limit  int32
+}
+
+func (q *Queries) ListPasswordHistory(ctx context.Context, arg ListPasswordHistoryParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listPasswordHistory, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var password string
+		if err := rows.Scan(&password); err != nil {
+			return nil, err
+		}
+		items = append(items, password)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, name, email, phone, role, password, created_at, updated_at, is_deleted, email_verified_at, suspended_at, avatar_key
+FROM users
+WHERE
+    ($1::TEXT IS NULL OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%') AND
+    ($2::TEXT IS NULL OR role = $2) AND
+    ($3::BOOLEAN IS NULL OR is_deleted = $3) AND
+    ($4::BOOLEAN IS NULL OR (suspended_at IS NOT NULL) = $4)
+ORDER BY
+    CASE WHEN $5::TEXT = 'name' AND NOT $6::BOOLEAN THEN name END ASC,
+    CASE WHEN $5::TEXT = 'name' AND $6::BOOLEAN THEN name END DESC,
+    CASE WHEN $5::TEXT = 'email' AND NOT $6::BOOLEAN THEN email END ASC,
+    CASE WHEN $5::TEXT = 'email' AND $6::BOOLEAN THEN email END DESC,
+    CASE WHEN $5::TEXT = 'createdAt' AND NOT $6::BOOLEAN THEN created_at END ASC,
+    CASE WHEN $5::TEXT = 'createdAt' AND $6::BOOLEAN THEN created_at END DESC,
+    CASE WHEN NOT $6::BOOLEAN THEN id END ASC,
+    id DESC
+LIMIT $7 OFFSET $8
+`
+
+type ListUsersParams struct {
+	Search     sql.NullString
+	Role       sql.NullString
+	IsDeleted  sql.NullBool
+	Suspended  sql.NullBool
+	SortBy     string
+	SortDesc   bool
+	PageLimit  int32
+	PageOffset int32
+}
+
+// Every filter is optional, a NULL filter matches all users. The search matches a
+// substring of the name or email, LIKE wildcards have to be escaped by the caller
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.Search,
+		arg.Role,
+		arg.IsDeleted,
+		arg.Suspended,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Phone,
+			&i.Role,
+			&i.Password,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.IsDeleted,
+			&i.EmailVerifiedAt,
+			&i.SuspendedAt,
+			&i.AvatarKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const prunePasswordHistory = `-- name: PrunePasswordHistory :exec
+DELETE FROM password_history
+WHERE user_id = $1 AND id NOT IN (
+    SELECT id FROM password_history
+    WHERE user_id = $1
+    ORDER BY id DESC
+    LIMIT $2
+)
+`
+
+type PrunePasswordHistoryParams struct {
+	UserID int64
+	Keep   int32
+}
+
+// Keeps the newest entries of a user, older ones are never checked again
+func (q *Queries) PrunePasswordHistory(ctx context.Context, arg PrunePasswordHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, prunePasswordHistory, arg.UserID, arg.Keep)
+	return err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :execrows
+UPDATE users SET password = $1
+WHERE id = $2 AND password = $3 AND is_deleted = FALSE
+`
+
+type RehashUserPasswordParams struct {
+	NewPassword string
+	ID          int64
+	OldPassword string
+}
+
+// The hash is only replaced while it's still the one the password was checked against,
+// so a concurrent password change is never overwritten
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewPassword, arg.ID, arg.OldPassword)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE users SET suspended_at = NULL, is_deleted = FALSE, updated_at = NOW()
+WHERE id = $1 AND (is_deleted = TRUE OR suspended_at IS NOT NULL)
+`
+
+// Lifts a suspension and undoes a deletion alike
+func (q *Queries) RestoreUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const suspendUser = `-- name: SuspendUser :execrows
+UPDATE users SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND is_deleted = FALSE AND suspended_at IS NULL
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, suspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :exec
-UPDATE users SET name = $1, email = $2, phone = $3, updated_at = NOW() WHERE id = $4 AND is_deleted = FALSE
+UPDATE users SET
+    name = $1,
+    phone = $2,
+    updated_at = NOW()
+WHERE id = $3 AND is_deleted = FALSE
 `
 
 type UpdateUserParams struct {
 	Name  string
-	Email string
 	Phone sql.NullString
 	ID    int64
 }
 
+// The email is changed with ChangeUserEmail once the new address is confirmed
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
-	_, err := q.db.ExecContext(ctx, updateUser,
-		arg.Name,
-		arg.Email,
-		arg.Phone,
-		arg.ID,
-	)
+	_, err := q.db.ExecContext(ctx, updateUser, arg.Name, arg.Phone, arg.ID)
 	return err
 }
+
+const updateUserAvatar = `-- name: UpdateUserAvatar :execrows
+UPDATE users SET avatar_key = $1, updated_at = NOW() WHERE id = $2 AND is_deleted = FALSE
+`
+
+type UpdateUserAvatarParams struct {
+	AvatarKey sql.NullString
+	ID        int64
+}
+
+// A NULL key removes the avatar
+func (q *Queries) UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserAvatar, arg.AvatarKey, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2 AND is_deleted = FALSE
+`
+
+type UpdateUserPasswordParams struct {
+	Password string
+	ID       int64
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.Password, arg.ID)
+	return err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :execrows
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2 AND is_deleted = FALSE
+`
+
+type UpdateUserRoleParams struct {
+	Role string
+	ID   int64
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserRole, arg.Role, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
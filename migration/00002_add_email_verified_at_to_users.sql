@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Only confirmed enrolments are stored, pending ones live in Redis until confirmed
+CREATE TABLE user_totp (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx
+ON user_recovery_codes (user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS user_recovery_codes_user_id_idx;
+DROP TABLE user_recovery_codes;
+DROP TABLE user_totp;
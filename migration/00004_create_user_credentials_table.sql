@@ -0,0 +1,26 @@
+-- +goose Up
+-- WebAuthn (passkey) credentials, a user may register several authenticators
+CREATE TABLE user_credentials (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL,
+    public_key BYTEA NOT NULL,
+    attestation_type TEXT NOT NULL,
+    aaguid BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    clone_warning BOOLEAN NOT NULL DEFAULT FALSE,
+    -- comma separated authenticator transports (usb, nfc, ble, internal, hybrid)
+    transports TEXT NOT NULL DEFAULT '',
+    flags SMALLINT NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NULL,
+    CONSTRAINT user_credentials_credential_id_unique UNIQUE (credential_id)
+);
+
+CREATE INDEX user_credentials_user_id_idx
+ON user_credentials (user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS user_credentials_user_id_idx;
+DROP TABLE user_credentials;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- External OpenID Connect identities linked to local users
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT user_identities_provider_subject_unique UNIQUE (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx
+ON user_identities (user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS user_identities_user_id_idx;
+DROP TABLE user_identities;
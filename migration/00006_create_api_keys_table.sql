@@ -0,0 +1,24 @@
+-- +goose Up
+-- Personal access tokens for scripts and CI jobs, sent as "Authorization: Bearer pat_..."
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    -- leading characters of the token, shown in the key list to tell keys apart
+    prefix TEXT NOT NULL,
+    -- SHA-256 of the whole token, the token itself is only shown once
+    secret_hash TEXT NOT NULL,
+    -- comma separated permissions, e.g. users:read:self
+    scopes TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NULL,
+    last_used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT api_keys_secret_hash_unique UNIQUE (secret_hash)
+);
+
+CREATE INDEX api_keys_user_id_idx
+ON api_keys (user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS api_keys_user_id_idx;
+DROP TABLE api_keys;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Session store for deployments without Redis: sessions, their per-user index and the
+-- short-lived tokens and counters of the auth flows. Plain values use an empty field,
+-- hashes and sets keep one row per field or member
+CREATE TABLE sessions (
+    key TEXT NOT NULL,
+    field TEXT NOT NULL DEFAULT '',
+    value TEXT NOT NULL,
+    -- NULL never expires, expired rows are ignored by reads and deleted periodically
+    expires_at TIMESTAMPTZ NULL,
+    PRIMARY KEY (key, field)
+);
+
+CREATE INDEX sessions_expires_at_idx
+ON sessions (expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS sessions_expires_at_idx;
+DROP TABLE sessions;
@@ -0,0 +1,46 @@
+-- +goose Up
+-- Append-only record of security relevant events, rows are never updated or deleted
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    -- user who acted, NULL when nobody was authenticated, e.g. on a failed login
+    actor_id BIGINT NULL,
+    -- "<resource>.<verb>", e.g. auth.login or user.update
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id BIGINT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    -- changed fields as {"<field>": {"from": ..., "to": ...}}
+    diff JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_events_actor_id_idx
+ON audit_events (actor_id, created_at);
+
+CREATE INDEX audit_events_target_idx
+ON audit_events (target_type, target_id, created_at);
+
+CREATE INDEX audit_events_created_at_idx
+ON audit_events (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP INDEX IF EXISTS audit_events_created_at_idx;
+DROP INDEX IF EXISTS audit_events_target_idx;
+DROP INDEX IF EXISTS audit_events_actor_id_idx;
+DROP TABLE audit_events;
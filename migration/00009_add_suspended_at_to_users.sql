@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMPTZ NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN suspended_at;
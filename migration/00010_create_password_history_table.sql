@@ -0,0 +1,15 @@
+-- +goose Up
+-- Previous password hashes of a user, checked so that recent passwords are not reused
+CREATE TABLE password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX password_history_user_id_idx
+ON password_history (user_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS password_history_user_id_idx;
+DROP TABLE password_history;
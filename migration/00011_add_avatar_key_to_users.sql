@@ -0,0 +1,6 @@
+-- +goose Up
+-- avatar_key is the object storage key of the avatar, thumbnails are stored next to it
+ALTER TABLE users ADD COLUMN avatar_key TEXT NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN avatar_key;
@@ -0,0 +1,29 @@
+const CSRF_COOKIE = 'csrf_token'
+const CSRF_HEADER = 'X-CSRF-Token'
+const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS', 'TRACE']
+
+// csrfToken reads the token the API sets next to the session cookie
+function csrfToken(): string | undefined {
+  return document.cookie
+    .split('; ')
+    .find((cookie) => cookie.startsWith(`${CSRF_COOKIE}=`))
+    ?.slice(CSRF_COOKIE.length + 1)
+}
+
+// refreshCsrfToken asks the API for a new token, e.g. after a 403 from an expired one
+export async function refreshCsrfToken(): Promise<void> {
+  await fetch('/api/v1/auth/csrf', { credentials: 'include' })
+}
+
+// apiFetch sends the session cookie and echoes the CSRF token on unsafe methods
+export function apiFetch(input: RequestInfo | URL, init: RequestInit = {}): Promise<Response> {
+  const method = (init.method ?? 'GET').toUpperCase()
+  const headers = new Headers(init.headers)
+
+  const token = csrfToken()
+  if (!SAFE_METHODS.includes(method) && token) {
+    headers.set(CSRF_HEADER, decodeURIComponent(token))
+  }
+
+  return fetch(input, { ...init, headers, credentials: 'include' })
+}